@@ -2,8 +2,9 @@ package main
 
 import (
 	"log"
-	"ngabaca/internal/routes" // <-- Import routes
-	"ngabaca/internal/server" // <-- Import server
+	"ngabaca/internal/routes"    // <-- Import routes
+	"ngabaca/internal/scheduler" // <-- Import scheduler
+	"ngabaca/internal/server"    // <-- Import server
 
 	"github.com/robfig/cron/v3"
 )
@@ -14,7 +15,14 @@ func main() {
 
 	// 2. Jalankan scheduler (jika ada)
 	c := cron.New()
-	// c.AddFunc(...)
+	c.AddFunc("@every 5m", func() { scheduler.CancelExpiredOrders(server.Cfg) })
+	c.AddFunc("@every 1m", scheduler.ExpireReservations)
+	c.AddFunc("@every 1m", scheduler.SyncFlashSaleCounters)
+	c.AddFunc("@hourly", scheduler.ExpireRentals)
+	c.AddFunc("@hourly", func() { scheduler.NotifyExpiringRentals(server.Cfg) })
+	c.AddFunc("@daily", func() { scheduler.ExpireLoyaltyPoints(server.Cfg) })
+	c.AddFunc("@every 15m", func() { scheduler.PollShipments(server.Cfg) })
+	c.AddFunc("@every 10m", func() { scheduler.SettleRefunds(server.Cfg) })
 	go c.Start()
 	defer c.Stop()
 
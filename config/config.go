@@ -35,6 +35,68 @@ type Config struct {
 	RedisAddr             string `mapstructure:"REDIS_ADDR"`
 	RedisPassword         string `mapstructure:"REDIS_PASSWORD"`
 	RedisDB               int    `mapstructure:"REDIS_DB"`
+
+	// Pengiriman ebook
+	EbookStoragePath       string `mapstructure:"EBOOK_STORAGE_PATH"`
+	DownloadSigningSecret  string `mapstructure:"DOWNLOAD_SIGNING_SECRET"` // Kunci tanda tangan URL unduhan, kosong berarti memakai JWT_SECRET
+	DownloadLinkTTLMinutes int    `mapstructure:"DOWNLOAD_LINK_TTL_MINUTES"`
+	MaxDownloadsPerEbook   int    `mapstructure:"MAX_DOWNLOADS_PER_EBOOK"`
+	WatermarkCachePath     string `mapstructure:"WATERMARK_CACHE_PATH"`
+
+	// Sampel buku
+	PreviewStoragePath        string `mapstructure:"PREVIEW_STORAGE_PATH"`
+	PreviewRateLimitPerMinute int    `mapstructure:"PREVIEW_RATE_LIMIT_PER_MINUTE"`
+
+	// Keranjang tamu
+	GuestCartTTLHours   int    `mapstructure:"GUEST_CART_TTL_HOURS"`
+	CartMergeStrategy   string `mapstructure:"CART_MERGE_STRATEGY"` // sum, max, atau replace
+	CartMergeCapToStock bool   `mapstructure:"CART_MERGE_CAP_TO_STOCK"`
+
+	// Reservasi stok
+	CartReservationTTLMinutes     int `mapstructure:"CART_RESERVATION_TTL_MINUTES"`
+	CheckoutReservationTTLMinutes int `mapstructure:"CHECKOUT_RESERVATION_TTL_MINUTES"`
+
+	// Harga pesanan
+	TaxPricesInclusive  bool    `mapstructure:"TAX_PRICES_INCLUSIVE"` // Harga katalog sudah termasuk PPN
+	TaxRatePhysicalBook float64 `mapstructure:"TAX_RATE_PHYSICAL_BOOK"`
+	TaxRateEbook        float64 `mapstructure:"TAX_RATE_EBOOK"`
+	TaxRateExempt       float64 `mapstructure:"TAX_RATE_EXEMPT"`
+
+	// Ongkos kirim
+	ShippingProviders          string `mapstructure:"SHIPPING_PROVIDERS"` // Daftar dipisah koma: table, fake, jne, sicepat
+	ShippingOriginPostalCode   string `mapstructure:"SHIPPING_ORIGIN_POSTAL_CODE"`
+	ShippingDefaultWeightGrams int    `mapstructure:"SHIPPING_DEFAULT_WEIGHT_GRAMS"` // Dipakai untuk buku yang beratnya belum diisi
+	ShippingCourierAPIURL      string `mapstructure:"SHIPPING_COURIER_API_URL"`
+	ShippingCourierAPIKey      string `mapstructure:"SHIPPING_COURIER_API_KEY"`
+
+	// Program poin loyalti
+	LoyaltySpendPerPoint      float64 `mapstructure:"LOYALTY_SPEND_PER_POINT"`
+	LoyaltyPointValue         float64 `mapstructure:"LOYALTY_POINT_VALUE"`
+	LoyaltyPointsExpiryMonths int     `mapstructure:"LOYALTY_POINTS_EXPIRY_MONTHS"`
+	LoyaltySilverSpend        float64 `mapstructure:"LOYALTY_SILVER_SPEND"`
+	LoyaltyGoldSpend          float64 `mapstructure:"LOYALTY_GOLD_SPEND"`
+	LoyaltySilverMultiplier   float64 `mapstructure:"LOYALTY_SILVER_MULTIPLIER"`
+	LoyaltyGoldMultiplier     float64 `mapstructure:"LOYALTY_GOLD_MULTIPLIER"`
+
+	// Idempotency-Key
+	IdempotencyKeyTTLHours int `mapstructure:"IDEMPOTENCY_KEY_TTL_HOURS"`
+
+	// Faktur
+	InvoiceStoragePath string `mapstructure:"INVOICE_STORAGE_PATH"`
+	StoreName          string `mapstructure:"STORE_NAME"`
+	StoreAddress       string `mapstructure:"STORE_ADDRESS"`
+	StoreNPWP          string `mapstructure:"STORE_NPWP"`
+
+	// Pembayaran dan pembatalan
+	PaymentGateway           string `mapstructure:"PAYMENT_GATEWAY"`            // midtrans, atau fake untuk pengembangan lokal
+	CustomerCancelProcessing bool   `mapstructure:"CUSTOMER_CANCEL_PROCESSING"` // Pembeli boleh membatalkan pesanan diproses yang belum dikirim
+
+	// Retur
+	ReturnWindowDays int `mapstructure:"RETURN_WINDOW_DAYS"` // Batas hari sejak pesanan selesai untuk mengajukan retur
+	ReturnMaxPhotos  int `mapstructure:"RETURN_MAX_PHOTOS"`  // Jumlah foto bukti maksimal per retur
+
+	// Pelacakan pengiriman
+	ShipmentTracker string `mapstructure:"SHIPMENT_TRACKER"` // api, fake, atau kosong untuk pembaruan manual oleh admin
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -44,11 +106,50 @@ func LoadConfig(path string) (config Config, err error) {
 
 	viper.AutomaticEnv()
 
+	// Nilai default untuk konfigurasi opsional
+	viper.SetDefault("EBOOK_STORAGE_PATH", "./storage/ebooks")
+	viper.SetDefault("DOWNLOAD_SIGNING_SECRET", "")
+	viper.SetDefault("DOWNLOAD_LINK_TTL_MINUTES", 15)
+	viper.SetDefault("MAX_DOWNLOADS_PER_EBOOK", 5)
+	viper.SetDefault("WATERMARK_CACHE_PATH", "./storage/watermarked")
+	viper.SetDefault("PREVIEW_STORAGE_PATH", "./storage/previews")
+	viper.SetDefault("PREVIEW_RATE_LIMIT_PER_MINUTE", 10)
+	viper.SetDefault("GUEST_CART_TTL_HOURS", 168)
+	viper.SetDefault("CART_MERGE_STRATEGY", "sum")
+	viper.SetDefault("CART_MERGE_CAP_TO_STOCK", true)
+	viper.SetDefault("CART_RESERVATION_TTL_MINUTES", 15)
+	viper.SetDefault("CHECKOUT_RESERVATION_TTL_MINUTES", 60)
+	viper.SetDefault("TAX_PRICES_INCLUSIVE", true)
+	viper.SetDefault("TAX_RATE_PHYSICAL_BOOK", 11)
+	viper.SetDefault("TAX_RATE_EBOOK", 11)
+	viper.SetDefault("TAX_RATE_EXEMPT", 0)
+	viper.SetDefault("SHIPPING_PROVIDERS", "table")
+	viper.SetDefault("SHIPPING_ORIGIN_POSTAL_CODE", "10110")
+	viper.SetDefault("SHIPPING_DEFAULT_WEIGHT_GRAMS", 500)
+	viper.SetDefault("LOYALTY_SPEND_PER_POINT", 10000)
+	viper.SetDefault("LOYALTY_POINT_VALUE", 100)
+	viper.SetDefault("LOYALTY_POINTS_EXPIRY_MONTHS", 12)
+	viper.SetDefault("LOYALTY_SILVER_SPEND", 1000000)
+	viper.SetDefault("LOYALTY_GOLD_SPEND", 5000000)
+	viper.SetDefault("LOYALTY_SILVER_MULTIPLIER", 1.25)
+	viper.SetDefault("LOYALTY_GOLD_MULTIPLIER", 1.5)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL_HOURS", 24)
+	viper.SetDefault("INVOICE_STORAGE_PATH", "./storage/invoices")
+	viper.SetDefault("STORE_NAME", "Ngabaca")
+	viper.SetDefault("PAYMENT_GATEWAY", "midtrans")
+	viper.SetDefault("CUSTOMER_CANCEL_PROCESSING", true)
+	viper.SetDefault("RETURN_WINDOW_DAYS", 14)
+	viper.SetDefault("RETURN_MAX_PHOTOS", 5)
+	viper.SetDefault("SHIPMENT_TRACKER", "api")
+
 	err = viper.ReadInConfig()
 	if err != nil {
 		return
 	}
 
 	err = viper.Unmarshal(&config)
+	if config.DownloadSigningSecret == "" {
+		config.DownloadSigningSecret = config.JWTSecret
+	}
 	return
 }
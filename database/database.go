@@ -60,10 +60,86 @@ func ConnectDB(cfg config.Config) *gorm.DB {
 		&model.Wishlist{},
 		&model.Cart{},
 		&model.CartItem{},
+		&model.Entitlement{},
+		&model.DownloadLog{},
+		&model.ReadingProgress{},
+		&model.Bookmark{},
+		&model.Highlight{},
+		&model.StockReservation{},
+		&model.Coupon{},
+		&model.CouponRedemption{},
+		&model.FlashSale{},
+		&model.FlashSaleItem{},
+		&model.FlashSalePurchase{},
+		&model.Promotion{},
+		&model.OrderItemDiscount{},
+		&model.Wallet{},
+		&model.WalletTransaction{},
+		&model.GiftCard{},
+		&model.LoyaltyAccount{},
+		&model.LoyaltyEntry{},
+		&model.Address{},
+		&model.OrderSequence{},
+		&model.Refund{},
+		&model.ReturnRequest{},
+		&model.ReturnItem{},
+		&model.ReturnPhoto{},
+		&model.Shipment{},
+		&model.ShipmentItem{},
+		&model.ShipmentEvent{},
+		&model.OrderStatusHistory{},
 	)
 	if err != nil {
 		log.Fatal("Gagal melakukan migrasi:", err)
 	}
+
+	// Indeks unik kode kupon lama juga mencakup kupon yang sudah dihapus, digantikan idx_coupons_code_active
+	if DB.Migrator().HasIndex(&model.Coupon{}, "idx_coupons_code") {
+		if err := DB.Migrator().DropIndex(&model.Coupon{}, "idx_coupons_code"); err != nil {
+			log.Fatal("Gagal menghapus indeks kode kupon lama:", err)
+		}
+	}
+	if err := backfillOrderNumbers(DB); err != nil {
+		log.Fatal("Gagal mengisi nomor pesanan lama:", err)
+	}
 	fmt.Println("Migrasi database selesai.")
 	return DB
 }
+
+// backfillOrderNumbers memberi nomor pesanan kepada pesanan yang dibuat sebelum ada nomor pesanan.
+// Nomor diambil dari urutan tahun pesanan dibuat, berurutan menurut waktu pembuatannya, dan
+// melanjutkan nomor terakhir tahun itu. Tabel urutan dikunci agar tidak bertabrakan dengan checkout.
+func backfillOrderNumbers(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE order_sequences IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		var orders []model.Order
+		err := tx.Unscoped().Select("id", "created_at").
+			Where("order_number IS NULL OR order_number = ''").
+			Order("created_at, id").
+			Find(&orders).Error
+		if err != nil || len(orders) == 0 {
+			return err
+		}
+
+		for _, order := range orders {
+			year := order.CreatedAt.Year()
+			var last int
+			err := tx.Raw(`INSERT INTO order_sequences (year, last_number) VALUES (?, 1)
+				ON CONFLICT (year) DO UPDATE SET last_number = order_sequences.last_number + 1
+				RETURNING last_number`, year).Scan(&last).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Unscoped().Model(&model.Order{}).Where("id = ?", order.ID).
+				Update("order_number", model.FormatOrderNumber(year, last)).Error
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Nomor pesanan diisi untuk %d pesanan lama.\n", len(orders))
+		return nil
+	})
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AddressHandler menampung dependency untuk buku alamat pengguna.
+type AddressHandler struct {
+	addressRepo repository.AddressRepository
+}
+
+// NewAddressHandler adalah constructor untuk AddressHandler.
+func NewAddressHandler(addressRepo repository.AddressRepository) *AddressHandler {
+	return &AddressHandler{
+		addressRepo: addressRepo,
+	}
+}
+
+// AddressRequest adalah body untuk membuat atau mengubah alamat.
+type AddressRequest struct {
+	Label         string `json:"label" validate:"omitempty,max=50"`
+	RecipientName string `json:"recipient_name" validate:"required,min=3,max=100"`
+	Phone         string `json:"phone" validate:"required,min=10,max=20,numeric"`
+	Province      string `json:"province" validate:"required,max=100"`
+	City          string `json:"city" validate:"required,max=100"`
+	District      string `json:"district" validate:"required,max=100"`
+	PostalCode    string `json:"postal_code" validate:"required,len=5,numeric"`
+	Detail        string `json:"detail" validate:"required,min=5,max=255"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+// apply menyalin isi request ke model alamat.
+func (r *AddressRequest) apply(address *model.Address) {
+	address.Label = r.Label
+	address.RecipientName = r.RecipientName
+	address.Phone = r.Phone
+	address.Province = r.Province
+	address.City = r.City
+	address.District = r.District
+	address.PostalCode = r.PostalCode
+	address.Detail = r.Detail
+}
+
+// GetMyAddresses menampilkan semua alamat tersimpan milik pengguna.
+func (h *AddressHandler) GetMyAddresses(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	addresses, err := h.addressRepo.FindByUserID(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch addresses")
+	}
+	return c.JSON(addresses)
+}
+
+// GetMyAddress menampilkan satu alamat milik pengguna.
+func (h *AddressHandler) GetMyAddress(c *fiber.Ctx) error {
+	address, err := h.findMyAddress(c)
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+	return c.JSON(address)
+}
+
+// CreateMyAddress menambah alamat baru ke buku alamat pengguna.
+func (h *AddressHandler) CreateMyAddress(c *fiber.Ctx) error {
+	req := new(AddressRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	address := &model.Address{UserID: userID, IsDefault: req.IsDefault}
+	req.apply(address)
+	createdAddress, err := h.addressRepo.Create(address)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create address")
+	}
+	return c.Status(fiber.StatusCreated).JSON(createdAddress)
+}
+
+// UpdateMyAddress mengubah alamat milik pengguna. Pesanan lama tidak ikut berubah karena
+// menyimpan salinan alamatnya sendiri.
+func (h *AddressHandler) UpdateMyAddress(c *fiber.Ctx) error {
+	address, err := h.findMyAddress(c)
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	req := new(AddressRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	req.apply(&address)
+	// Alamat utama hanya bisa dilepas dengan menjadikan alamat lain sebagai utama
+	if req.IsDefault {
+		address.IsDefault = true
+	}
+	updatedAddress, err := h.addressRepo.Update(&address)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update address")
+	}
+	return c.JSON(updatedAddress)
+}
+
+// DeleteMyAddress menghapus alamat milik pengguna.
+func (h *AddressHandler) DeleteMyAddress(c *fiber.Ctx) error {
+	address, err := h.findMyAddress(c)
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	if err := h.addressRepo.Delete(&address); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to delete address")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// findMyAddress mengambil alamat dari parameter :id yang dimiliki pengguna yang sedang login.
+// Alamat milik pengguna lain dijawab 404, sama seperti alamat yang tidak ada.
+func (h *AddressHandler) findMyAddress(c *fiber.Ctx) (model.Address, error) {
+	addressID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return model.Address{}, fiber.NewError(fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	address, err := h.addressRepo.FindByIDAndUserID(addressID, userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return model.Address{}, fiber.NewError(fiber.StatusNotFound, "Address not found")
+		}
+		return model.Address{}, fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	return address, nil
+}
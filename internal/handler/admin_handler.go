@@ -1,15 +1,21 @@
 package handler
 
 import (
+	"errors"
 	"io"
 	"ngabaca/config"
 	"ngabaca/internal/model"
 	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
 	"ngabaca/internal/utils"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -31,18 +37,32 @@ type BookResponse struct {
 	Book    BookData `json:"book"`
 }
 type AdminHandler struct {
-	bookRepo  repository.BookRepository
-	userRepo  repository.UserRepository
-	orderRepo repository.OrderRepository
-	cfg       config.Config
+	bookRepo        repository.BookRepository
+	userRepo        repository.UserRepository
+	orderRepo       repository.OrderRepository
+	categoryRepo    repository.CategoryRepository
+	couponRepo      repository.CouponRepository
+	flashSaleRepo   repository.FlashSaleRepository
+	promotionRepo   repository.PromotionRepository
+	orderService    service.OrderService
+	invoiceService  service.InvoiceService
+	shipmentService service.ShipmentService
+	cfg             config.Config
 }
 
-func NewAdminHandler(bookRepo repository.BookRepository, userRepo repository.UserRepository, orderRepo repository.OrderRepository, cfg config.Config) *AdminHandler {
+func NewAdminHandler(bookRepo repository.BookRepository, userRepo repository.UserRepository, orderRepo repository.OrderRepository, categoryRepo repository.CategoryRepository, couponRepo repository.CouponRepository, flashSaleRepo repository.FlashSaleRepository, promotionRepo repository.PromotionRepository, orderService service.OrderService, invoiceService service.InvoiceService, shipmentService service.ShipmentService, cfg config.Config) *AdminHandler {
 	return &AdminHandler{
-		bookRepo:  bookRepo,
-		userRepo:  userRepo,
-		orderRepo: orderRepo,
-		cfg:       cfg,
+		bookRepo:        bookRepo,
+		userRepo:        userRepo,
+		orderRepo:       orderRepo,
+		categoryRepo:    categoryRepo,
+		couponRepo:      couponRepo,
+		flashSaleRepo:   flashSaleRepo,
+		promotionRepo:   promotionRepo,
+		orderService:    orderService,
+		invoiceService:  invoiceService,
+		shipmentService: shipmentService,
+		cfg:             cfg,
 	}
 }
 
@@ -74,11 +94,11 @@ func (h *AdminHandler) AdminGetBook(c *fiber.Ctx) error {
 func (h *AdminHandler) AdminCreateBook(c *fiber.Ctx) error {
 	// Variabel untuk menampung nilai input
 	var (
-		title, author, description, coverURL, categoryIDStr string
-		price                                               float64
-		stock, publishedYear                                int
-		categoryUUID                                        uuid.UUID
-		err                                                 error
+		title, author, description, coverURL, categoryIDStr, format string
+		price                                                       float64
+		stock, publishedYear                                        int
+		categoryUUID                                                uuid.UUID
+		err                                                         error
 	)
 
 	// Logika parsing request tetap di handler, ini sudah benar.
@@ -92,6 +112,7 @@ func (h *AdminHandler) AdminCreateBook(c *fiber.Ctx) error {
 		stockStr := c.FormValue("stock")
 		publishedYearStr := c.FormValue("published_year")
 		categoryIDStr = c.FormValue("category_id")
+		format = c.FormValue("format", model.BookFormatPhysical)
 
 		// Validasi & konversi tipe data
 		price, err = strconv.ParseFloat(priceStr, 64)
@@ -128,12 +149,16 @@ func (h *AdminHandler) AdminCreateBook(c *fiber.Ctx) error {
 		publishedYear = req.PublishedYear
 		categoryIDStr = req.CategoryID.String()
 		coverURL = req.CoverImageURL
+		format = utils.DefaultString(req.Format, model.BookFormatPhysical)
 	}
 
 	categoryUUID, err = uuid.Parse(categoryIDStr)
 	if err != nil {
 		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid category_id format")
 	}
+	if !isValidBookFormat(format) {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid format. Use 'physical', 'ebook', or 'gift_card'")
+	}
 
 	// REFACTOR: Logika pengecekan slug sekarang memanggil repository
 	baseSlug := utils.GenerateSlug(title)
@@ -160,6 +185,7 @@ func (h *AdminHandler) AdminCreateBook(c *fiber.Ctx) error {
 		Stock:         stock,
 		PublishedYear: publishedYear,
 		CoverImageURL: coverURL,
+		Format:        format,
 		CategoryID:    categoryUUID,
 	}
 
@@ -189,10 +215,10 @@ func (h *AdminHandler) AdminUpdateBook(c *fiber.Ctx) error {
 	}
 
 	var (
-		title, author, description, coverURL, categoryIDStr string
-		price                                               float64
-		stock, publishedYear                                int
-		categoryUUID                                        uuid.UUID
+		title, author, description, coverURL, categoryIDStr, format string
+		price                                                       float64
+		stock, publishedYear                                        int
+		categoryUUID                                                uuid.UUID
 	)
 
 	contentType := c.Get("Content-Type")
@@ -227,6 +253,7 @@ func (h *AdminHandler) AdminUpdateBook(c *fiber.Ctx) error {
 		}
 
 		categoryIDStr = c.FormValue("category_id", book.CategoryID.String())
+		format = c.FormValue("format", book.Format)
 
 		// File cover opsional
 		file, _ := c.FormFile("cover_image")
@@ -280,12 +307,16 @@ func (h *AdminHandler) AdminUpdateBook(c *fiber.Ctx) error {
 		} else {
 			coverURL = book.CoverImageURL
 		}
+		format = utils.DefaultString(req.Format, book.Format)
 	}
 
 	categoryUUID, err = uuid.Parse(categoryIDStr)
 	if err != nil {
 		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid category_id format")
 	}
+	if !isValidBookFormat(format) {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid format. Use 'physical', 'ebook', or 'gift_card'")
+	}
 
 	// Cek slug kalau judul berubah
 	if title != book.Title {
@@ -314,6 +345,7 @@ func (h *AdminHandler) AdminUpdateBook(c *fiber.Ctx) error {
 	book.Stock = stock
 	book.PublishedYear = publishedYear
 	book.CoverImageURL = coverURL
+	book.Format = format
 	book.CategoryID = categoryUUID
 
 	// Simpan ke DB
@@ -325,6 +357,259 @@ func (h *AdminHandler) AdminUpdateBook(c *fiber.Ctx) error {
 	return c.JSON(updatedBook)
 }
 
+// isValidBookFormat mengecek apakah format buku dikenali.
+func isValidBookFormat(format string) bool {
+	return format == model.BookFormatPhysical || format == model.BookFormatEbook || format == model.BookFormatGiftCard
+}
+
+// AdminUploadEbook mengunggah file ebook (PDF/EPUB) ke storage privat.
+func (h *AdminHandler) AdminUploadEbook(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if !book.IsDigital() {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Book format must be 'ebook' to upload an ebook file")
+	}
+
+	savePath, err := saveBookFile(c, h.cfg.EbookStoragePath, book.ID.String())
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	// Hapus file lama jika ekstensinya berbeda
+	if book.PrivateFilePath != "" && book.PrivateFilePath != savePath {
+		_ = os.Remove(book.PrivateFilePath)
+	}
+
+	book.PrivateFilePath = savePath
+	if _, err := h.bookRepo.Update(&book); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.JSON(fiber.Map{"message": "Ebook file uploaded successfully"})
+}
+
+// AdminUploadPreview mengunggah file sampel (misal bab pertama) yang bisa diakses publik.
+func (h *AdminHandler) AdminUploadPreview(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	savePath, err := saveBookFile(c, h.cfg.PreviewStoragePath, book.ID.String())
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	// Hapus file lama jika ekstensinya berbeda
+	if book.PreviewFilePath != "" && book.PreviewFilePath != savePath {
+		_ = os.Remove(book.PreviewFilePath)
+	}
+
+	book.PreviewFilePath = savePath
+	if _, err := h.bookRepo.Update(&book); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.JSON(fiber.Map{"message": "Preview file uploaded successfully"})
+}
+
+// AdminDeletePreview menghapus file sampel sebuah buku.
+func (h *AdminHandler) AdminDeletePreview(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if book.PreviewFilePath == "" {
+		return utils.GenericError(c, fiber.StatusNotFound, "Book has no preview")
+	}
+
+	_ = os.Remove(book.PreviewFilePath)
+	book.PreviewFilePath = ""
+	if _, err := h.bookRepo.Update(&book); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// UpdateRentalPricesRequest adalah body untuk mengatur harga sewa ebook. Harga 0 menonaktifkan durasi tersebut.
+type UpdateRentalPricesRequest struct {
+	RentalPrice7  float64 `json:"rental_price_7" validate:"gte=0"`
+	RentalPrice14 float64 `json:"rental_price_14" validate:"gte=0"`
+	RentalPrice30 float64 `json:"rental_price_30" validate:"gte=0"`
+}
+
+// AdminUpdateRentalPrices mengatur harga sewa 7/14/30 hari untuk sebuah ebook.
+func (h *AdminHandler) AdminUpdateRentalPrices(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(UpdateRentalPricesRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if !book.IsDigital() {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Only ebooks can be rented")
+	}
+
+	book.RentalPrice7 = req.RentalPrice7
+	book.RentalPrice14 = req.RentalPrice14
+	book.RentalPrice30 = req.RentalPrice30
+	updatedBook, err := h.bookRepo.Update(&book)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.JSON(updatedBook)
+}
+
+// UpdateBookDimensionsRequest adalah body untuk mengatur berat dan dimensi kemasan buku fisik.
+type UpdateBookDimensionsRequest struct {
+	WeightGrams int     `json:"weight_grams" validate:"required,gt=0"`
+	LengthCm    float64 `json:"length_cm" validate:"gte=0"`
+	WidthCm     float64 `json:"width_cm" validate:"gte=0"`
+	HeightCm    float64 `json:"height_cm" validate:"gte=0"`
+}
+
+// AdminUpdateBookDimensions mengatur berat dan dimensi buku fisik untuk perhitungan ongkos kirim.
+func (h *AdminHandler) AdminUpdateBookDimensions(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(UpdateBookDimensionsRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if !book.IsShippable() {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Only physical books are shipped")
+	}
+
+	book.WeightGrams = req.WeightGrams
+	book.LengthCm = req.LengthCm
+	book.WidthCm = req.WidthCm
+	book.HeightCm = req.HeightCm
+	updatedBook, err := h.bookRepo.Update(&book)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.JSON(updatedBook)
+}
+
+// UpdateBookTaxClassRequest adalah body untuk mengatur kelas pajak buku. Kosong berarti mengikuti format buku.
+type UpdateBookTaxClassRequest struct {
+	TaxClass string `json:"tax_class" validate:"omitempty,oneof=physical_book ebook exempt none"`
+}
+
+// AdminUpdateBookTaxClass mengatur kelas pajak sebuah buku, misalnya membebaskan buku pelajaran dari PPN.
+func (h *AdminHandler) AdminUpdateBookTaxClass(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(UpdateBookTaxClassRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	book, err := h.bookRepo.FindByID(bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	book.TaxClass = req.TaxClass
+	updatedBook, err := h.bookRepo.Update(&book)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update book")
+	}
+
+	return c.JSON(updatedBook)
+}
+
+// saveBookFile menyimpan file PDF/EPUB dari form field "file" ke direktori privat.
+// Error yang dikembalikan selalu berupa *fiber.Error agar bisa langsung dipetakan ke response.
+func saveBookFile(c *fiber.Ctx, dir, baseName string) (string, error) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, "File is required")
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".pdf" && ext != ".epub" {
+		return "", fiber.NewError(fiber.StatusBadRequest, "Invalid file type. Only pdf and epub are allowed.")
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to prepare file storage")
+	}
+	savePath := filepath.Join(dir, baseName+ext)
+	if err := c.SaveFile(file, savePath); err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, "Failed to save file")
+	}
+	return savePath, nil
+}
+
 // AdminDeleteBook menghapus buku.
 func (h *AdminHandler) AdminDeleteBook(c *fiber.Ctx) error {
 	bookID, err := uuid.Parse(c.Params("id"))
@@ -406,33 +691,53 @@ func (h *AdminHandler) AdminUpdateUser(c *fiber.Ctx) error {
 
 func (h *AdminHandler) AdminGetOrders(c *fiber.Ctx) error {
 	status := c.Query("status")
-	orders, err := h.orderRepo.FindAll(status)
+	orders, err := h.orderRepo.FindAll(status, c.Query("q"))
 	if err != nil {
 		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch orders")
 	}
 	return c.JSON(orders)
 }
 
-// AdminGetOrderDetail sekarang memanggil repository.
+// AdminGetOrderDetail menampilkan detail pesanan berdasarkan ID atau nomor pesanan.
 func (h *AdminHandler) AdminGetOrderDetail(c *fiber.Ctx) error {
-	orderID, err := uuid.Parse(c.Params("id"))
+	order, err := h.findOrder(c.Params("id"))
 	if err != nil {
-		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
 	}
+	return c.JSON(order)
+}
 
-	order, err := h.orderRepo.FindByID(orderID)
+// AdminGetOrderInvoice mengunduh faktur PDF sebuah pesanan yang sudah lunas.
+func (h *AdminHandler) AdminGetOrderInvoice(c *fiber.Ctx) error {
+	order, err := h.findOrder(c.Params("id"))
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
 		}
 		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
 	}
-	return c.JSON(order)
+	return sendInvoicePDF(c, h.invoiceService, order)
+}
+
+// findOrder mencari pesanan dengan ID maupun nomor pesanannya.
+func (h *AdminHandler) findOrder(ref string) (model.Order, error) {
+	if orderID, err := uuid.Parse(ref); err == nil {
+		return h.orderRepo.FindByID(orderID)
+	}
+	return h.orderRepo.FindByOrderNumber(ref)
 }
 
 // UpdateOrderStatusRequest adalah struct untuk validasi permintaan update status.
+// Data kurir dan nomor resi wajib diisi saat mengubah pesanan berisi buku fisik menjadi dikirim.
+// Pesanan bisa dikirim dalam beberapa paket dengan mengisi item yang masuk ke setiap paket.
+// Note dicatat di riwayat status, dan menjadi alasan pembatalan untuk status batal.
 type UpdateOrderStatusRequest struct {
-	Status string `json:"status" validate:"required,oneof=pending diproses dikirim selesai batal challenge"`
+	Status string `json:"status" validate:"required,oneof=dikirim selesai batal"` // Status lain hanya diubah oleh pembayaran
+	Note   string `json:"note" validate:"max=500"`
+	service.ShipOrderRequest
 }
 
 // AdminUpdateOrderStatus untuk mengubah status sebuah pesanan.
@@ -455,11 +760,758 @@ func (h *AdminHandler) AdminUpdateOrderStatus(c *fiber.Ctx) error {
 		return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
 	}
 
-	order.Status = req.Status
+	adminClaims := c.Locals("user").(jwt.MapClaims)
+	adminID, _ := uuid.Parse(adminClaims["user_id"].(string))
+
+	// Pengiriman buku fisik dicatat bersama kurir dan nomor resinya
+	if req.Status == model.OrderStatusShipped && order.Status != model.OrderStatusShipped && order.HasShippableItems() {
+		if _, err := h.shipmentService.Ship(adminID, order.ID, &req.ShipOrderRequest); err != nil {
+			return shipmentError(c, err, "Failed to ship order")
+		}
+		shippedOrder, err := h.orderRepo.FindByID(order.ID)
+		if err != nil {
+			return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+		}
+		return c.JSON(shippedOrder)
+	}
 
-	updatedOrder, err := h.orderRepo.Update(&order)
+	// Perubahan lain lewat mesin status: pembatalan melepas stok, kupon, dan saldo yang ditahan serta
+	// mengembalikan dana pesanan yang sudah dibayar, pesanan selesai memberikan poin loyalti kepada pembeli
+	change := service.OrderStatusChange{Actor: model.OrderActorAdmin, ActorID: &adminID, Note: req.Note}
+	updatedOrder, err := h.orderService.UpdateStatus(order.ID, req.Status, change)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrderTransition) || errors.Is(err, service.ErrRefundExceedsPaid) ||
+			errors.Is(err, service.ErrGiftCardOrderRedeemed) {
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		}
 		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update order status")
 	}
 	return c.JSON(updatedOrder)
 }
+
+// AdminCancelOrderItems membatalkan buku fisik yang belum dikirim dari pesanan lunas, misalnya
+// karena stoknya terlambat datang. Stok dikembalikan dan nilainya dikembalikan ke pembeli.
+func (h *AdminHandler) AdminCancelOrderItems(c *fiber.Ctx) error {
+	orderID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(service.CancelOrderItemsRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	adminClaims := c.Locals("user").(jwt.MapClaims)
+	adminID, _ := uuid.Parse(adminClaims["user_id"].(string))
+
+	order, err := h.orderService.CancelItems(adminID, orderID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
+		case errors.Is(err, service.ErrOrderItemsNotCancellable), errors.Is(err, service.ErrShipmentNothingToShip),
+			errors.Is(err, service.ErrInvalidOrderTransition), errors.Is(err, service.ErrRefundExceedsPaid),
+			errors.Is(err, service.ErrGiftCardOrderRedeemed):
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrOrderItemNotCancellable), errors.Is(err, service.ErrOrderItemCancelExceeded),
+			errors.Is(err, service.ErrInvalidRefundMethod):
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to cancel order items")
+	}
+	return c.JSON(order)
+}
+
+// AdminGetOrderHistory menampilkan riwayat perubahan status sebuah pesanan: siapa, kapan,
+// dari status apa ke status apa, dan catatannya.
+func (h *AdminHandler) AdminGetOrderHistory(c *fiber.Ctx) error {
+	order, err := h.findOrder(c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	history, err := h.orderRepo.FindStatusHistory(order.ID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch order history")
+	}
+	return c.JSON(history)
+}
+
+// shipmentError menerjemahkan error dari ShipmentService ke respons HTTP.
+func shipmentError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.GenericError(c, fiber.StatusNotFound, "Shipment not found")
+	case errors.Is(err, service.ErrOrderNotShippable), errors.Is(err, service.ErrShipmentAlreadyFinal),
+		errors.Is(err, service.ErrShipmentNothingToShip), errors.Is(err, service.ErrInvalidOrderTransition):
+		return utils.GenericError(c, fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrShipmentCourierRequired), errors.Is(err, service.ErrInvalidShipmentStatus),
+		errors.Is(err, service.ErrShipmentItemInvalid), errors.Is(err, service.ErrShipmentQuantityExceeded):
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+	return utils.GenericError(c, fiber.StatusInternalServerError, fallback)
+}
+
+// AdminGetOrderShipments menampilkan pengiriman sebuah pesanan beserta linimasa pelacakannya.
+func (h *AdminHandler) AdminGetOrderShipments(c *fiber.Ctx) error {
+	order, err := h.findOrder(c.Params("id"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	shipments, err := h.shipmentService.GetForOrder(order.ID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch shipments")
+	}
+	return c.JSON(shipments)
+}
+
+// AdminAddShipmentEvent mencatat event pelacakan secara manual, misalnya untuk kurir tanpa API
+// pelacakan. Event delivered menyelesaikan pesanan.
+func (h *AdminHandler) AdminAddShipmentEvent(c *fiber.Ctx) error {
+	shipmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	req := new(service.ShipmentEventRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	shipment, err := h.shipmentService.AddEvent(shipmentID, req)
+	if err != nil {
+		return shipmentError(c, err, "Failed to add tracking event")
+	}
+	return c.Status(fiber.StatusCreated).JSON(shipment)
+}
+
+// =====================================================================
+// MANAJEMEN KUPON UNTUK ADMIN
+// =====================================================================
+
+// CouponRequest adalah body untuk membuat atau mengubah kupon.
+type CouponRequest struct {
+	Code         string      `json:"code" validate:"required,min=3,max=32,alphanum"`
+	Description  string      `json:"description" validate:"omitempty,max=255"`
+	Type         string      `json:"type" validate:"required,oneof=percentage fixed free_shipping"`
+	Value        float64     `json:"value" validate:"gte=0"`
+	MaxDiscount  float64     `json:"max_discount" validate:"gte=0"`
+	MinSpend     float64     `json:"min_spend" validate:"gte=0"`
+	UsageLimit   int         `json:"usage_limit" validate:"gte=0"`
+	PerUserLimit int         `json:"per_user_limit" validate:"gte=0"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	IsActive     *bool       `json:"is_active"`
+	CategoryIDs  []uuid.UUID `json:"category_ids"`
+	BookIDs      []uuid.UUID `json:"book_ids"`
+}
+
+func (h *AdminHandler) AdminGetCoupons(c *fiber.Ctx) error {
+	coupons, err := h.couponRepo.FindAll()
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch coupons")
+	}
+	return c.JSON(coupons)
+}
+
+func (h *AdminHandler) AdminGetCoupon(c *fiber.Ctx) error {
+	couponID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	coupon, err := h.couponRepo.FindByID(couponID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Coupon not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(coupon)
+}
+
+func (h *AdminHandler) AdminCreateCoupon(c *fiber.Ctx) error {
+	req := new(CouponRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	var coupon model.Coupon
+	if err := h.applyCouponRequest(req, &coupon); err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	if err := h.couponRepo.Create(&coupon); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create coupon")
+	}
+	return c.Status(fiber.StatusCreated).JSON(coupon)
+}
+
+func (h *AdminHandler) AdminUpdateCoupon(c *fiber.Ctx) error {
+	couponID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(CouponRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	coupon, err := h.couponRepo.FindByID(couponID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Coupon not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if err := h.applyCouponRequest(req, &coupon); err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	if err := h.couponRepo.Update(&coupon); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update coupon")
+	}
+	return c.JSON(coupon)
+}
+
+func (h *AdminHandler) AdminDeleteCoupon(c *fiber.Ctx) error {
+	couponID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	coupon, err := h.couponRepo.FindByID(couponID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Coupon not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	if err := h.couponRepo.Delete(&coupon); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to delete coupon")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AdminGetCouponStats menampilkan ringkasan pemakaian sebuah kupon.
+func (h *AdminHandler) AdminGetCouponStats(c *fiber.Ctx) error {
+	couponID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	coupon, err := h.couponRepo.FindByID(couponID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Coupon not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	stats, err := h.couponRepo.Stats(coupon.ID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch coupon stats")
+	}
+	return c.JSON(fiber.Map{
+		"coupon":      coupon,
+		"used_count":  coupon.UsedCount,
+		"usage_limit": coupon.UsageLimit,
+		"stats":       stats,
+	})
+}
+
+// applyCouponRequest memeriksa nilai, periode, dan keunikan kode kupon lalu menyalin request ke model.
+// Kode yang sudah dipakai kupon lain ditolak dengan 409, pelanggaran aturan lainnya dengan 400.
+func (h *AdminHandler) applyCouponRequest(req *CouponRequest, coupon *model.Coupon) error {
+	if req.Type == model.CouponTypePercentage && (req.Value <= 0 || req.Value > 100) {
+		return fiber.NewError(fiber.StatusBadRequest, "Percentage value must be between 0 and 100")
+	}
+	if req.Type == model.CouponTypeFixed && req.Value <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Fixed value must be greater than 0")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return fiber.NewError(fiber.StatusBadRequest, "ends_at must be after starts_at")
+	}
+
+	code := repository.NormalizeCouponCode(req.Code)
+	exists, err := h.couponRepo.IsCodeExist(code, coupon.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Database error")
+	}
+	if exists {
+		return fiber.NewError(fiber.StatusConflict, "Coupon code already exists")
+	}
+
+	categories := make([]model.Category, 0, len(req.CategoryIDs))
+	for _, id := range req.CategoryIDs {
+		if _, err := h.categoryRepo.FindByID(id.String()); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Category not found: "+id.String())
+		}
+		categories = append(categories, model.Category{Basemodel: model.Basemodel{ID: id}})
+	}
+	books := make([]model.Book, 0, len(req.BookIDs))
+	for _, id := range req.BookIDs {
+		book, err := h.bookRepo.FindByID(id)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Book not found: "+id.String())
+		}
+		books = append(books, book)
+	}
+
+	coupon.Code = code
+	coupon.Description = req.Description
+	coupon.Type = req.Type
+	coupon.Value = req.Value
+	coupon.MaxDiscount = req.MaxDiscount
+	coupon.MinSpend = req.MinSpend
+	coupon.UsageLimit = req.UsageLimit
+	coupon.PerUserLimit = req.PerUserLimit
+	coupon.StartsAt = req.StartsAt
+	coupon.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		coupon.IsActive = *req.IsActive
+	} else if coupon.ID == uuid.Nil {
+		coupon.IsActive = true
+	}
+	coupon.Categories = categories
+	coupon.Books = books
+	return nil
+}
+
+// =====================================================================
+// MANAJEMEN FLASH SALE UNTUK ADMIN
+// =====================================================================
+
+// FlashSaleItemRequest adalah satu buku beserta harga flash sale-nya.
+type FlashSaleItemRequest struct {
+	BookID           uuid.UUID `json:"book_id" validate:"required"`
+	SalePrice        float64   `json:"sale_price" validate:"required,gt=0"`
+	Quota            int       `json:"quota" validate:"gte=0"`
+	PerCustomerLimit int       `json:"per_customer_limit" validate:"gte=0"`
+}
+
+// FlashSaleRequest adalah body untuk membuat atau mengubah flash sale.
+type FlashSaleRequest struct {
+	Name        string                 `json:"name" validate:"required,max=100"`
+	Description string                 `json:"description" validate:"omitempty,max=500"`
+	StartsAt    time.Time              `json:"starts_at" validate:"required"`
+	EndsAt      time.Time              `json:"ends_at" validate:"required"`
+	IsActive    *bool                  `json:"is_active"`
+	Items       []FlashSaleItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+func (h *AdminHandler) AdminGetFlashSales(c *fiber.Ctx) error {
+	sales, err := h.flashSaleRepo.FindAll()
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch flash sales")
+	}
+	return c.JSON(sales)
+}
+
+func (h *AdminHandler) AdminGetFlashSale(c *fiber.Ctx) error {
+	saleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	sale, err := h.flashSaleRepo.FindByID(saleID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Flash sale not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(sale)
+}
+
+func (h *AdminHandler) AdminCreateFlashSale(c *fiber.Ctx) error {
+	req := new(FlashSaleRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	items, err := h.buildFlashSaleItems(req)
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	sale := model.FlashSale{
+		Name:        req.Name,
+		Description: req.Description,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+		Items:       items,
+	}
+	if err := h.flashSaleRepo.Create(&sale); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create flash sale")
+	}
+
+	created, err := h.flashSaleRepo.FindByID(sale.ID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+func (h *AdminHandler) AdminUpdateFlashSale(c *fiber.Ctx) error {
+	saleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(FlashSaleRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	sale, err := h.flashSaleRepo.FindByID(saleID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Flash sale not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	items, err := h.buildFlashSaleItems(req)
+	if err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	sale.Name = req.Name
+	sale.Description = req.Description
+	sale.StartsAt = req.StartsAt
+	sale.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		sale.IsActive = *req.IsActive
+	}
+	if err := h.flashSaleRepo.Update(&sale); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update flash sale")
+	}
+	if err := h.flashSaleRepo.ReplaceItems(sale.ID, items); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update flash sale items")
+	}
+
+	updated, err := h.flashSaleRepo.FindByID(sale.ID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(updated)
+}
+
+func (h *AdminHandler) AdminDeleteFlashSale(c *fiber.Ctx) error {
+	saleID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	sale, err := h.flashSaleRepo.FindByID(saleID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Flash sale not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	if err := h.flashSaleRepo.Delete(&sale); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to delete flash sale")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// buildFlashSaleItems memeriksa jadwal dan setiap buku dalam request flash sale. Setiap buku hanya
+// boleh muncul sekali dan harga flash sale-nya harus di bawah harga normal.
+func (h *AdminHandler) buildFlashSaleItems(req *FlashSaleRequest) ([]model.FlashSaleItem, error) {
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "ends_at must be after starts_at")
+	}
+
+	items := make([]model.FlashSaleItem, 0, len(req.Items))
+	seen := make(map[uuid.UUID]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.BookID] {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Each book can only appear once in a flash sale")
+		}
+		seen[item.BookID] = true
+
+		book, err := h.bookRepo.FindByID(item.BookID)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Book not found: "+item.BookID.String())
+		}
+		if item.SalePrice >= book.Price {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Sale price for "+book.Title+" must be lower than its regular price")
+		}
+		if book.IsDigital() && item.PerCustomerLimit > 1 {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "Ebooks can only be purchased once per customer")
+		}
+
+		items = append(items, model.FlashSaleItem{
+			BookID:           item.BookID,
+			SalePrice:        item.SalePrice,
+			Quota:            item.Quota,
+			PerCustomerLimit: item.PerCustomerLimit,
+		})
+	}
+	return items, nil
+}
+
+// =====================================================================
+// MANAJEMEN PROMOSI UNTUK ADMIN
+// =====================================================================
+
+// PromotionRequest adalah body untuk membuat atau mengubah promosi.
+type PromotionRequest struct {
+	Name        string      `json:"name" validate:"required,max=100"`
+	Description string      `json:"description" validate:"omitempty,max=500"`
+	Type        string      `json:"type" validate:"required,oneof=buy_x_cheapest_free multi_buy bundle"`
+	Quantity    int         `json:"quantity" validate:"gte=0"`
+	Price       float64     `json:"price" validate:"gte=0"`
+	StartsAt    *time.Time  `json:"starts_at"`
+	EndsAt      *time.Time  `json:"ends_at"`
+	IsActive    *bool       `json:"is_active"`
+	CategoryIDs []uuid.UUID `json:"category_ids"`
+	BookIDs     []uuid.UUID `json:"book_ids"`
+}
+
+func (h *AdminHandler) AdminGetPromotions(c *fiber.Ctx) error {
+	promotions, err := h.promotionRepo.FindAll()
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch promotions")
+	}
+	return c.JSON(promotions)
+}
+
+func (h *AdminHandler) AdminGetPromotion(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	promotion, err := h.promotionRepo.FindByID(promotionID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Promotion not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	return c.JSON(promotion)
+}
+
+func (h *AdminHandler) AdminCreatePromotion(c *fiber.Ctx) error {
+	req := new(PromotionRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	var promotion model.Promotion
+	if err := h.applyPromotionRequest(req, &promotion); err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	if err := h.promotionRepo.Create(&promotion); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create promotion")
+	}
+	return c.Status(fiber.StatusCreated).JSON(promotion)
+}
+
+func (h *AdminHandler) AdminUpdatePromotion(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(PromotionRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	promotion, err := h.promotionRepo.FindByID(promotionID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Promotion not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if err := h.applyPromotionRequest(req, &promotion); err != nil {
+		fe := err.(*fiber.Error)
+		return utils.GenericError(c, fe.Code, fe.Message)
+	}
+
+	if err := h.promotionRepo.Update(&promotion); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to update promotion")
+	}
+	return c.JSON(promotion)
+}
+
+func (h *AdminHandler) AdminDeletePromotion(c *fiber.Ctx) error {
+	promotionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	promotion, err := h.promotionRepo.FindByID(promotionID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Promotion not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	if err := h.promotionRepo.Delete(&promotion); err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to delete promotion")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// applyPromotionRequest memeriksa aturan tiap jenis promosi lalu menyalin request ke model.
+func (h *AdminHandler) applyPromotionRequest(req *PromotionRequest, promotion *model.Promotion) error {
+	switch req.Type {
+	case model.PromotionTypeBuyXCheapestFree:
+		if req.Quantity < 2 {
+			return fiber.NewError(fiber.StatusBadRequest, "quantity must be at least 2")
+		}
+	case model.PromotionTypeMultiBuy:
+		if req.Quantity < 2 || req.Price <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "quantity must be at least 2 and price must be greater than 0")
+		}
+	case model.PromotionTypeBundle:
+		if len(req.BookIDs) < 2 || req.Price <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "A bundle needs at least 2 books and a price greater than 0")
+		}
+		if len(req.CategoryIDs) > 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "A bundle is made of books, not categories")
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return fiber.NewError(fiber.StatusBadRequest, "ends_at must be after starts_at")
+	}
+
+	categories := make([]model.Category, 0, len(req.CategoryIDs))
+	for _, id := range req.CategoryIDs {
+		if _, err := h.categoryRepo.FindByID(id.String()); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Category not found: "+id.String())
+		}
+		categories = append(categories, model.Category{Basemodel: model.Basemodel{ID: id}})
+	}
+	books := make([]model.Book, 0, len(req.BookIDs))
+	seen := make(map[uuid.UUID]bool, len(req.BookIDs))
+	for _, id := range req.BookIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		book, err := h.bookRepo.FindByID(id)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Book not found: "+id.String())
+		}
+		books = append(books, book)
+	}
+
+	promotion.Name = req.Name
+	promotion.Description = req.Description
+	promotion.Type = req.Type
+	promotion.Quantity = req.Quantity
+	promotion.Price = req.Price
+	promotion.StartsAt = req.StartsAt
+	promotion.EndsAt = req.EndsAt
+	if req.IsActive != nil {
+		promotion.IsActive = *req.IsActive
+	} else if promotion.ID == uuid.Nil {
+		promotion.IsActive = true
+	}
+	promotion.Categories = categories
+	promotion.Books = books
+	return nil
+}
+
+// =====================================================================
+// LAPORAN UNTUK ADMIN
+// =====================================================================
+
+// reportRange membaca rentang tanggal laporan dari ?from=&to= (format YYYY-MM-DD, keduanya inklusif).
+// Default-nya bulan berjalan.
+func reportRange(c *fiber.Ctx) (from, to time.Time, err error) {
+	now := time.Now()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to = from.AddDate(0, 1, -1)
+
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			return from, to, errors.New("Invalid from date, use YYYY-MM-DD")
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			return from, to, errors.New("Invalid to date, use YYYY-MM-DD")
+		}
+	}
+	if to.Before(from) {
+		return from, to, errors.New("to must not be before from")
+	}
+	return from, to, nil
+}
+
+// AdminGetTaxReport merangkum PPN dari pesanan yang dibayar dalam rentang tanggal ?from=&to=
+// (format YYYY-MM-DD, keduanya inklusif). Default-nya bulan berjalan.
+func (h *AdminHandler) AdminGetTaxReport(c *fiber.Ctx) error {
+	from, to, err := reportRange(c)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	rows, err := h.orderRepo.TaxSummary(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not build tax report")
+	}
+
+	var netSales, taxBase, taxAmount float64
+	for _, row := range rows {
+		netSales += row.NetSales
+		taxBase += row.TaxBase
+		taxAmount += row.TaxAmount
+	}
+	return c.JSON(fiber.Map{
+		"from":            from.Format("2006-01-02"),
+		"to":              to.Format("2006-01-02"),
+		"rows":            rows,
+		"total_net_sales": netSales,
+		"total_tax_base":  taxBase,
+		"total_tax":       taxAmount,
+	})
+}
@@ -1,31 +1,31 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 	"ngabaca/config"
 	"ngabaca/internal/model"
 	"ngabaca/internal/repository"
 	"ngabaca/internal/service"
 	"ngabaca/internal/utils"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/midtrans/midtrans-go"
-	"github.com/midtrans/midtrans-go/snap"
 	"gorm.io/gorm"
 )
 
 // CustomerHandler menampung semua dependency yang dibutuhkan untuk fitur-fitur pelanggan.
 type CustomerHandler struct {
-	orderRepo    repository.OrderRepository
-	userRepo     repository.UserRepository
-	orderService service.OrderService
-	reviewRepo   repository.ReviewRepository
-	wishlistRepo repository.WishlistRepository
-	cartRepo     repository.CartRepository
-	cfg          config.Config
+	orderRepo          repository.OrderRepository
+	userRepo           repository.UserRepository
+	orderService       service.OrderService
+	reviewRepo         repository.ReviewRepository
+	wishlistRepo       repository.WishlistRepository
+	cartRepo           repository.CartRepository
+	cartService        service.CartService
+	reservationService service.ReservationService
+	invoiceService     service.InvoiceService
+	cfg                config.Config
 }
 
 // NewCustomerHandler adalah constructor untuk CustomerHandler.
@@ -36,16 +36,22 @@ func NewCustomerHandler(
 	reviewRepo repository.ReviewRepository,
 	wishlistRepo repository.WishlistRepository,
 	cartRepo repository.CartRepository,
+	cartService service.CartService,
+	reservationService service.ReservationService,
+	invoiceService service.InvoiceService,
 	cfg config.Config,
 ) *CustomerHandler {
 	return &CustomerHandler{
-		orderRepo:    orderRepo,
-		userRepo:     userRepo,
-		reviewRepo:   reviewRepo,
-		orderService: orderService,
-		wishlistRepo: wishlistRepo,
-		cartRepo:     cartRepo,
-		cfg:          cfg,
+		orderRepo:          orderRepo,
+		userRepo:           userRepo,
+		reviewRepo:         reviewRepo,
+		orderService:       orderService,
+		wishlistRepo:       wishlistRepo,
+		cartRepo:           cartRepo,
+		cartService:        cartService,
+		reservationService: reservationService,
+		invoiceService:     invoiceService,
+		cfg:                cfg,
 	}
 }
 
@@ -59,7 +65,7 @@ func (h *CustomerHandler) GetCustomerOrders(c *fiber.Ctx) error {
 	userClaims := c.Locals("user").(jwt.MapClaims)
 	userID, _ := uuid.Parse(userClaims["user_id"].(string))
 
-	orders, err := h.orderRepo.FindByUserID(userID)
+	orders, err := h.orderRepo.FindByUserID(userID, c.Query("q"))
 	if err != nil {
 		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch orders")
 	}
@@ -68,12 +74,12 @@ func (h *CustomerHandler) GetCustomerOrders(c *fiber.Ctx) error {
 }
 
 // GetCustomerOrderDetail mengambil detail satu pesanan spesifik milik pengguna yang sedang login.
+// Pesanan bisa dicari dengan ID maupun nomor pesanannya.
 func (h *CustomerHandler) GetCustomerOrderDetail(c *fiber.Ctx) error {
 	userClaims := c.Locals("user").(jwt.MapClaims)
 	userID, _ := uuid.Parse(userClaims["user_id"].(string))
-	orderID, _ := uuid.Parse(c.Params("id"))
 
-	order, err := h.orderRepo.FindByIDAndUserID(orderID, userID)
+	order, err := h.findOrder(c.Params("id"), userID)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return utils.GenericError(c, fiber.StatusNotFound, "Order not found or you don't have permission to view it")
@@ -84,6 +90,94 @@ func (h *CustomerHandler) GetCustomerOrderDetail(c *fiber.Ctx) error {
 	return c.JSON(order)
 }
 
+// GetCustomerOrderInvoice mengunduh faktur PDF pesanan milik pengguna yang sedang login.
+func (h *CustomerHandler) GetCustomerOrderInvoice(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	order, err := h.findOrder(c.Params("id"), userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found or you don't have permission to view it")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	return sendInvoicePDF(c, h.invoiceService, order)
+}
+
+// CancelOrderRequest adalah body untuk membatalkan pesanan.
+type CancelOrderRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// CancelCustomerOrder membatalkan pesanan milik pengguna yang sedang login. Hanya pesanan yang belum
+// dibayar, atau sudah dibayar tetapi belum dikirim, yang bisa dibatalkan.
+func (h *CustomerHandler) CancelCustomerOrder(c *fiber.Ctx) error {
+	req := new(CancelOrderRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	order, err := h.findOrder(c.Params("id"), userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found or you don't have permission to view it")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	cancelled, err := h.orderService.CancelOrderByCustomer(userID, order.ID, req.Reason)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotCancellable) || errors.Is(err, service.ErrGiftCardOrderRedeemed) {
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to cancel order")
+	}
+
+	return c.JSON(fiber.Map{"message": "Order cancelled", "order": cancelled})
+}
+
+// findOrder mencari pesanan milik pengguna dengan ID maupun nomor pesanannya.
+func (h *CustomerHandler) findOrder(ref string, userID uuid.UUID) (model.Order, error) {
+	return findUserOrder(h.orderRepo, ref, userID)
+}
+
+// findUserOrder mencari pesanan milik pengguna dengan ID maupun nomor pesanannya.
+func findUserOrder(orderRepo repository.OrderRepository, ref string, userID uuid.UUID) (model.Order, error) {
+	if orderID, err := uuid.Parse(ref); err == nil {
+		return orderRepo.FindByIDAndUserID(orderID, userID)
+	}
+	order, err := orderRepo.FindByOrderNumber(ref)
+	if err == nil && order.UserID != userID {
+		err = gorm.ErrRecordNotFound
+	}
+	return order, err
+}
+
+// sendInvoicePDF mengirim faktur pesanan sebagai file PDF.
+func sendInvoicePDF(c *fiber.Ctx, invoiceService service.InvoiceService, order model.Order) error {
+	pdf, err := invoiceService.GetPDF(order.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvoiceNotAvailable) {
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to generate invoice")
+	}
+
+	fileName := model.FormatInvoiceNumber(utils.DefaultString(order.OrderNumber, order.ID.String())) + ".pdf"
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+fileName+`"`)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Send(pdf)
+}
+
 // Checkout memproses permintaan checkout dari pengguna.
 func (h *CustomerHandler) Checkout(c *fiber.Ctx) error {
 	// 1. Parse dan validasi request body menggunakan struct dari paket service.
@@ -99,33 +193,38 @@ func (h *CustomerHandler) Checkout(c *fiber.Ctx) error {
 	userClaims := c.Locals("user").(jwt.MapClaims)
 	userID, _ := uuid.Parse(userClaims["user_id"].(string))
 
-	// 3. Panggil "Kepala Koki" (OrderService) untuk memproses semua logika bisnis yang kompleks.
-	order, totalPrice, err := h.orderService.CreateOrder(userID, req)
-	if err != nil {
-		// Error dari service bisa jadi karena stok tidak cukup, dll.
-		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	// 3. Checkout yang diulang dengan Idempotency-Key yang sama memakai pesanan yang sudah dibuat,
+	// misalnya karena respons sebelumnya tidak sampai. Sesi pembayaran yang sudah ada dikembalikan,
+	// atau dibuat jika pembuatannya sebelumnya gagal.
+	var order *model.Order
+	if key := c.Get("Idempotency-Key"); key != "" {
+		if existing, err := h.orderRepo.FindByCheckoutKey(userID, key); err == nil {
+			if existing.Status != model.OrderStatusPending || existing.AmountDue() <= 0 {
+				return c.JSON(fiber.Map{"message": "Order is no longer awaiting payment", "order": existing})
+			}
+			order = &existing
+		}
+		req.CheckoutKey = key
 	}
 
-	// 4. Siapkan dan panggil Midtrans Snap (interaksi dengan layanan eksternal).
-	var s = snap.Client{}
-	s.New(h.cfg.MidtransServerKey, midtrans.Sandbox)
-
-	// Ambil detail pengguna untuk Midtrans
-	user, _ := h.userRepo.FindByID(userID)
+	// Panggil "Kepala Koki" (OrderService) untuk memproses semua logika bisnis yang kompleks.
+	if order == nil {
+		var err error
+		order, _, err = h.orderService.CreateOrder(userID, req)
+		if err != nil {
+			// Error dari service bisa jadi karena stok tidak cukup, dll.
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+		}
+	}
 
-	snapReq := &snap.Request{
-		TransactionDetails: midtrans.TransactionDetails{
-			OrderID:  fmt.Sprintf("NGABACA-%s-%d", order.ID.String(), time.Now().Unix()),
-			GrossAmt: int64(totalPrice),
-		},
-		CustomerDetail: &midtrans.CustomerDetails{
-			FName: user.Name,
-			Email: user.Email,
-			Phone: user.PhoneNumber,
-		},
+	// Pesanan yang lunas dengan saldo dompet tidak perlu sesi pembayaran
+	if order.AmountDue() <= 0 {
+		return c.JSON(fiber.Map{"message": "Order paid with wallet balance", "order": order})
 	}
 
-	snapRes, errSnap := s.CreateTransaction(snapReq)
+	// 4. Buat sesi pembayaran di Midtrans Snap (interaksi dengan layanan eksternal).
+	user, _ := h.userRepo.FindByID(userID)
+	snapRes, errSnap := h.orderService.StartPayment(order, user)
 	if errSnap != nil {
 		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create Midtrans transaction")
 	}
@@ -135,6 +234,27 @@ func (h *CustomerHandler) Checkout(c *fiber.Ctx) error {
 
 }
 
+// PreviewCheckout menghitung rincian harga (subtotal, potongan, pajak, ongkos kirim, dan total)
+// untuk item atau keranjang tanpa membuat pesanan.
+func (h *CustomerHandler) PreviewCheckout(c *fiber.Ctx) error {
+	req := new(service.CreateOrderRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	quote, err := h.orderService.PreviewOrder(userID, req)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+	return c.JSON(quote)
+}
+
 type CreateReviewRequest struct {
 	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
 	Comment string `json:"comment" validate:"omitempty,max=500"`
@@ -274,12 +394,13 @@ type CartResponse struct {
 }
 
 type BookResponses struct {
-	ID            uuid.UUID `json:"id"`
-	Title         string    `json:"title"`
-	Author        string    `json:"author"`
-	Price         float64   `json:"price"`
-	CoverImageURL string    `json:"cover_image_url"`
-	Stock         int       `json:"stock"`
+	ID             uuid.UUID `json:"id"`
+	Title          string    `json:"title"`
+	Author         string    `json:"author"`
+	Price          float64   `json:"price"`
+	CoverImageURL  string    `json:"cover_image_url"`
+	Stock          int       `json:"stock"`
+	AvailableStock int       `json:"available_stock"`
 }
 
 type CartItemResponse struct {
@@ -315,12 +436,13 @@ func (h *CustomerHandler) GetMyCart(c *fiber.Ctx) error {
 		book := item.Book
 
 		bookResp := BookResponses{
-			ID:            book.ID,
-			Title:         book.Title,
-			Author:        book.Author,
-			Price:         book.Price,
-			CoverImageURL: book.CoverImageURL,
-			Stock:         book.Stock,
+			ID:             book.ID,
+			Title:          book.Title,
+			Author:         book.Author,
+			Price:          book.Price,
+			CoverImageURL:  book.CoverImageURL,
+			Stock:          book.Stock,
+			AvailableStock: book.AvailableStock,
 		}
 
 		itemResp := CartItemResponse{
@@ -399,14 +521,41 @@ func (h *CustomerHandler) RemoveFromCart(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// ReserveCart menahan stok buku di keranjang untuk sementara waktu, misalnya saat pengguna
+// membuka halaman checkout. Reservasi berpindah ke pesanan ketika checkout berhasil.
+func (h *CustomerHandler) ReserveCart(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	reservations, err := h.reservationService.ReserveCart(userID)
+	if err != nil {
+		var stockErr *service.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		}
+		if err == service.ErrNothingToReserve {
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to reserve cart items")
+	}
+
+	return c.JSON(fiber.Map{
+		"reservations": reservations,
+		"expires_at":   reservations[0].ExpiresAt,
+	})
+}
+
+// SyncCartRequest berisi keranjang tamu yang akan digabungkan setelah login:
+// cart token dari keranjang tamu di server, item dari keranjang lokal klien, atau keduanya.
 type SyncCartRequest struct {
-	Items []struct {
+	CartToken string `json:"cart_token" validate:"omitempty,uuid"`
+	Items     []struct {
 		BookID   uuid.UUID `json:"book_id" validate:"required"`
 		Quantity int       `json:"quantity" validate:"required,min=1"`
-	} `json:"items" validate:"required,dive"`
+	} `json:"items" validate:"omitempty,dive"`
 }
 
-// Method baru untuk sinkronisasi keranjang
+// SyncCart menggabungkan keranjang tamu ke keranjang pengguna yang baru login.
 func (h *CustomerHandler) SyncCart(c *fiber.Ctx) error {
 	req := new(SyncCartRequest)
 	if err := c.BodyParser(req); err != nil {
@@ -415,19 +564,136 @@ func (h *CustomerHandler) SyncCart(c *fiber.Ctx) error {
 	if errs := utils.ValidateStruct(req); errs != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
 	}
+	if req.CartToken == "" && len(req.Items) == 0 {
+		return utils.GenericError(c, fiber.StatusBadRequest, "cart_token or items is required")
+	}
 
 	userClaims := c.Locals("user").(jwt.MapClaims)
 	userID, _ := uuid.Parse(userClaims["user_id"].(string))
 
-	// Looping setiap item dari cart lokal dan tambahkan ke cart di database
-	// Repository AddItem kita sudah pintar menangani item yang sudah ada (akan menambah quantity)
+	items := make([]repository.GuestCartItem, 0, len(req.Items))
 	for _, item := range req.Items {
-		err := h.cartRepo.AddItem(userID, item.BookID, item.Quantity)
-		if err != nil {
-			// Lanjutkan meski ada error di satu item, atau bisa juga dibatalkan semua
-			fmt.Printf("Warning: could not sync item %s for user %s: %v\n", item.BookID, userID, err)
+		items = append(items, repository.GuestCartItem{BookID: item.BookID, Quantity: item.Quantity})
+	}
+
+	result, err := h.cartService.MergeIntoUserCart(userID, req.CartToken, items)
+	if err != nil {
+		if err == service.ErrInvalidCartToken {
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
 		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to synchronize cart")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Cart synchronized successfully",
+		"result":  result,
+	})
+}
+
+// ========== KERANJANG TAMU ==========
+
+// guestCartTokenHeader adalah header yang membawa cart token untuk pengunjung yang belum login.
+const guestCartTokenHeader = "X-Cart-Token"
+
+// GuestCartResponse adalah format keranjang tamu.
+type GuestCartResponse struct {
+	Token string                  `json:"token"`
+	Items []GuestCartItemResponse `json:"items"`
+}
+
+type GuestCartItemResponse struct {
+	Quantity int           `json:"quantity"`
+	Book     BookResponses `json:"book"`
+}
+
+// guestCartError memetakan error CartService ke response HTTP.
+func guestCartError(c *fiber.Ctx, err error, fallback string) error {
+	switch err {
+	case service.ErrInvalidCartToken:
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	case service.ErrCartBookNotFound, service.ErrCartItemNotFound:
+		return utils.GenericError(c, fiber.StatusNotFound, err.Error())
+	case repository.ErrGuestCartBusy:
+		return utils.GenericError(c, fiber.StatusConflict, err.Error())
+	}
+	return utils.GenericError(c, fiber.StatusInternalServerError, fallback)
+}
+
+// GetGuestCart menampilkan isi keranjang tamu berdasarkan cart token.
+func (h *CustomerHandler) GetGuestCart(c *fiber.Ctx) error {
+	token := c.Get(guestCartTokenHeader)
+	lines, err := h.cartService.GetGuestCart(token)
+	if err != nil {
+		return guestCartError(c, err, "Could not fetch cart")
+	}
+
+	response := GuestCartResponse{Token: token, Items: []GuestCartItemResponse{}}
+	for _, line := range lines {
+		response.Items = append(response.Items, GuestCartItemResponse{
+			Quantity: line.Quantity,
+			Book: BookResponses{
+				ID:             line.Book.ID,
+				Title:          line.Book.Title,
+				Author:         line.Book.Author,
+				Price:          line.Book.Price,
+				CoverImageURL:  line.Book.CoverImageURL,
+				Stock:          line.Book.Stock,
+				AvailableStock: line.Book.AvailableStock,
+			},
+		})
+	}
+	return c.JSON(response)
+}
+
+// AddToGuestCart menambahkan buku ke keranjang tamu. Token baru diterbitkan jika belum ada.
+func (h *CustomerHandler) AddToGuestCart(c *fiber.Ctx) error {
+	req := new(AddToCartRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	token, err := h.cartService.AddGuestItem(c.Get(guestCartTokenHeader), req.BookID, req.Quantity)
+	if err != nil {
+		return guestCartError(c, err, "Failed to add item to cart")
+	}
+
+	c.Set(guestCartTokenHeader, token)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Item added to cart successfully", "token": token})
+}
+
+// UpdateGuestCartItem mengubah kuantitas buku di keranjang tamu.
+func (h *CustomerHandler) UpdateGuestCartItem(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
 	}
+	req := new(AddToCartRequest)
+	req.BookID = bookID
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	if err := h.cartService.UpdateGuestItem(c.Get(guestCartTokenHeader), bookID, req.Quantity); err != nil {
+		return guestCartError(c, err, "Failed to update cart item")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Cart item updated successfully"})
+}
 
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Cart synchronized successfully"})
+// RemoveFromGuestCart menghapus buku dari keranjang tamu.
+func (h *CustomerHandler) RemoveFromGuestCart(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	if err := h.cartService.RemoveGuestItem(c.Get(guestCartTokenHeader), bookID); err != nil {
+		return guestCartError(c, err, "Failed to remove item from cart")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -0,0 +1,431 @@
+package handler
+
+import (
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
+	"ngabaca/internal/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// LibraryHandler menampung dependency untuk fitur perpustakaan ebook pengguna.
+type LibraryHandler struct {
+	libraryService service.LibraryService
+	readingService service.ReadingService
+	orderService   service.OrderService
+	userRepo       repository.UserRepository
+}
+
+// NewLibraryHandler adalah constructor untuk LibraryHandler.
+func NewLibraryHandler(
+	libraryService service.LibraryService,
+	readingService service.ReadingService,
+	orderService service.OrderService,
+	userRepo repository.UserRepository,
+) *LibraryHandler {
+	return &LibraryHandler{
+		libraryService: libraryService,
+		readingService: readingService,
+		orderService:   orderService,
+		userRepo:       userRepo,
+	}
+}
+
+// LibraryItemResponse adalah format satu ebook di perpustakaan pengguna.
+type LibraryItemResponse struct {
+	EntitlementID      uuid.UUID  `json:"entitlement_id"`
+	BookID             uuid.UUID  `json:"book_id"`
+	Title              string     `json:"title"`
+	Slug               string     `json:"slug"`
+	Author             string     `json:"author"`
+	CoverImageURL      string     `json:"cover_image_url"`
+	Type               string     `json:"type"`
+	Status             string     `json:"status"`
+	DownloadCount      int        `json:"download_count"`
+	RemainingDownloads int        `json:"remaining_downloads"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	PurchasedAt        time.Time  `json:"purchased_at"`
+}
+
+// GetMyLibrary menampilkan semua ebook yang sudah dibeli pengguna.
+func (h *LibraryHandler) GetMyLibrary(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	entitlements, err := h.libraryService.GetLibrary(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch library")
+	}
+
+	response := make([]LibraryItemResponse, 0, len(entitlements))
+	for _, e := range entitlements {
+		response = append(response, LibraryItemResponse{
+			EntitlementID:      e.ID,
+			BookID:             e.BookID,
+			Title:              e.Book.Title,
+			Slug:               e.Book.Slug,
+			Author:             e.Book.Author,
+			CoverImageURL:      e.Book.CoverImageURL,
+			Type:               e.Type,
+			Status:             e.Status,
+			DownloadCount:      e.DownloadCount,
+			RemainingDownloads: e.RemainingDownloads(),
+			ExpiresAt:          e.ExpiresAt,
+			PurchasedAt:        e.CreatedAt,
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// CreateDownloadLink menerbitkan URL unduhan bertanda tangan untuk ebook milik pengguna.
+func (h *LibraryHandler) CreateDownloadLink(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	link, err := h.libraryService.CreateDownloadLink(userID, bookID)
+	if err != nil {
+		switch err {
+		case service.ErrNotEntitled:
+			return utils.GenericError(c, fiber.StatusForbidden, err.Error())
+		case service.ErrDownloadLimit:
+			return utils.GenericError(c, fiber.StatusTooManyRequests, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create download link")
+	}
+
+	return c.JSON(link)
+}
+
+// Download mengalirkan file ebook privat berdasarkan URL bertanda tangan.
+func (h *LibraryHandler) Download(c *fiber.Ctx) error {
+	entitlementID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid download link")
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid download link")
+	}
+
+	file, err := h.libraryService.OpenDownload(entitlementID, expires, c.Query("signature"), c.IP(), c.Get(fiber.HeaderUserAgent))
+	if err != nil {
+		switch err {
+		case service.ErrInvalidSignature, service.ErrNotEntitled:
+			return utils.GenericError(c, fiber.StatusForbidden, err.Error())
+		case service.ErrDownloadLimit:
+			return utils.GenericError(c, fiber.StatusTooManyRequests, err.Error())
+		case service.ErrEbookFileNotFound:
+			return utils.GenericError(c, fiber.StatusNotFound, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to process download")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Download(file.Path, file.FileName)
+}
+
+// ExtendRentalRequest adalah body untuk memperpanjang sewa ebook.
+type ExtendRentalRequest struct {
+	Days int `json:"days" validate:"required,oneof=7 14 30"`
+}
+
+// ExtendRental membuat pesanan perpanjangan sewa dan mengembalikan sesi pembayarannya.
+func (h *LibraryHandler) ExtendRental(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+	req := new(ExtendRentalRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	order, err := h.orderService.CreateRentalExtensionOrder(userID, bookID, req.Days)
+	if err != nil {
+		return rentalError(c, err)
+	}
+	return h.respondWithPayment(c, order)
+}
+
+// ConvertRental membuat pesanan untuk membeli ebook yang sedang disewa, dengan biaya sewa dikreditkan.
+func (h *LibraryHandler) ConvertRental(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	order, err := h.orderService.CreateRentalConversionOrder(userID, bookID)
+	if err != nil {
+		return rentalError(c, err)
+	}
+	if order.TotalPrice == 0 {
+		return c.JSON(fiber.Map{"message": "Rental converted to purchase", "order": order})
+	}
+	return h.respondWithPayment(c, order)
+}
+
+// respondWithPayment membuat sesi pembayaran untuk pesanan dan mengembalikannya ke klien.
+func (h *LibraryHandler) respondWithPayment(c *fiber.Ctx, order *model.Order) error {
+	user, _ := h.userRepo.FindByID(order.UserID)
+	snapRes, err := h.orderService.StartPayment(order, user)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to create Midtrans transaction")
+	}
+	return c.JSON(snapRes)
+}
+
+// rentalError memetakan error perpanjangan/konversi sewa ke response HTTP.
+func rentalError(c *fiber.Ctx, err error) error {
+	switch err {
+	case service.ErrRentalNotFound:
+		return utils.GenericError(c, fiber.StatusNotFound, err.Error())
+	case service.ErrRentalNotAvailable:
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+	return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to process rental")
+}
+
+// readingError memetakan error dari ReadingService ke response HTTP.
+func readingError(c *fiber.Ctx, err error, fallback string) error {
+	switch err {
+	case service.ErrNotEntitled:
+		return utils.GenericError(c, fiber.StatusForbidden, err.Error())
+	case service.ErrReadingNotFound:
+		return utils.GenericError(c, fiber.StatusNotFound, err.Error())
+	}
+	return utils.GenericError(c, fiber.StatusInternalServerError, fallback)
+}
+
+// GetProgress mengambil posisi baca terakhir untuk sebuah ebook.
+func (h *LibraryHandler) GetProgress(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	progress, err := h.readingService.GetProgress(userID, bookID)
+	if err != nil {
+		return readingError(c, err, "Could not fetch reading progress")
+	}
+	return c.JSON(progress)
+}
+
+// UpdateProgress menyimpan posisi baca. Jika server punya progres yang lebih baru,
+// progres tersebut dikembalikan dengan status 409 agar klien menyesuaikan.
+func (h *LibraryHandler) UpdateProgress(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	req := new(service.UpdateProgressRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	progress, err := h.readingService.UpdateProgress(userID, bookID, req)
+	if err == service.ErrProgressConflict {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":    err.Error(),
+			"progress": progress,
+		})
+	}
+	if err != nil {
+		return readingError(c, err, "Failed to save reading progress")
+	}
+	return c.JSON(progress)
+}
+
+// GetContinueReading menampilkan buku yang terakhir dibaca, diurutkan dari aktivitas terbaru.
+func (h *LibraryHandler) GetContinueReading(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	items, err := h.readingService.ContinueReading(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch reading activity")
+	}
+	return c.JSON(items)
+}
+
+// GetBookmarks mengambil semua bookmark pengguna pada sebuah ebook.
+func (h *LibraryHandler) GetBookmarks(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	bookmarks, err := h.readingService.GetBookmarks(userID, bookID)
+	if err != nil {
+		return readingError(c, err, "Could not fetch bookmarks")
+	}
+	return c.JSON(bookmarks)
+}
+
+// CreateBookmark menambahkan bookmark baru.
+func (h *LibraryHandler) CreateBookmark(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	req := new(service.CreateBookmarkRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	bookmark, err := h.readingService.CreateBookmark(userID, bookID, req)
+	if err != nil {
+		return readingError(c, err, "Failed to create bookmark")
+	}
+	return c.Status(fiber.StatusCreated).JSON(bookmark)
+}
+
+// DeleteBookmark menghapus sebuah bookmark.
+func (h *LibraryHandler) DeleteBookmark(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+	bookmarkID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Bookmark ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	if err := h.readingService.DeleteBookmark(userID, bookID, bookmarkID); err != nil {
+		return readingError(c, err, "Failed to delete bookmark")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetHighlights mengambil semua highlight pengguna pada sebuah ebook.
+func (h *LibraryHandler) GetHighlights(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	highlights, err := h.readingService.GetHighlights(userID, bookID)
+	if err != nil {
+		return readingError(c, err, "Could not fetch highlights")
+	}
+	return c.JSON(highlights)
+}
+
+// CreateHighlight menambahkan highlight baru.
+func (h *LibraryHandler) CreateHighlight(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+
+	req := new(service.CreateHighlightRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	highlight, err := h.readingService.CreateHighlight(userID, bookID, req)
+	if err != nil {
+		return readingError(c, err, "Failed to create highlight")
+	}
+	return c.Status(fiber.StatusCreated).JSON(highlight)
+}
+
+// UpdateHighlight mengubah catatan atau warna highlight.
+func (h *LibraryHandler) UpdateHighlight(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+	highlightID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Highlight ID format")
+	}
+
+	req := new(service.UpdateHighlightRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	highlight, err := h.readingService.UpdateHighlight(userID, bookID, highlightID, req)
+	if err != nil {
+		return readingError(c, err, "Failed to update highlight")
+	}
+	return c.JSON(highlight)
+}
+
+// DeleteHighlight menghapus sebuah highlight.
+func (h *LibraryHandler) DeleteHighlight(c *fiber.Ctx) error {
+	bookID, err := uuid.Parse(c.Params("bookId"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Book ID format")
+	}
+	highlightID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid Highlight ID format")
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	if err := h.readingService.DeleteHighlight(userID, bookID, highlightID); err != nil {
+		return readingError(c, err, "Failed to delete highlight")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
+	"ngabaca/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoyaltyHandler menampung dependency untuk program poin loyalti.
+type LoyaltyHandler struct {
+	loyaltyService service.LoyaltyService
+	userRepo       repository.UserRepository
+}
+
+// NewLoyaltyHandler adalah constructor untuk LoyaltyHandler.
+func NewLoyaltyHandler(loyaltyService service.LoyaltyService, userRepo repository.UserRepository) *LoyaltyHandler {
+	return &LoyaltyHandler{
+		loyaltyService: loyaltyService,
+		userRepo:       userRepo,
+	}
+}
+
+// GetMyPoints menampilkan saldo poin, tingkat keanggotaan, dan mutasi terakhir pengguna.
+func (h *LoyaltyHandler) GetMyPoints(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	summary, err := h.loyaltyService.GetSummary(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch loyalty points")
+	}
+	return c.JSON(summary)
+}
+
+// GetMyPointsHistory menampilkan riwayat perolehan, penukaran, dan hangusnya poin pengguna.
+func (h *LoyaltyHandler) GetMyPointsHistory(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	entries, err := h.loyaltyService.GetHistory(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch points history")
+	}
+	return c.JSON(entries)
+}
+
+// AdminGetUserPoints menampilkan saldo dan riwayat poin seorang pengguna.
+func (h *LoyaltyHandler) AdminGetUserPoints(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	if _, err := h.userRepo.FindByID(userID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "User not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	summary, err := h.loyaltyService.GetSummary(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch loyalty points")
+	}
+	return c.JSON(summary)
+}
+
+// AdjustPointsRequest adalah body penyesuaian poin oleh admin. Points negatif mengurangi saldo.
+type AdjustPointsRequest struct {
+	Points int    `json:"points" validate:"required"`
+	Note   string `json:"note" validate:"required,max=255"`
+}
+
+// AdminAdjustPoints menambah atau mengurangi poin seorang pengguna. Admin yang melakukannya dicatat di riwayat.
+func (h *LoyaltyHandler) AdminAdjustPoints(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(AdjustPointsRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	if _, err := h.userRepo.FindByID(userID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "User not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	adminClaims := c.Locals("user").(jwt.MapClaims)
+	adminID, _ := uuid.Parse(adminClaims["user_id"].(string))
+
+	entry, err := h.loyaltyService.Adjust(adminID, userID, req.Points, req.Note)
+	if err != nil {
+		switch err {
+		case service.ErrInsufficientPoints, service.ErrInvalidPointsAmount:
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to adjust loyalty points")
+	}
+	return c.Status(fiber.StatusCreated).JSON(entry)
+}
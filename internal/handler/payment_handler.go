@@ -6,6 +6,7 @@ import (
 	"ngabaca/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type PaymentHandler struct {
@@ -35,3 +36,20 @@ func (h *PaymentHandler) MidtransNotification(c *fiber.Ctx) error {
 	// Beri tahu Midtrans bahwa notifikasi sudah diterima
 	return c.SendStatus(fiber.StatusOK)
 }
+
+// FakePayment adalah halaman pembayaran payment gateway tiruan. Membukanya mensimulasikan
+// notifikasi pelunasan dari gateway untuk pesanan tersebut. Hanya didaftarkan jika PAYMENT_GATEWAY=fake.
+func (h *PaymentHandler) FakePayment(c *fiber.Ctx) error {
+	orderID := c.Params("orderID")
+	err := h.paymentService.UpdatePaymentStatus(map[string]interface{}{
+		"order_id":           orderID,
+		"transaction_status": "settlement",
+		"fraud_status":       "accept",
+		"payment_type":       "fake",
+		"transaction_id":     "fake-" + uuid.NewString(),
+	})
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(fiber.Map{"message": "Payment for order " + orderID + " has been settled"})
+}
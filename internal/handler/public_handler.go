@@ -4,6 +4,8 @@ import (
 	"ngabaca/internal/model"
 	"ngabaca/internal/repository"
 	"ngabaca/internal/utils"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -40,19 +42,22 @@ type CategorySummary struct {
 
 // BookDetailResponse adalah struct utama untuk respons JSON.
 type BookDetailResponse struct {
-	ID            uuid.UUID       `json:"id"`
-	Title         string          `json:"title"`
-	Slug          string          `json:"slug"`
-	PublishedYear int             `json:"published_year"`
-	CoverImageURL string          `json:"cover_image_url"`
-	Author        string          `json:"author"`
-	Description   string          `json:"description"`
-	Price         float64         `json:"price"`
-	Stock         int             `json:"stock"`
-	AvgRating     float64         `json:"avg_rating"`
-	ReviewCount   int             `json:"review_count"`
-	Category      CategorySummary `json:"category"`
-	Reviews       []ReviewDetail  `json:"reviews"`
+	ID            uuid.UUID             `json:"id"`
+	Title         string                `json:"title"`
+	Slug          string                `json:"slug"`
+	PublishedYear int                   `json:"published_year"`
+	CoverImageURL string                `json:"cover_image_url"`
+	Author        string                `json:"author"`
+	Description   string                `json:"description"`
+	Price         float64               `json:"price"`
+	Stock         int                   `json:"stock"`
+	AvgRating     float64               `json:"avg_rating"`
+	ReviewCount   int                   `json:"review_count"`
+	HasPreview    bool                  `json:"has_preview"`
+	RentalOptions []model.RentalOption  `json:"rental_options"`
+	FlashSale     *model.FlashSaleOffer `json:"flash_sale"`
+	Category      CategorySummary       `json:"category"`
+	Reviews       []ReviewDetail        `json:"reviews"`
 }
 
 type PublicHandler struct {
@@ -144,6 +149,9 @@ func (h *PublicHandler) GetBookDetail(c *fiber.Ctx) error {
 		CoverImageURL: book.CoverImageURL,
 		AvgRating:     book.AvgRating,
 		ReviewCount:   book.ReviewCount,
+		HasPreview:    book.HasPreview(),
+		RentalOptions: book.RentalOptions(),
+		FlashSale:     book.FlashSale,
 		Category: CategorySummary{
 			ID:   book.Category.ID,
 			Name: book.Category.Name,
@@ -156,6 +164,27 @@ func (h *PublicHandler) GetBookDetail(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// GetBookPreview mengirim file sampel buku yang boleh dibaca siapa saja.
+func (h *PublicHandler) GetBookPreview(c *fiber.Ctx) error {
+	book, err := h.bookRepo.FindBySlug(c.Params("slug"))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Book not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+	if !book.HasPreview() {
+		return utils.GenericError(c, fiber.StatusNotFound, "Preview is not available for this book")
+	}
+	if _, err := os.Stat(book.PreviewFilePath); err != nil {
+		return utils.GenericError(c, fiber.StatusNotFound, "Preview is not available for this book")
+	}
+
+	fileName := book.Slug + "-preview" + filepath.Ext(book.PreviewFilePath)
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+fileName+`"`)
+	return c.SendFile(book.PreviewFilePath)
+}
+
 func (h *PublicHandler) SearchBooks(c *fiber.Ctx) error {
 	keyword := c.Query("q")
 	if keyword == "" {
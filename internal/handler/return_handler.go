@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
+	"ngabaca/internal/utils"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxReturnPhotoSize adalah ukuran maksimal satu foto bukti retur.
+const maxReturnPhotoSize = 5 << 20
+
+// ReturnHandler menampung dependency untuk pengajuan dan pemrosesan retur (RMA).
+type ReturnHandler struct {
+	returnService service.ReturnService
+	returnRepo    repository.ReturnRepository
+	orderRepo     repository.OrderRepository
+	cfg           config.Config
+}
+
+// NewReturnHandler adalah constructor untuk ReturnHandler.
+func NewReturnHandler(returnService service.ReturnService, returnRepo repository.ReturnRepository, orderRepo repository.OrderRepository, cfg config.Config) *ReturnHandler {
+	return &ReturnHandler{
+		returnService: returnService,
+		returnRepo:    returnRepo,
+		orderRepo:     orderRepo,
+		cfg:           cfg,
+	}
+}
+
+// returnError menerjemahkan error dari ReturnService ke respons HTTP.
+func returnError(c *fiber.Ctx, err error, fallback string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return utils.GenericError(c, fiber.StatusNotFound, "Return not found")
+	case errors.Is(err, service.ErrReturnStatusInvalid), errors.Is(err, service.ErrRefundExceedsPaid),
+		errors.Is(err, service.ErrOrderNotPaid):
+		return utils.GenericError(c, fiber.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrOrderNotReturnable), errors.Is(err, service.ErrReturnWindowClosed),
+		errors.Is(err, service.ErrReturnItemNotReturnable), errors.Is(err, service.ErrReturnQuantityExceeded),
+		errors.Is(err, service.ErrReturnTooManyPhotos), errors.Is(err, service.ErrReturnPhotoInvalid),
+		errors.Is(err, service.ErrReturnDispositionRequired), errors.Is(err, service.ErrReturnItemNotFound),
+		errors.Is(err, service.ErrInvalidRefundMethod):
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+	return utils.GenericError(c, fiber.StatusInternalServerError, fallback)
+}
+
+// CreateReturn mengajukan retur untuk item dari pesanan yang sudah selesai.
+func (h *ReturnHandler) CreateReturn(c *fiber.Ctx) error {
+	req := new(service.CreateReturnRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	order, err := findUserOrder(h.orderRepo, c.Params("id"), userID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "Order not found or you don't have permission to view it")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	ret, err := h.returnService.RequestReturn(userID, order.ID, req)
+	if err != nil {
+		return returnError(c, err, "Failed to create return request")
+	}
+	return c.Status(fiber.StatusCreated).JSON(ret)
+}
+
+// UploadReturnPhoto mengunggah satu foto bukti kondisi barang. URL yang dihasilkan dikirim
+// di photo_urls saat mengajukan retur.
+func (h *ReturnHandler) UploadReturnPhoto(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Photo file is required")
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	allowedExt := map[string]bool{".jpg": true, ".jpeg": true, ".png": true}
+	if !allowedExt[ext] {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid file type. Only jpg, jpeg, png are allowed.")
+	}
+	if file.Size > maxReturnPhotoSize {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Photo must not be larger than 5 MB")
+	}
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Could not read photo")
+	}
+	defer openedFile.Close()
+	fileBytes, err := io.ReadAll(openedFile)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Could not read photo")
+	}
+
+	fileName := fmt.Sprintf("%s-%d%s", userID, time.Now().UnixNano(), ext)
+	url, err := utils.UploadToImageKit(h.cfg, fileBytes, fileName, "returns")
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Image upload failed: "+err.Error())
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"url": url})
+}
+
+// GetMyReturns menampilkan semua retur milik pengguna.
+func (h *ReturnHandler) GetMyReturns(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	returns, err := h.returnService.GetUserReturns(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch returns")
+	}
+	return c.JSON(returns)
+}
+
+// GetMyReturn menampilkan detail satu retur milik pengguna.
+func (h *ReturnHandler) GetMyReturn(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	ret, err := h.returnService.GetUserReturn(userID, returnID)
+	if err != nil {
+		return returnError(c, err, "Could not fetch return")
+	}
+	return c.JSON(ret)
+}
+
+// CancelMyReturn membatalkan retur yang belum ditinjau admin.
+func (h *ReturnHandler) CancelMyReturn(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	ret, err := h.returnService.CancelReturn(userID, returnID)
+	if err != nil {
+		return returnError(c, err, "Failed to cancel return")
+	}
+	return c.JSON(fiber.Map{"message": "Return cancelled", "return": ret})
+}
+
+// AdminGetReturns menampilkan semua retur, bisa difilter dengan ?status=.
+func (h *ReturnHandler) AdminGetReturns(c *fiber.Ctx) error {
+	returns, err := h.returnService.GetReturns(c.Query("status"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch returns")
+	}
+	return c.JSON(returns)
+}
+
+// AdminGetReturn menampilkan detail satu retur.
+func (h *ReturnHandler) AdminGetReturn(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	ret, err := h.returnService.GetReturn(returnID)
+	if err != nil {
+		return returnError(c, err, "Could not fetch return")
+	}
+	return c.JSON(ret)
+}
+
+// ReviewReturnRequest adalah catatan admin saat menyetujui atau menolak retur.
+type ReviewReturnRequest struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+// AdminApproveReturn menyetujui retur sehingga pembeli bisa mengirim barangnya kembali.
+func (h *ReturnHandler) AdminApproveReturn(c *fiber.Ctx) error {
+	return h.review(c, h.returnService.Approve, false, "Failed to approve return")
+}
+
+// AdminRejectReturn menolak retur. Alasan penolakan wajib diisi agar bisa disampaikan ke pembeli.
+func (h *ReturnHandler) AdminRejectReturn(c *fiber.Ctx) error {
+	return h.review(c, h.returnService.Reject, true, "Failed to reject return")
+}
+
+// review menjalankan persetujuan atau penolakan retur oleh admin yang sedang login.
+func (h *ReturnHandler) review(c *fiber.Ctx, action func(adminID, returnID uuid.UUID, note string) (*model.ReturnRequest, error), requireNote bool, fallback string) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	req := new(ReviewReturnRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+	if requireNote && strings.TrimSpace(req.Note) == "" {
+		return utils.GenericError(c, fiber.StatusBadRequest, "A note explaining the rejection is required")
+	}
+
+	adminClaims := c.Locals("user").(jwt.MapClaims)
+	adminID, _ := uuid.Parse(adminClaims["user_id"].(string))
+
+	ret, err := action(adminID, returnID, req.Note)
+	if err != nil {
+		return returnError(c, err, fallback)
+	}
+	return c.JSON(ret)
+}
+
+// AdminReceiveReturn mencatat barang retur sudah diterima beserta perlakuannya (restock atau write_off).
+func (h *ReturnHandler) AdminReceiveReturn(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	req := new(service.ReceiveReturnRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	ret, err := h.returnService.Receive(returnID, req)
+	if err != nil {
+		return returnError(c, err, "Failed to receive return")
+	}
+	return c.JSON(ret)
+}
+
+// RefundReturnRequest memilih tujuan pengembalian dana. Kosong berarti memakai pilihan pembeli.
+type RefundReturnRequest struct {
+	Method string `json:"method" validate:"omitempty,oneof=original_payment store_credit"`
+}
+
+// AdminRefundReturn mengembalikan dana retur yang barangnya sudah diterima.
+func (h *ReturnHandler) AdminRefundReturn(c *fiber.Ctx) error {
+	returnID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	req := new(RefundReturnRequest)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(req); err != nil {
+			return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	ret, err := h.returnService.Refund(returnID, req.Method)
+	if err != nil {
+		return returnError(c, err, "Failed to refund return")
+	}
+	return c.JSON(ret)
+}
+
+// AdminGetReturnReport merangkum retur dalam rentang tanggal ?from=&to= (format YYYY-MM-DD,
+// keduanya inklusif): jumlah pengajuan per status dan barang yang diterima per perlakuannya.
+// Default-nya bulan berjalan.
+func (h *ReturnHandler) AdminGetReturnReport(c *fiber.Ctx) error {
+	from, to, err := reportRange(c)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	statuses, err := h.returnRepo.CountByStatus(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not build return report")
+	}
+	dispositions, err := h.returnRepo.DispositionSummary(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not build return report")
+	}
+
+	var restocked, writtenOff int
+	var refundAmount float64
+	for _, row := range dispositions {
+		switch row.Disposition {
+		case model.ReturnDispositionRestock:
+			restocked += row.Quantity
+		case model.ReturnDispositionWriteOff:
+			writtenOff += row.Quantity
+		}
+		refundAmount += row.RefundAmount
+	}
+	return c.JSON(fiber.Map{
+		"from":                  from.Format("2006-01-02"),
+		"to":                    to.Format("2006-01-02"),
+		"by_status":             statuses,
+		"by_disposition":        dispositions,
+		"total_restocked":       restocked,
+		"total_written_off":     writtenOff,
+		"total_received_refund": refundAmount,
+	})
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
+	"ngabaca/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WalletHandler menampung dependency untuk dompet saldo dan gift card.
+type WalletHandler struct {
+	walletService   service.WalletService
+	giftCardService service.GiftCardService
+	userRepo        repository.UserRepository
+}
+
+// NewWalletHandler adalah constructor untuk WalletHandler.
+func NewWalletHandler(
+	walletService service.WalletService,
+	giftCardService service.GiftCardService,
+	userRepo repository.UserRepository,
+) *WalletHandler {
+	return &WalletHandler{
+		walletService:   walletService,
+		giftCardService: giftCardService,
+		userRepo:        userRepo,
+	}
+}
+
+// GetMyWallet menampilkan saldo dompet dan mutasi terakhir pengguna.
+func (h *WalletHandler) GetMyWallet(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	summary, err := h.walletService.GetSummary(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch wallet")
+	}
+	return c.JSON(summary)
+}
+
+// RedeemGiftCardRequest adalah body untuk menukar gift card.
+type RedeemGiftCardRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// RedeemGiftCard menukar kode gift card menjadi saldo dompet.
+func (h *WalletHandler) RedeemGiftCard(c *fiber.Ctx) error {
+	req := new(RedeemGiftCardRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	transaction, err := h.giftCardService.Redeem(userID, req.Code)
+	if err != nil {
+		switch err {
+		case service.ErrGiftCardNotFound:
+			return utils.GenericError(c, fiber.StatusNotFound, err.Error())
+		case service.ErrGiftCardAlreadyRedeemed:
+			return utils.GenericError(c, fiber.StatusConflict, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to redeem gift card")
+	}
+	return c.JSON(transaction)
+}
+
+// GetMyGiftCards menampilkan gift card yang dibeli pengguna beserta kodenya.
+func (h *WalletHandler) GetMyGiftCards(c *fiber.Ctx) error {
+	userClaims := c.Locals("user").(jwt.MapClaims)
+	userID, _ := uuid.Parse(userClaims["user_id"].(string))
+
+	cards, err := h.giftCardService.GetPurchased(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch gift cards")
+	}
+	return c.JSON(cards)
+}
+
+// AdminGetUserWallet menampilkan saldo dan mutasi dompet seorang pengguna.
+func (h *WalletHandler) AdminGetUserWallet(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+	if _, err := h.userRepo.FindByID(userID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "User not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	summary, err := h.walletService.GetSummary(userID)
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Could not fetch wallet")
+	}
+	return c.JSON(summary)
+}
+
+// AdjustWalletRequest adalah body penyesuaian saldo oleh admin. Amount negatif mengurangi saldo.
+type AdjustWalletRequest struct {
+	Amount float64 `json:"amount" validate:"required"`
+	Note   string  `json:"note" validate:"required,max=255"`
+}
+
+// AdminAdjustWallet menambah atau mengurangi saldo dompet seorang pengguna.
+func (h *WalletHandler) AdminAdjustWallet(c *fiber.Ctx) error {
+	userID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid ID format")
+	}
+
+	req := new(AdjustWalletRequest)
+	if err := c.BodyParser(req); err != nil {
+		return utils.GenericError(c, fiber.StatusBadRequest, "Invalid request body")
+	}
+	if errs := utils.ValidateStruct(req); errs != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"errors": errs})
+	}
+
+	if _, err := h.userRepo.FindByID(userID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return utils.GenericError(c, fiber.StatusNotFound, "User not found")
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Database error")
+	}
+
+	transaction, err := h.walletService.Adjust(userID, req.Amount, req.Note)
+	if err != nil {
+		switch err {
+		case service.ErrInsufficientWalletBalance, service.ErrInvalidWalletAmount:
+			return utils.GenericError(c, fiber.StatusBadRequest, err.Error())
+		}
+		return utils.GenericError(c, fiber.StatusInternalServerError, "Failed to adjust wallet")
+	}
+	return c.Status(fiber.StatusCreated).JSON(transaction)
+}
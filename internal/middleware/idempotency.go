@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyInFlightTTL membatasi umur penanda request yang sedang diproses, agar key dari request
+// yang terhenti di tengah jalan (misalnya server mati) bisa dipakai lagi tanpa menunggu TTL penuh.
+const idempotencyInFlightTTL = 2 * time.Minute
+
+// idempotencyRecord adalah request yang tercatat untuk sebuah Idempotency-Key beserta response-nya.
+type idempotencyRecord struct {
+	RequestHash string `json:"request_hash"`
+	Done        bool   `json:"done"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency membuat request yang mengubah data aman untuk diulang. Request dengan header
+// Idempotency-Key yang sama dan isi yang sama mendapat response pertama tanpa diproses ulang,
+// sedangkan key yang dipakai ulang untuk request berbeda ditolak dengan 422.
+// Key dicatat per pengguna di Redis, jadi middleware ini harus dipasang setelah Protected.
+func Idempotency(rdb *redis.Client, ttl time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" || c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead || c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+		if len(key) > 255 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+
+		userID := ""
+		if claims, ok := c.Locals("user").(jwt.MapClaims); ok {
+			userID, _ = claims["user_id"].(string)
+		}
+		redisKey := fmt.Sprintf("idempotency:%s:%s", userID, key)
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Method() + " " + c.Path() + "\n"))
+		hash.Write(c.Body())
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		ctx := context.Background()
+		pending, _ := json.Marshal(idempotencyRecord{RequestHash: requestHash})
+		acquired, err := rdb.SetNX(ctx, redisKey, pending, idempotencyInFlightTTL).Result()
+		if err != nil {
+			// Redis bermasalah: request tetap diproses agar checkout tidak ikut terhenti
+			fmt.Println("Error saat mencatat Idempotency-Key:", err)
+			return c.Next()
+		}
+
+		if !acquired {
+			raw, err := rdb.Get(ctx, redisKey).Bytes()
+			if err != nil {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+			}
+			var record idempotencyRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Could not read idempotency record",
+				})
+			}
+			if record.RequestHash != requestHash {
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error": "Idempotency-Key was already used for a different request",
+				})
+			}
+			if !record.Done {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "A request with this Idempotency-Key is still being processed",
+				})
+			}
+
+			c.Set("Idempotent-Replayed", "true")
+			if record.ContentType != "" {
+				c.Set(fiber.HeaderContentType, record.ContentType)
+			}
+			return c.Status(record.StatusCode).Send(record.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			// Biarkan klien mengulang request yang gagal karena error
+			rdb.Del(ctx, redisKey)
+			return err
+		}
+
+		// Error server tidak disimpan agar request bisa diulang setelah masalahnya pulih. Handler
+		// yang sudah menyimpan data sebelum gagal harus mengenali ulangan key yang sama sendiri,
+		// seperti Checkout yang mencari pesanan lewat CheckoutKey.
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			rdb.Del(ctx, redisKey)
+			return nil
+		}
+
+		done, _ := json.Marshal(idempotencyRecord{
+			RequestHash: requestHash,
+			Done:        true,
+			StatusCode:  status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		})
+		if err := rdb.Set(ctx, redisKey, done, ttl).Err(); err != nil {
+			fmt.Println("Error saat menyimpan response Idempotency-Key:", err)
+		}
+		return nil
+	}
+}
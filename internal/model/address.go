@@ -0,0 +1,77 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Address adalah alamat pengiriman tersimpan milik pengguna.
+type Address struct {
+	Basemodel
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Label         string    `json:"label"` // Misal "Rumah" atau "Kantor"
+	RecipientName string    `gorm:"not null" json:"recipient_name"`
+	Phone         string    `gorm:"not null" json:"phone"`
+	Province      string    `gorm:"not null" json:"province"`
+	City          string    `gorm:"not null" json:"city"`
+	District      string    `gorm:"not null" json:"district"` // Kecamatan
+	PostalCode    string    `gorm:"not null" json:"postal_code"`
+	Detail        string    `gorm:"not null" json:"detail"` // Nama jalan, nomor rumah, RT/RW, patokan
+	IsDefault     bool      `gorm:"default:false" json:"is_default"`
+}
+
+// Snapshot menyalin isi alamat untuk disimpan di pesanan.
+func (a Address) Snapshot() *AddressSnapshot {
+	return &AddressSnapshot{
+		AddressID:     a.ID,
+		Label:         a.Label,
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Province:      a.Province,
+		City:          a.City,
+		District:      a.District,
+		PostalCode:    a.PostalCode,
+		Detail:        a.Detail,
+	}
+}
+
+// AddressSnapshot adalah salinan alamat pada saat checkout. Disimpan sebagai JSONB di pesanan
+// sehingga perubahan atau penghapusan alamat di buku alamat tidak mengubah pesanan lama.
+type AddressSnapshot struct {
+	AddressID     uuid.UUID `json:"address_id"`
+	Label         string    `json:"label,omitempty"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         string    `json:"phone"`
+	Province      string    `json:"province"`
+	City          string    `json:"city"`
+	District      string    `json:"district"`
+	PostalCode    string    `json:"postal_code"`
+	Detail        string    `json:"detail"`
+}
+
+// String menyusun alamat menjadi satu baris teks, dipakai untuk kolom ShippingAddress lama.
+func (a AddressSnapshot) String() string {
+	parts := []string{a.RecipientName + " (" + a.Phone + ")", a.Detail, a.District, a.City, a.Province, a.PostalCode}
+	return strings.Join(parts, ", ")
+}
+
+// Value mengimplementasikan interface driver.Valuer untuk GORM.
+func (a AddressSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+// Scan mengimplementasikan interface sql.Scanner untuk GORM.
+func (a *AddressSnapshot) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, a)
+}
@@ -2,6 +2,13 @@ package model
 
 import "github.com/google/uuid"
 
+// Format produk yang didukung oleh tabel buku.
+const (
+	BookFormatPhysical = "physical"
+	BookFormatEbook    = "ebook"
+	BookFormatGiftCard = "gift_card" // Voucher saldo dompet, Price adalah nilai nominalnya
+)
+
 // Book mendefinisikan skema untuk tabel buku.
 type Book struct {
 	Basemodel
@@ -13,13 +20,92 @@ type Book struct {
 	Stock           int       `gorm:"not null" json:"stock"`
 	PublishedYear   int       `gorm:"not null" json:"published_year"`
 	CoverImageURL   string    `json:"cover_image_url"`
-	PrivateFilePath string    `json:"private_file_path"`
+	Format          string    `gorm:"default:'physical';not null" json:"format"`
+	PrivateFilePath string    `json:"-"` // Lokasi file ebook di storage privat, tidak boleh bocor ke klien
+	PreviewFilePath string    `json:"-"` // Lokasi file sampel yang boleh diakses publik
 	CategoryID      uuid.UUID `json:"category_id"`
+	TaxClass        string    `json:"tax_class"` // Kosong berarti mengikuti format buku
+
+	// Berat dan dimensi kemasan untuk menghitung ongkos kirim buku fisik
+	WeightGrams int     `gorm:"default:0" json:"weight_grams"`
+	LengthCm    float64 `gorm:"default:0" json:"length_cm"`
+	WidthCm     float64 `gorm:"default:0" json:"width_cm"`
+	HeightCm    float64 `gorm:"default:0" json:"height_cm"`
+
+	// Harga sewa ebook per durasi, 0 berarti durasi tersebut tidak tersedia
+	RentalPrice7  float64 `gorm:"default:0" json:"rental_price_7"`
+	RentalPrice14 float64 `gorm:"default:0" json:"rental_price_14"`
+	RentalPrice30 float64 `gorm:"default:0" json:"rental_price_30"`
 
 	// Relasi
-	Reviews     []Review    `gorm:"foreignKey:BookID" json:"reviews,omitempty"`
-	AvgRating   float64     `gorm:"-" json:"avg_rating"`
-	ReviewCount int         `gorm:"-" json:"review_count"`
-	Category    Category    `gorm:"foreignKey:CategoryID" json:"-"`
-	OrderItems  []OrderItem `gorm:"foreignKey:BookID" json:"-"`
+	Reviews        []Review        `gorm:"foreignKey:BookID" json:"reviews,omitempty"`
+	AvgRating      float64         `gorm:"-" json:"avg_rating"`
+	ReviewCount    int             `gorm:"-" json:"review_count"`
+	AvailableStock int             `gorm:"-" json:"available_stock"` // Stok dikurangi reservasi aktif
+	FlashSale      *FlashSaleOffer `gorm:"-" json:"flash_sale"`      // Flash sale yang sedang berlaku
+	Category       Category        `gorm:"foreignKey:CategoryID" json:"-"`
+	OrderItems     []OrderItem     `gorm:"foreignKey:BookID" json:"-"`
+}
+
+// IsDigital menandakan buku dikirim sebagai file, sehingga tidak memakai stok maupun pengiriman.
+func (b Book) IsDigital() bool {
+	return b.Format == BookFormatEbook
+}
+
+// IsGiftCard menandakan produk berupa gift card yang kodenya diterbitkan setelah pembayaran lunas.
+func (b Book) IsGiftCard() bool {
+	return b.Format == BookFormatGiftCard
+}
+
+// IsShippable menandakan produk berupa barang fisik yang memakai stok dan perlu dikirim.
+func (b Book) IsShippable() bool {
+	return !b.IsDigital() && !b.IsGiftCard()
+}
+
+// EffectiveTaxClass mengembalikan kelas pajak buku. Jika belum diatur, kelas ditentukan dari formatnya.
+func (b Book) EffectiveTaxClass() string {
+	switch {
+	case b.TaxClass != "":
+		return b.TaxClass
+	case b.IsGiftCard():
+		return TaxClassNone
+	case b.IsDigital():
+		return TaxClassEbook
+	}
+	return TaxClassPhysicalBook
+}
+
+// HasPreview menandakan buku memiliki file sampel yang bisa dibaca gratis.
+func (b Book) HasPreview() bool {
+	return b.PreviewFilePath != ""
+}
+
+// RentalOption adalah satu pilihan durasi sewa ebook.
+type RentalOption struct {
+	Days  int     `json:"days"`
+	Price float64 `json:"price"`
+}
+
+// RentalOptions mengembalikan durasi sewa yang tersedia untuk buku ini.
+func (b Book) RentalOptions() []RentalOption {
+	options := []RentalOption{}
+	if !b.IsDigital() {
+		return options
+	}
+	for _, o := range []RentalOption{{7, b.RentalPrice7}, {14, b.RentalPrice14}, {30, b.RentalPrice30}} {
+		if o.Price > 0 {
+			options = append(options, o)
+		}
+	}
+	return options
+}
+
+// RentalPrice mengembalikan harga sewa untuk durasi tertentu.
+func (b Book) RentalPrice(days int) (float64, bool) {
+	for _, o := range b.RentalOptions() {
+		if o.Days == days {
+			return o.Price, true
+		}
+	}
+	return 0, false
 }
@@ -0,0 +1,13 @@
+package model
+
+import "github.com/google/uuid"
+
+// Bookmark mendefinisikan skema untuk penanda halaman pada ebook.
+type Bookmark struct {
+	Basemodel
+	UserID       uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	BookID       uuid.UUID `gorm:"type:uuid;not null;index" json:"book_id"`
+	Position     string    `gorm:"not null" json:"position"`
+	PositionType string    `gorm:"not null" json:"position_type"`
+	Label        string    `json:"label"`
+}
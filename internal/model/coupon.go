@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Jenis kupon.
+const (
+	CouponTypePercentage   = "percentage"    // Potongan persen dari item yang memenuhi syarat
+	CouponTypeFixed        = "fixed"         // Potongan nominal tetap
+	CouponTypeFreeShipping = "free_shipping" // Gratis ongkos kirim
+)
+
+// Status pemakaian kupon.
+const (
+	CouponRedemptionActive   = "active"
+	CouponRedemptionReleased = "released" // Pesanan batal, kuota kupon dikembalikan
+)
+
+// Coupon mendefinisikan skema untuk kode promo.
+type Coupon struct {
+	Basemodel
+	Code         string     `gorm:"uniqueIndex:idx_coupons_code_active,where:deleted_at IS NULL;not null" json:"code"` // Kode kupon yang sudah dihapus boleh dipakai lagi
+	Description  string     `json:"description"`
+	Type         string     `gorm:"not null" json:"type"`
+	Value        float64    `gorm:"default:0" json:"value"`          // Persen atau nominal, tergantung Type
+	MaxDiscount  float64    `gorm:"default:0" json:"max_discount"`   // Batas potongan kupon persen, 0 berarti tanpa batas
+	MinSpend     float64    `gorm:"default:0" json:"min_spend"`      // Minimal subtotal item yang memenuhi syarat
+	UsageLimit   int        `gorm:"default:0" json:"usage_limit"`    // Batas pemakaian total, 0 berarti tanpa batas
+	PerUserLimit int        `gorm:"default:0" json:"per_user_limit"` // Batas pemakaian per pengguna, 0 berarti tanpa batas
+	UsedCount    int        `gorm:"default:0;not null" json:"used_count"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	IsActive     bool       `gorm:"default:true;not null" json:"is_active"`
+
+	// Batasan kupon; jika keduanya kosong kupon berlaku untuk semua buku
+	Categories []Category `gorm:"many2many:coupon_categories" json:"categories"`
+	Books      []Book     `gorm:"many2many:coupon_books" json:"books"`
+}
+
+// IsRestricted menandakan kupon hanya berlaku untuk kategori atau buku tertentu.
+func (c Coupon) IsRestricted() bool {
+	return len(c.Categories) > 0 || len(c.Books) > 0
+}
+
+// AppliesTo mengecek apakah kupon berlaku untuk sebuah buku.
+func (c Coupon) AppliesTo(book Book) bool {
+	if !c.IsRestricted() {
+		return true
+	}
+	for _, b := range c.Books {
+		if b.ID == book.ID {
+			return true
+		}
+	}
+	for _, cat := range c.Categories {
+		if cat.ID == book.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// CouponRedemption mencatat pemakaian kupon pada sebuah pesanan.
+type CouponRedemption struct {
+	Basemodel
+	CouponID uuid.UUID `gorm:"type:uuid;not null;index" json:"coupon_id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	OrderID  uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	Amount   float64   `gorm:"not null" json:"amount"`
+	Status   string    `gorm:"default:'active';not null" json:"status"`
+
+	// Relasi
+	Coupon Coupon `gorm:"foreignKey:CouponID" json:"-"`
+	User   User   `gorm:"foreignKey:UserID" json:"-"`
+}
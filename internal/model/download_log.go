@@ -0,0 +1,13 @@
+package model
+
+import "github.com/google/uuid"
+
+// DownloadLog mencatat setiap akses unduhan ebook untuk keperluan audit.
+type DownloadLog struct {
+	Basemodel
+	EntitlementID uuid.UUID `gorm:"type:uuid;not null;index" json:"entitlement_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	BookID        uuid.UUID `gorm:"type:uuid;not null" json:"book_id"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Jenis hak akses ebook.
+const (
+	EntitlementTypePurchase = "purchase"
+	EntitlementTypeRental   = "rental"
+)
+
+// Status hak akses ebook.
+const (
+	EntitlementStatusActive  = "active"
+	EntitlementStatusExpired = "expired"
+	EntitlementStatusRevoked = "revoked" // Dicabut karena pesanan pembeliannya batal
+)
+
+// Entitlement mendefinisikan skema untuk hak akses pengguna terhadap sebuah ebook.
+// Record ini dibuat ketika pembayaran pesanan yang berisi ebook berhasil.
+type Entitlement struct {
+	Basemodel
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	BookID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"book_id"`
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	Type          string     `gorm:"default:'purchase';not null" json:"type"`
+	Status        string     `gorm:"default:'active';not null" json:"status"`
+	DownloadCount int        `gorm:"default:0;not null" json:"download_count"`
+	MaxDownloads  int        `gorm:"not null" json:"max_downloads"`
+	ExpiresAt     *time.Time `json:"expires_at"` // Hanya diisi untuk sewa
+
+	// Khusus sewa
+	RentalFeePaid    float64    `gorm:"default:0" json:"rental_fee_paid"` // Total biaya sewa, dikreditkan saat dikonversi menjadi pembelian
+	ExpiryNotifiedAt *time.Time `json:"-"`
+
+	// Relasi
+	User User `gorm:"foreignKey:UserID" json:"-"`
+	Book Book `gorm:"foreignKey:BookID" json:"book"`
+}
+
+// IsActive mengecek apakah hak akses masih berlaku.
+func (e Entitlement) IsActive() bool {
+	if e.Status != EntitlementStatusActive {
+		return false
+	}
+	return e.ExpiresAt == nil || e.ExpiresAt.After(time.Now())
+}
+
+// IsRental menandakan hak akses berasal dari sewa.
+func (e Entitlement) IsRental() bool {
+	return e.Type == EntitlementTypeRental
+}
+
+// RemainingDownloads mengembalikan sisa kuota unduhan.
+func (e Entitlement) RemainingDownloads() int {
+	if e.DownloadCount >= e.MaxDownloads {
+		return 0
+	}
+	return e.MaxDownloads - e.DownloadCount
+}
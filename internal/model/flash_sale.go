@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status pembelian flash sale.
+const (
+	FlashSalePurchaseActive   = "active"
+	FlashSalePurchaseReleased = "released" // Pesanan batal, kuota dikembalikan
+)
+
+// FlashSale adalah acara diskon berjangka waktu untuk sejumlah buku.
+type FlashSale struct {
+	Basemodel
+	Name        string          `gorm:"not null" json:"name"`
+	Description string          `json:"description"`
+	StartsAt    time.Time       `gorm:"not null;index" json:"starts_at"`
+	EndsAt      time.Time       `gorm:"not null;index" json:"ends_at"`
+	IsActive    bool            `gorm:"default:true;not null" json:"is_active"`
+	Items       []FlashSaleItem `gorm:"foreignKey:FlashSaleID" json:"items"`
+}
+
+// IsRunning menandakan flash sale sedang berlangsung pada waktu tertentu.
+func (f FlashSale) IsRunning(at time.Time) bool {
+	return f.IsActive && !at.Before(f.StartsAt) && at.Before(f.EndsAt)
+}
+
+// FlashSaleItem adalah harga khusus sebuah buku selama flash sale.
+type FlashSaleItem struct {
+	Basemodel
+	FlashSaleID      uuid.UUID `gorm:"type:uuid;not null;index" json:"flash_sale_id"`
+	BookID           uuid.UUID `gorm:"type:uuid;not null;index" json:"book_id"`
+	SalePrice        float64   `gorm:"not null" json:"sale_price"`
+	Quota            int       `gorm:"default:0" json:"quota"`              // Jumlah yang bisa terjual, 0 berarti tanpa batas
+	PerCustomerLimit int       `gorm:"default:0" json:"per_customer_limit"` // Maksimal per pelanggan, 0 berarti tanpa batas
+	SoldCount        int       `gorm:"default:0;not null" json:"sold_count"`
+
+	// Relasi
+	FlashSale FlashSale `gorm:"foreignKey:FlashSaleID" json:"-"`
+	Book      Book      `gorm:"foreignKey:BookID" json:"book"`
+}
+
+// FlashSalePurchase mencatat jumlah yang diambil sebuah pesanan dari kuota flash sale.
+type FlashSalePurchase struct {
+	Basemodel
+	FlashSaleItemID uuid.UUID `gorm:"type:uuid;not null;index" json:"flash_sale_item_id"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	OrderID         uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	Quantity        int       `gorm:"not null" json:"quantity"`
+	Status          string    `gorm:"default:'active';not null" json:"status"`
+}
+
+// FlashSaleOffer adalah ringkasan flash sale yang sedang berlaku untuk sebuah buku,
+// ditampilkan di katalog dan detail buku.
+type FlashSaleOffer struct {
+	FlashSaleID      uuid.UUID `json:"flash_sale_id"`
+	FlashSaleItemID  uuid.UUID `json:"flash_sale_item_id"`
+	Name             string    `json:"name"`
+	SalePrice        float64   `json:"sale_price"`
+	EndsAt           time.Time `json:"ends_at"`
+	EndsInSeconds    int64     `json:"ends_in_seconds"`
+	Quota            int       `json:"quota"`
+	Remaining        *int      `json:"remaining,omitempty"` // Kosong jika kuota tanpa batas
+	PerCustomerLimit int       `json:"per_customer_limit"`
+}
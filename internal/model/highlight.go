@@ -0,0 +1,16 @@
+package model
+
+import "github.com/google/uuid"
+
+// Highlight mendefinisikan skema untuk teks yang ditandai pengguna pada ebook.
+type Highlight struct {
+	Basemodel
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	BookID        uuid.UUID `gorm:"type:uuid;not null;index" json:"book_id"`
+	StartPosition string    `gorm:"not null" json:"start_position"`
+	EndPosition   string    `gorm:"not null" json:"end_position"`
+	PositionType  string    `gorm:"not null" json:"position_type"`
+	Text          string    `json:"text"`
+	Note          string    `json:"note"`
+	Color         string    `gorm:"default:'yellow'" json:"color"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tingkat keanggotaan berdasarkan belanja 12 bulan terakhir.
+const (
+	LoyaltyTierRegular = "Regular"
+	LoyaltyTierSilver  = "Silver"
+	LoyaltyTierGold    = "Gold"
+)
+
+// Jenis mutasi poin loyalti.
+const (
+	LoyaltyEntryEarn     = "earn"     // Poin dari pesanan selesai
+	LoyaltyEntryRedeem   = "redeem"   // Poin ditukar menjadi potongan saat checkout
+	LoyaltyEntryReversal = "reversal" // Poin yang ditukar dikembalikan karena pesanan batal
+	LoyaltyEntryRevoke   = "revoke"   // Poin yang didapat ditarik karena pesanan batal
+	LoyaltyEntryExpire   = "expire"   // Poin hangus
+	LoyaltyEntryAdjust   = "adjust"   // Penyesuaian oleh admin
+)
+
+// LoyaltyAccount menyimpan saldo poin seorang pengguna.
+type LoyaltyAccount struct {
+	Basemodel
+	UserID  uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"`
+	Balance int       `gorm:"default:0;not null;check:balance >= 0" json:"balance"`
+}
+
+// LoyaltyEntry adalah satu baris riwayat poin. Entri yang menambah poin sekaligus menjadi "lot"
+// dengan sisa dan tanggal hangus sendiri, sehingga penukaran memakai poin yang paling dulu hangus.
+type LoyaltyEntry struct {
+	Basemodel
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type      string     `gorm:"not null" json:"type"`
+	Points    int        `gorm:"not null" json:"points"`     // Positif menambah, negatif mengurangi saldo
+	Remaining int        `gorm:"default:0" json:"remaining"` // Sisa poin lot yang belum dipakai atau hangus
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	OrderID   *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	ActorID   *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"` // Admin yang melakukan penyesuaian
+	Note      string     `json:"note"`
+
+	// Relasi
+	Actor *User `gorm:"foreignKey:ActorID" json:"actor,omitempty"`
+}
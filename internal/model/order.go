@@ -1,20 +1,89 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // Order mendefinisikan skema untuk tabel pesanan.
 type Order struct {
 	Basemodel
-	UserID          uuid.UUID `gorm:"not null" json:"user_id"`
-	TotalPrice      float64   `gorm:"not null" json:"total_price"`
-	Taxes           float64   `gorm:"default:0" json:"taxes"`
-	ShippingCost    float64   `gorm:"default:0" json:"shipping_cost"`
-	Status          string    `gorm:"default:'pending';not null" json:"status"`
-	Notes           string    `json:"notes"`
-	ShippingAddress string    `json:"shipping_address"`
+	UserID                  uuid.UUID        `gorm:"not null;uniqueIndex:idx_orders_user_checkout_key,where:checkout_key <> ''" json:"user_id"`
+	CheckoutKey             string           `gorm:"uniqueIndex:idx_orders_user_checkout_key" json:"-"`                                // Idempotency-Key checkout yang membuat pesanan ini
+	OrderNumber             string           `gorm:"uniqueIndex:idx_orders_order_number,where:order_number <> ''" json:"order_number"` // Nomor pesanan yang mudah dibaca, misal NGB-2026-000123
+	Subtotal                float64          `gorm:"default:0" json:"subtotal"`                                                        // Jumlah harga item sebelum potongan
+	DiscountTotal           float64          `gorm:"default:0" json:"discount_total"`                                                  // Total semua potongan harga
+	CouponCode              string           `json:"coupon_code,omitempty"`
+	TotalPrice              float64          `gorm:"not null" json:"total_price"`
+	Taxes                   float64          `gorm:"default:0" json:"taxes"`
+	TaxInclusive            bool             `gorm:"default:false" json:"tax_inclusive"` // Taxes sudah termasuk di harga item, bukan tambahan
+	ShippingCost            float64          `gorm:"default:0" json:"shipping_cost"`
+	WalletAmount            float64          `gorm:"default:0" json:"wallet_amount"`    // Bagian total yang dibayar dengan saldo dompet
+	PointsRedeemed          int              `gorm:"default:0" json:"points_redeemed"`  // Poin loyalti yang ditukar menjadi potongan
+	CancelledAmount         float64          `gorm:"default:0" json:"cancelled_amount"` // Nilai item dan ongkir yang dibatalkan setelah dibayar
+	Status                  string           `gorm:"default:'pending';not null" json:"status"`
+	Notes                   string           `json:"notes"`
+	ShippingAddress         string           `json:"shipping_address"`
+	ShippingAddressSnapshot *AddressSnapshot `gorm:"type:jsonb" json:"shipping_address_snapshot,omitempty"` // Salinan alamat dari buku alamat saat checkout
+	ShippingPostalCode      string           `json:"shipping_postal_code,omitempty"`
+	ShippingCourier         string           `json:"shipping_courier,omitempty"` // Kode kurir yang dipilih, misal jne
+	ShippingService         string           `json:"shipping_service,omitempty"` // Kode layanan kurir, misal REG
+
+	// Faktur diterbitkan sekali saat pembayaran berhasil
+	InvoiceNumber   string     `json:"invoice_number,omitempty"`
+	InvoicePath     string     `json:"-"` // Lokasi file PDF faktur di storage
+	InvoiceIssuedAt *time.Time `json:"invoice_issued_at,omitempty"`
+
+	ShippedAt   *time.Time `json:"shipped_at,omitempty"`   // Saat paket pertama dikirim
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // Saat pesanan ditandai selesai, awal masa retur
+
+	// Pembatalan
+	CancelReason string     `json:"cancel_reason,omitempty"`
+	CancelledBy  string     `json:"cancelled_by,omitempty"` // customer, admin, atau system
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
 
 	// Relasi
 	User       User        `gorm:"foreignKey:UserID" json:"user"`
 	OrderItems []OrderItem `gorm:"foreignKey:OrderID" json:"order_items"`
 	Payment    Payment     `gorm:"foreignKey:OrderID" json:"payment,omitempty"`
+	Shipments  []Shipment  `gorm:"foreignKey:OrderID" json:"shipments,omitempty"`
+}
+
+// AmountDue adalah sisa tagihan yang harus dibayar lewat payment gateway.
+func (o Order) AmountDue() float64 {
+	return o.TotalPrice - o.WalletAmount
+}
+
+// NetTotal adalah total pesanan setelah dikurangi nilai item yang dibatalkan.
+func (o Order) NetTotal() float64 {
+	return o.TotalPrice - o.CancelledAmount
+}
+
+// HasShippableItems menandakan pesanan berisi setidaknya satu buku fisik yang perlu dikirim.
+// OrderItems harus dimuat bersama Book.
+func (o Order) HasShippableItems() bool {
+	for _, item := range o.OrderItems {
+		if item.Book.IsShippable() {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderSequence menyimpan nomor urut pesanan terakhir untuk setiap tahun.
+type OrderSequence struct {
+	Year       int `gorm:"primaryKey;autoIncrement:false"`
+	LastNumber int `gorm:"not null"`
+}
+
+// FormatOrderNumber menyusun nomor pesanan dari tahun dan nomor urutnya.
+func FormatOrderNumber(year, number int) string {
+	return fmt.Sprintf("NGB-%d-%06d", year, number)
+}
+
+// FormatInvoiceNumber menyusun nomor faktur dari nomor pesanan.
+func FormatInvoiceNumber(orderNumber string) string {
+	return "INV-" + orderNumber
 }
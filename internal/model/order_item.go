@@ -2,17 +2,47 @@ package model
 
 import "github.com/google/uuid"
 
+// Jenis item pesanan.
+const (
+	OrderItemKindPurchase         = "purchase"
+	OrderItemKindRental           = "rental"
+	OrderItemKindRentalExtension  = "rental_extension"
+	OrderItemKindRentalConversion = "rental_conversion"
+)
+
 // OrderItem mendefinisikan skema untuk setiap item dalam pesanan.
 type OrderItem struct {
 	Basemodel
 	OrderID  uuid.UUID `gorm:"not null" json:"order_id"`
 	BookID   uuid.UUID `gorm:"not null" json:"book_id"`
 	Quantity int       `gorm:"not null" json:"quantity"`
-	Price    float64   `gorm:"not null" json:"price"`
+	// Kuantitas yang dibatalkan setelah pesanan dibayar, misalnya karena stok terlambat datang
+	CancelledQuantity int     `gorm:"default:0" json:"cancelled_quantity"`
+	Price             float64 `gorm:"not null" json:"price"`     // Harga satuan
+	Discount          float64 `gorm:"default:0" json:"discount"` // Total potongan untuk baris ini
+
+	// Sewa ebook
+	Kind          string     `gorm:"default:'purchase';not null" json:"kind"`
+	RentalDays    int        `gorm:"default:0" json:"rental_days,omitempty"`
+	EntitlementID *uuid.UUID `gorm:"type:uuid" json:"entitlement_id,omitempty"` // Sewa yang diperpanjang atau dikonversi
+
+	FlashSaleItemID *uuid.UUID `gorm:"type:uuid" json:"flash_sale_item_id,omitempty"` // Diisi jika dibeli dengan harga flash sale
+
+	// PPN per baris
+	TaxClass  string  `json:"tax_class"`
+	TaxRate   float64 `gorm:"default:0" json:"tax_rate"`   // Persen
+	TaxBase   float64 `gorm:"default:0" json:"tax_base"`   // Dasar pengenaan pajak (DPP)
+	TaxAmount float64 `gorm:"default:0" json:"tax_amount"` // Termasuk di harga jika pesanan memakai harga termasuk pajak
 
 	// Relasi
-	Order Order `gorm:"foreignKey:OrderID" json:"-"`
-	Book  Book  `gorm:"foreignKey:BookID" json:"book"`
+	Order     Order               `gorm:"foreignKey:OrderID" json:"-"`
+	Book      Book                `gorm:"foreignKey:BookID" json:"book"`
+	Discounts []OrderItemDiscount `gorm:"foreignKey:OrderItemID" json:"discounts,omitempty"` // Rincian sumber Discount
+}
+
+// ActiveQuantity adalah kuantitas yang masih harus dipenuhi, yaitu yang tidak dibatalkan.
+func (i OrderItem) ActiveQuantity() int {
+	return i.Quantity - i.CancelledQuantity
 }
 
 // TableName secara eksplisit memberitahu GORM nama tabel yang benar.
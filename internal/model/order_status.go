@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status pesanan.
+const (
+	OrderStatusPending          = "pending"          // Menunggu pembayaran
+	OrderStatusChallenge        = "challenge"        // Pembayaran kartu ditahan fraud detection Midtrans
+	OrderStatusProcessing       = "diproses"         // Sudah dibayar, sedang disiapkan
+	OrderStatusPartiallyShipped = "dikirim_sebagian" // Sebagian buku fisik sudah dikirim, sisanya menunggu
+	OrderStatusShipped          = "dikirim"          // Semua buku fisik yang tidak dibatalkan sudah dikirim
+	OrderStatusCompleted        = "selesai"
+	OrderStatusCancelled        = "batal"
+)
+
+// Pihak yang mengubah status pesanan.
+const (
+	OrderActorCustomer = "customer"
+	OrderActorAdmin    = "admin"
+	OrderActorSystem   = "system" // Notifikasi pembayaran, scheduler, atau pelacakan kurir
+)
+
+// orderTransitions adalah perpindahan status pesanan yang diizinkan beserta pihak yang boleh
+// melakukannya. Status lunas hanya bisa dicapai lewat pembayaran agar stok selalu terpotong,
+// dan pesanan selesai atau batal tidak bisa dibuka kembali.
+var orderTransitions = map[string]map[string][]string{
+	OrderStatusPending: {
+		OrderStatusProcessing: {OrderActorSystem},
+		OrderStatusChallenge:  {OrderActorSystem},
+		OrderStatusCancelled:  {OrderActorCustomer, OrderActorAdmin, OrderActorSystem},
+	},
+	OrderStatusChallenge: {
+		OrderStatusProcessing: {OrderActorSystem},
+		OrderStatusCancelled:  {OrderActorAdmin, OrderActorSystem},
+	},
+	OrderStatusProcessing: {
+		OrderStatusPartiallyShipped: {OrderActorAdmin},
+		OrderStatusShipped:          {OrderActorAdmin},
+		OrderStatusCompleted:        {OrderActorAdmin, OrderActorSystem},
+		OrderStatusCancelled:        {OrderActorCustomer, OrderActorAdmin},
+	},
+	// Sisa item pesanan yang dikirim sebagian hanya bisa dikirim atau dibatalkan per item
+	OrderStatusPartiallyShipped: {
+		OrderStatusShipped: {OrderActorAdmin},
+	},
+	OrderStatusShipped: {
+		OrderStatusCompleted: {OrderActorAdmin, OrderActorSystem},
+		OrderStatusCancelled: {OrderActorAdmin},
+	},
+}
+
+// CanTransitionOrder menandakan actor boleh mengubah status pesanan dari from ke to.
+func CanTransitionOrder(from, to, actor string) bool {
+	for _, allowed := range orderTransitions[from][to] {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusHistory mencatat satu perubahan status pesanan.
+type OrderStatusHistory struct {
+	Basemodel
+	OrderID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	FromStatus string     `json:"from_status"` // Kosong untuk pesanan yang baru dibuat
+	ToStatus   string     `gorm:"not null" json:"to_status"`
+	Actor      string     `gorm:"not null" json:"actor"`               // customer, admin, atau system
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actor_id,omitempty"` // Pengguna yang melakukan perubahan
+	Note       string     `json:"note,omitempty"`
+	ChangedAt  time.Time  `gorm:"not null" json:"changed_at"`
+}
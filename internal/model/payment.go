@@ -22,6 +22,11 @@ type Payment struct {
 	VerifiedBy             *uint     `json:"verified_by"`
 	ExpiresAt              time.Time `json:"expires_at"`
 
+	// Sesi Snap yang sudah dibuat. Midtrans menolak order_id yang sama dua kali, jadi checkout yang
+	// diulang mengembalikan sesi ini alih-alih membuat transaksi baru.
+	SnapToken       string `json:"-"`
+	SnapRedirectURL string `json:"-"`
+
 	// Relasi
 	Order    *Order `gorm:"foreignKey:OrderID" json:"order"`
 	Verifier User   `gorm:"foreignKey:VerifiedBy" json:"verifier,omitempty"`
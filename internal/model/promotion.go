@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Jenis promosi.
+const (
+	PromotionTypeBuyXCheapestFree = "buy_x_cheapest_free" // Beli Quantity buku, yang termurah gratis
+	PromotionTypeMultiBuy         = "multi_buy"           // Quantity buku seharga Price
+	PromotionTypeBundle           = "bundle"              // Paket berisi Books seharga Price
+)
+
+// Promotion mendefinisikan aturan promosi yang diterapkan otomatis saat menghitung harga.
+type Promotion struct {
+	Basemodel
+	Name        string     `gorm:"not null" json:"name"`
+	Description string     `json:"description"`
+	Type        string     `gorm:"not null" json:"type"`
+	Quantity    int        `gorm:"default:0" json:"quantity"` // Jumlah buku per paket untuk buy_x_cheapest_free dan multi_buy
+	Price       float64    `gorm:"default:0" json:"price"`    // Harga paket untuk multi_buy dan bundle
+	StartsAt    *time.Time `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	IsActive    bool       `gorm:"default:true;not null" json:"is_active"`
+
+	// Untuk buy_x_cheapest_free dan multi_buy: batasan buku yang ikut promosi, kosong berarti semua buku.
+	// Untuk bundle: Books adalah isi paket.
+	Categories []Category `gorm:"many2many:promotion_categories" json:"categories"`
+	Books      []Book     `gorm:"many2many:promotion_books" json:"books"`
+}
+
+// IsRunning menandakan promosi sedang berlaku pada waktu tertentu.
+func (p Promotion) IsRunning(at time.Time) bool {
+	if !p.IsActive {
+		return false
+	}
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	return p.EndsAt == nil || at.Before(*p.EndsAt)
+}
+
+// AppliesTo mengecek apakah sebuah buku bisa ikut promosi buy_x_cheapest_free atau multi_buy.
+func (p Promotion) AppliesTo(book Book) bool {
+	if len(p.Categories) == 0 && len(p.Books) == 0 {
+		return true
+	}
+	for _, b := range p.Books {
+		if b.ID == book.ID {
+			return true
+		}
+	}
+	for _, cat := range p.Categories {
+		if cat.ID == book.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderItemDiscount mencatat sumber setiap potongan pada sebuah item pesanan.
+type OrderItemDiscount struct {
+	Basemodel
+	OrderItemID uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	PromotionID *uuid.UUID `gorm:"type:uuid;index" json:"promotion_id,omitempty"`
+	CouponID    *uuid.UUID `gorm:"type:uuid;index" json:"coupon_id,omitempty"`
+	Label       string     `json:"label"`
+	Amount      float64    `gorm:"not null" json:"amount"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Jenis posisi baca yang didukung.
+const (
+	PositionTypePage = "page"
+	PositionTypeCFI  = "cfi"
+)
+
+// ReadingProgress mendefinisikan skema untuk posisi baca terakhir pengguna pada sebuah ebook.
+// Satu pengguna hanya memiliki satu record per buku, disinkronkan antar perangkat.
+type ReadingProgress struct {
+	Basemodel
+	UserID          uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_reading_progress_user_book" json:"user_id"`
+	BookID          uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_reading_progress_user_book" json:"book_id"`
+	Position        string    `gorm:"not null" json:"position"`      // Nomor halaman atau EPUB CFI
+	PositionType    string    `gorm:"not null" json:"position_type"` // page | cfi
+	Percentage      float64   `gorm:"default:0" json:"percentage"`
+	Device          string    `json:"device"`
+	ClientUpdatedAt time.Time `gorm:"not null" json:"client_updated_at"` // Waktu perubahan di perangkat, dipakai untuk resolusi konflik
+
+	// Relasi
+	Book Book `gorm:"foreignKey:BookID" json:"-"`
+}
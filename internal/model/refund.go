@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tujuan pengembalian dana.
+const (
+	RefundMethodOriginal    = "original_payment" // Kembali ke metode pembayaran asal
+	RefundMethodStoreCredit = "store_credit"     // Dikreditkan ke saldo dompet
+)
+
+// Status pengembalian dana. Bagian saldo dompet langsung dikreditkan, jadi hanya refund dengan
+// bagian payment gateway yang melewati status pending.
+const (
+	RefundStatusPending   = "pending"   // Tercatat, menunggu dikirim ke payment gateway
+	RefundStatusCompleted = "completed" // Seluruh dana sudah dikembalikan
+	RefundStatusFailed    = "failed"    // Payment gateway terus menolak, perlu ditangani manual
+)
+
+// Refund mencatat satu pengembalian dana untuk sebuah pesanan. Dana bisa terbagi antara
+// payment gateway dan saldo dompet, misalnya untuk pesanan yang dibayar sebagian dengan saldo.
+type Refund struct {
+	Basemodel
+	OrderID       uuid.UUID `gorm:"type:uuid;not null;index" json:"order_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Method        string    `gorm:"not null" json:"method"`
+	Amount        float64   `gorm:"not null" json:"amount"`
+	GatewayAmount float64   `gorm:"default:0" json:"gateway_amount"` // Bagian yang dikembalikan lewat payment gateway
+	WalletAmount  float64   `gorm:"default:0" json:"wallet_amount"`  // Bagian yang dikreditkan ke saldo dompet
+	Reason        string    `json:"reason"`
+
+	Status       string     `gorm:"default:'completed';not null;index" json:"status"`
+	Attempts     int        `gorm:"default:0" json:"attempts"` // Jumlah percobaan refund ke payment gateway
+	GatewayError string     `json:"gateway_error,omitempty"`   // Error terakhir dari payment gateway
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
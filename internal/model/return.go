@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status pengajuan retur (RMA).
+const (
+	ReturnStatusRequested = "requested" // Diajukan pembeli, menunggu tinjauan admin
+	ReturnStatusApproved  = "approved"  // Disetujui, menunggu barang dikirim balik
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received" // Barang sudah diterima gudang
+	ReturnStatusRefunded  = "refunded"
+	ReturnStatusCancelled = "cancelled" // Dibatalkan pembeli sebelum ditinjau
+)
+
+// returnTransitions adalah perpindahan status retur yang diizinkan.
+var returnTransitions = map[string][]string{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected, ReturnStatusCancelled},
+	ReturnStatusApproved:  {ReturnStatusReceived},
+	ReturnStatusReceived:  {ReturnStatusRefunded},
+}
+
+// Perlakuan barang retur setelah diterima.
+const (
+	ReturnDispositionRestock  = "restock"   // Barang layak jual, stok ditambah kembali
+	ReturnDispositionWriteOff = "write_off" // Barang rusak, tidak masuk stok
+)
+
+// ReturnRequest adalah satu pengajuan retur untuk sebagian atau seluruh item sebuah pesanan.
+type ReturnRequest struct {
+	Basemodel
+	RMANumber    string     `gorm:"uniqueIndex;not null" json:"rma_number"` // Misal RMA-NGB-2026-000123-1
+	OrderID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status       string     `gorm:"default:'requested';not null;index" json:"status"`
+	Reason       string     `gorm:"not null" json:"reason"`
+	CustomerNote string     `json:"customer_note,omitempty"`
+	AdminNote    string     `json:"admin_note,omitempty"`
+	RefundMethod string     `json:"refund_method"`
+	RefundAmount float64    `gorm:"default:0" json:"refund_amount"` // Jumlah dari semua item, termasuk PPN jika pajak ditambahkan di luar harga
+	RefundID     *uuid.UUID `gorm:"type:uuid" json:"refund_id,omitempty"`
+	ReviewedBy   *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"` // Admin yang menyetujui atau menolak
+	ApprovedAt   *time.Time `json:"approved_at,omitempty"`
+	RejectedAt   *time.Time `json:"rejected_at,omitempty"`
+	ReceivedAt   *time.Time `json:"received_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+
+	// Relasi
+	Order  Order         `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	Items  []ReturnItem  `gorm:"foreignKey:ReturnRequestID" json:"items"`
+	Photos []ReturnPhoto `gorm:"foreignKey:ReturnRequestID" json:"photos"`
+}
+
+// CanTransition menandakan retur boleh berpindah dari status sekarang ke status tujuan.
+func (r ReturnRequest) CanTransition(to string) bool {
+	for _, next := range returnTransitions[r.Status] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen menandakan retur masih berjalan atau sudah selesai, sehingga item di dalamnya tidak bisa diretur lagi.
+func (r ReturnRequest) IsOpen() bool {
+	return r.Status != ReturnStatusRejected && r.Status != ReturnStatusCancelled
+}
+
+// ReturnItem adalah item pesanan yang diretur beserta jumlah dan nilai pengembaliannya.
+type ReturnItem struct {
+	Basemodel
+	ReturnRequestID uuid.UUID `gorm:"type:uuid;not null;index" json:"return_request_id"`
+	OrderItemID     uuid.UUID `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	Quantity        int       `gorm:"not null" json:"quantity"`
+	RefundAmount    float64   `gorm:"default:0" json:"refund_amount"`
+	Disposition     string    `json:"disposition,omitempty"` // Diisi saat barang diterima
+
+	// Relasi
+	OrderItem OrderItem `gorm:"foreignKey:OrderItemID" json:"order_item"`
+}
+
+// ReturnPhoto adalah foto bukti kondisi barang yang dilampirkan pembeli.
+type ReturnPhoto struct {
+	Basemodel
+	ReturnRequestID uuid.UUID `gorm:"type:uuid;not null;index" json:"return_request_id"`
+	URL             string    `gorm:"not null" json:"url"`
+}
+
+// FormatRMANumber menyusun nomor retur dari nomor pesanan dan urutan retur pesanan tersebut.
+func FormatRMANumber(orderNumber string, seq int) string {
+	return fmt.Sprintf("RMA-%s-%d", orderNumber, seq)
+}
+
+// ReturnSummaryRow adalah rekap retur per perlakuan barang untuk laporan.
+type ReturnSummaryRow struct {
+	Disposition  string  `json:"disposition"`
+	Returns      int     `json:"returns"`
+	Quantity     int     `json:"quantity"`
+	RefundAmount float64 `json:"refund_amount"`
+}
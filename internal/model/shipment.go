@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status pengiriman paket, juga dipakai sebagai status setiap event pelacakan.
+const (
+	ShipmentStatusPickedUp       = "picked_up"        // Paket diserahkan ke kurir
+	ShipmentStatusInTransit      = "in_transit"       // Dalam perjalanan antar-gudang kurir
+	ShipmentStatusOutForDelivery = "out_for_delivery" // Dibawa kurir ke alamat tujuan
+	ShipmentStatusDelivered      = "delivered"
+	ShipmentStatusFailed         = "failed" // Gagal diantar atau dikembalikan ke pengirim
+)
+
+// Sumber event pelacakan.
+const (
+	ShipmentEventSourceAdmin   = "admin"   // Dicatat manual oleh admin
+	ShipmentEventSourceCourier = "courier" // Diambil dari API pelacakan kurir
+)
+
+// Shipment adalah paket yang dikirim untuk sebuah pesanan beserta nomor resinya.
+type Shipment struct {
+	Basemodel
+	OrderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	Courier        string     `gorm:"not null" json:"courier"` // Kode kurir, misal jne
+	Service        string     `json:"service"`                 // Kode layanan kurir, misal REG
+	TrackingNumber string     `gorm:"not null;index" json:"tracking_number"`
+	Status         string     `gorm:"default:'picked_up';not null" json:"status"`
+	ShippedAt      time.Time  `gorm:"not null" json:"shipped_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	LastPolledAt   *time.Time `json:"-"` // Terakhir kali status diambil dari API kurir
+
+	// Relasi
+	Items  []ShipmentItem  `gorm:"foreignKey:ShipmentID" json:"items"` // Kuantitas item pesanan di dalam paket
+	Events []ShipmentEvent `gorm:"foreignKey:ShipmentID" json:"events"`
+}
+
+// IsFinal menandakan pengiriman sudah berakhir sehingga tidak perlu dilacak lagi.
+func (s Shipment) IsFinal() bool {
+	return s.Status == ShipmentStatusDelivered || s.Status == ShipmentStatusFailed
+}
+
+// ShipmentItem adalah sebagian atau seluruh kuantitas satu item pesanan yang dikirim dalam sebuah paket.
+type ShipmentItem struct {
+	Basemodel
+	ShipmentID  uuid.UUID `gorm:"type:uuid;not null;index" json:"shipment_id"`
+	OrderItemID uuid.UUID `gorm:"type:uuid;not null;index" json:"order_item_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+}
+
+// ShipmentEvent adalah satu titik di linimasa pelacakan paket.
+type ShipmentEvent struct {
+	Basemodel
+	ShipmentID  uuid.UUID `gorm:"type:uuid;not null;index" json:"shipment_id"`
+	Status      string    `gorm:"not null" json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `gorm:"not null" json:"occurred_at"`
+	Source      string    `gorm:"not null" json:"source"` // admin atau courier
+}
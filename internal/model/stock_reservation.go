@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status reservasi stok.
+const (
+	ReservationStatusActive    = "active"
+	ReservationStatusCommitted = "committed" // Pembayaran berhasil, stok sudah dipotong
+	ReservationStatusReleased  = "released"  // Dilepas karena pesanan batal atau keranjang direservasi ulang
+	ReservationStatusExpired   = "expired"
+)
+
+// StockReservation menahan sejumlah stok buku fisik untuk keranjang atau pesanan
+// selama waktu tertentu agar tidak dibeli pengguna lain.
+type StockReservation struct {
+	Basemodel
+	BookID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"book_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	OrderID   *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"` // Kosong selama reservasi masih milik keranjang
+	Quantity  int        `gorm:"not null" json:"quantity"`
+	Status    string     `gorm:"default:'active';not null;index" json:"status"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	Shortfall int        `gorm:"not null;default:0" json:"shortfall"` // Kuantitas yang tidak terpotong karena stok habis saat pembayaran
+
+	// Relasi
+	Book Book `gorm:"foreignKey:BookID" json:"-"`
+}
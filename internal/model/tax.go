@@ -0,0 +1,21 @@
+package model
+
+// Kelas pajak produk. Tarif PPN per kelas diatur lewat konfigurasi.
+const (
+	TaxClassPhysicalBook = "physical_book"
+	TaxClassEbook        = "ebook"
+	TaxClassExempt       = "exempt" // Buku pelajaran dan pendidikan yang dibebaskan dari PPN
+	TaxClassNone         = "none"   // Bukan objek PPN, misal gift card yang setara uang tunai
+)
+
+// TaxSummaryRow adalah ringkasan pajak untuk satu kelas, tarif, dan cara penetapan harga dalam laporan.
+type TaxSummaryRow struct {
+	TaxClass     string  `json:"tax_class"`
+	TaxRate      float64 `json:"tax_rate"`
+	TaxInclusive bool    `json:"tax_inclusive"` // Sesuai yang tersimpan di pesanan, bukan konfigurasi saat ini
+	OrderCount   int64   `json:"order_count"`
+	Quantity     int64   `json:"quantity"`
+	NetSales     float64 `json:"net_sales"` // Nilai penjualan setelah potongan, sebagaimana dibayar pembeli di luar PPN eksklusif
+	TaxBase      float64 `json:"tax_base"`  // Dasar pengenaan pajak (DPP)
+	TaxAmount    float64 `json:"tax_amount"`
+}
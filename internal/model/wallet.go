@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Arah mutasi saldo dompet.
+const (
+	WalletCredit = "credit"
+	WalletDebit  = "debit"
+)
+
+// Sumber mutasi saldo dompet.
+const (
+	WalletSourceRefund           = "refund"
+	WalletSourceGiftCard         = "gift_card"
+	WalletSourceAdminAdjustment  = "admin_adjustment"
+	WalletSourceCheckout         = "checkout"          // Saldo dipakai untuk membayar pesanan
+	WalletSourceCheckoutReversal = "checkout_reversal" // Saldo dikembalikan karena pesanan batal
+)
+
+// Wallet adalah saldo kredit toko milik seorang pengguna.
+type Wallet struct {
+	Basemodel
+	UserID  uuid.UUID `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"`
+	Balance float64   `gorm:"default:0;not null;check:balance >= 0" json:"balance"`
+}
+
+// WalletTransaction adalah satu baris buku besar dompet. Saldo hanya berubah bersama baris ini.
+type WalletTransaction struct {
+	Basemodel
+	WalletID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"wallet_id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Type         string     `gorm:"not null" json:"type"`
+	Source       string     `gorm:"not null" json:"source"`
+	Amount       float64    `gorm:"not null" json:"amount"`
+	BalanceAfter float64    `gorm:"not null" json:"balance_after"`
+	OrderID      *uuid.UUID `gorm:"type:uuid;index" json:"order_id,omitempty"`
+	GiftCardID   *uuid.UUID `gorm:"type:uuid" json:"gift_card_id,omitempty"`
+	Note         string     `json:"note"`
+}
+
+// Status gift card.
+const (
+	GiftCardStatusIssued   = "issued"
+	GiftCardStatusRedeemed = "redeemed"
+	GiftCardStatusVoided   = "voided" // Dibatalkan karena pesanan pembelinya batal
+)
+
+// GiftCard adalah kode voucher yang bisa ditukar menjadi saldo dompet.
+type GiftCard struct {
+	Basemodel
+	Code          string     `gorm:"uniqueIndex;not null" json:"code"`
+	Amount        float64    `gorm:"not null" json:"amount"`
+	Status        string     `gorm:"default:'issued';not null" json:"status"`
+	BookID        uuid.UUID  `gorm:"type:uuid;not null" json:"book_id"` // Produk gift card yang dibeli
+	OrderID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"order_id"`
+	PurchasedByID uuid.UUID  `gorm:"type:uuid;not null;index" json:"purchased_by_id"`
+	RedeemedByID  *uuid.UUID `gorm:"type:uuid" json:"redeemed_by_id,omitempty"`
+	RedeemedAt    *time.Time `json:"redeemed_at,omitempty"`
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AddressRepository mendefinisikan kontrak untuk buku alamat pengguna.
+type AddressRepository interface {
+	FindByUserID(userID uuid.UUID) ([]model.Address, error)
+	FindByIDAndUserID(id, userID uuid.UUID) (model.Address, error)
+	FindDefault(userID uuid.UUID) (model.Address, error)
+	Create(address *model.Address) (*model.Address, error)
+	Update(address *model.Address) (*model.Address, error)
+	Delete(address *model.Address) error
+}
+
+type addressRepository struct {
+	db *gorm.DB
+}
+
+// NewAddressRepository adalah constructor untuk addressRepository.
+func NewAddressRepository(db *gorm.DB) AddressRepository {
+	return &addressRepository{db: db}
+}
+
+// FindByUserID mengambil semua alamat pengguna, alamat utama lebih dulu.
+func (r *addressRepository) FindByUserID(userID uuid.UUID) ([]model.Address, error) {
+	var addresses []model.Address
+	err := r.db.Where("user_id = ?", userID).Order("is_default desc, created_at desc").Find(&addresses).Error
+	return addresses, err
+}
+
+func (r *addressRepository) FindByIDAndUserID(id, userID uuid.UUID) (model.Address, error) {
+	var address model.Address
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&address).Error
+	return address, err
+}
+
+func (r *addressRepository) FindDefault(userID uuid.UUID) (model.Address, error) {
+	var address model.Address
+	err := r.db.Where("user_id = ? AND is_default = ?", userID, true).First(&address).Error
+	return address, err
+}
+
+// Create menyimpan alamat baru. Alamat pertama pengguna otomatis menjadi alamat utama.
+func (r *addressRepository) Create(address *model.Address) (*model.Address, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Address{}).Where("user_id = ?", address.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefault = true
+		}
+		if err := clearDefaultAddress(tx, address); err != nil {
+			return err
+		}
+		return tx.Create(address).Error
+	})
+	return address, err
+}
+
+// Update menyimpan perubahan alamat. Menjadikan alamat ini utama akan melepas alamat utama sebelumnya.
+func (r *addressRepository) Update(address *model.Address) (*model.Address, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := clearDefaultAddress(tx, address); err != nil {
+			return err
+		}
+		return tx.Save(address).Error
+	})
+	return address, err
+}
+
+// Delete menghapus alamat. Jika yang dihapus alamat utama, alamat terbaru menggantikannya.
+func (r *addressRepository) Delete(address *model.Address) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+		var next model.Address
+		err := tx.Where("user_id = ?", address.UserID).Order("created_at desc").First(&next).Error
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+}
+
+// clearDefaultAddress melepas status utama dari alamat lain milik pengguna yang sama.
+func clearDefaultAddress(tx *gorm.DB, address *model.Address) error {
+	if !address.IsDefault {
+		return nil
+	}
+	return tx.Model(&model.Address{}).
+		Where("user_id = ? AND id <> ? AND is_default = ?", address.UserID, address.ID, true).
+		Update("is_default", false).Error
+}
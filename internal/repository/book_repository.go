@@ -2,6 +2,7 @@ package repository
 
 import (
 	"ngabaca/internal/model"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -12,6 +13,7 @@ import (
 type BookRepository interface {
 	FindAll() ([]model.Book, error)
 	FindByID(id uuid.UUID) (model.Book, error)
+	FindByIDForUpdate(id uuid.UUID) (model.Book, error)
 	FindBySlug(slug string) (model.Book, error)
 	Create(book *model.Book) (*model.Book, error)
 	Update(book *model.Book) (*model.Book, error)
@@ -48,15 +50,107 @@ func NewBookRepository(db *gorm.DB) BookRepository {
 func (r *bookRepository) FindAll() ([]model.Book, error) {
 	var books []model.Book
 	err := r.db.Preload("Category").Find(&books).Error
+	if err != nil {
+		return books, err
+	}
+	err = r.fillComputedFields(books)
 	return books, err
 }
 
 func (r *bookRepository) FindByID(id uuid.UUID) (model.Book, error) {
 	var book model.Book
 	err := r.db.Preload("Category").First(&book, id).Error
+	if err != nil {
+		return book, err
+	}
+	books := []model.Book{book}
+	err = r.fillComputedFields(books)
+	return books[0], err
+}
+
+// FindByIDForUpdate mengambil buku sambil mengunci barisnya (SELECT ... FOR UPDATE).
+// Hanya bermakna jika dipanggil di dalam transaksi.
+func (r *bookRepository) FindByIDForUpdate(id uuid.UUID) (model.Book, error) {
+	var book model.Book
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id).Error
 	return book, err
 }
 
+// fillComputedFields mengisi kolom turunan yang tidak disimpan di tabel buku.
+func (r *bookRepository) fillComputedFields(books []model.Book) error {
+	if err := r.fillAvailableStock(books); err != nil {
+		return err
+	}
+	return r.fillFlashSales(books)
+}
+
+// fillAvailableStock mengisi AvailableStock dengan stok dikurangi reservasi yang masih aktif.
+func (r *bookRepository) fillAvailableStock(books []model.Book) error {
+	ids := make([]uuid.UUID, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	reserved, err := NewReservationRepository(r.db).ReservedQuantities(ids)
+	if err != nil {
+		return err
+	}
+	for i := range books {
+		available := books[i].Stock - reserved[books[i].ID]
+		if available < 0 {
+			available = 0
+		}
+		books[i].AvailableStock = available
+	}
+	return nil
+}
+
+// fillFlashSales mengisi penawaran flash sale yang sedang berlaku. Jika sebuah buku ada di beberapa
+// flash sale sekaligus, harga termurah yang dipakai. Item yang kuotanya habis tidak lagi ditawarkan.
+func (r *bookRepository) fillFlashSales(books []model.Book) error {
+	ids := make([]uuid.UUID, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	now := time.Now()
+	items, err := NewFlashSaleRepository(r.db).FindRunningItems(ids, now)
+	if err != nil {
+		return err
+	}
+
+	best := make(map[uuid.UUID]model.FlashSaleItem, len(items))
+	for _, item := range items {
+		if item.Quota > 0 && item.SoldCount >= item.Quota {
+			continue
+		}
+		if current, ok := best[item.BookID]; !ok || item.SalePrice < current.SalePrice {
+			best[item.BookID] = item
+		}
+	}
+
+	for i := range books {
+		item, ok := best[books[i].ID]
+		if !ok {
+			continue
+		}
+		offer := &model.FlashSaleOffer{
+			FlashSaleID:      item.FlashSaleID,
+			FlashSaleItemID:  item.ID,
+			Name:             item.FlashSale.Name,
+			SalePrice:        item.SalePrice,
+			EndsAt:           item.FlashSale.EndsAt,
+			EndsInSeconds:    int64(item.FlashSale.EndsAt.Sub(now).Seconds()),
+			Quota:            item.Quota,
+			PerCustomerLimit: item.PerCustomerLimit,
+		}
+		if item.Quota > 0 {
+			remaining := item.Quota - item.SoldCount
+			offer.Remaining = &remaining
+		}
+		books[i].FlashSale = offer
+	}
+	return nil
+}
+
 func (r *bookRepository) FindBySlug(slug string) (model.Book, error) {
 	var book model.Book
 	err := r.db.Preload("Category").Preload("Reviews.User").Where("slug = ?", slug).First(&book).Error
@@ -79,7 +173,9 @@ func (r *bookRepository) FindBySlug(slug string) (model.Book, error) {
 	book.AvgRating = result.AvgRating
 	book.ReviewCount = result.ReviewCount
 
-	return book, nil
+	books := []model.Book{book}
+	err = r.fillComputedFields(books)
+	return books[0], err
 }
 func (r *bookRepository) Create(book *model.Book) (*model.Book, error) {
 	err := r.db.Clauses(clause.Returning{}).Create(book).Error
@@ -113,6 +209,10 @@ func (r *bookRepository) Search(keyword string) ([]model.Book, error) {
 		Preload("Category").
 		Where("title ILIKE ? OR author ILIKE ?", searchPattern, searchPattern).
 		Find(&books).Error
+	if err != nil {
+		return books, err
+	}
 
+	err = r.fillComputedFields(books)
 	return books, err
 }
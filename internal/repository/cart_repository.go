@@ -6,13 +6,17 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CartRepository interface {
 	GetCartByUserID(userID uuid.UUID) (model.Cart, error)
 	AddItem(userID, bookID uuid.UUID, quantity int) error
+	SetItemQuantity(userID, bookID uuid.UUID, quantity int) error
 	UpdateCartItem(userID, itemID uuid.UUID, quantity int) error
 	RemoveItem(userID, itemID uuid.UUID) error
+	FindItems(userID uuid.UUID, itemIDs []uuid.UUID) ([]model.CartItem, error)
+	DeleteItems(userID uuid.UUID, itemIDs []uuid.UUID) error
 }
 
 type cartRepository struct {
@@ -23,23 +27,60 @@ func NewCartRepository(db *gorm.DB) CartRepository {
 	return &cartRepository{db: db}
 }
 
+// findOrCreateCart mengambil cart milik user, atau membuatnya jika belum ada
+// (misalnya untuk pengguna yang mendaftar lewat Google). Hanya dipanggil saat menulis ke keranjang.
+func findOrCreateCart(tx *gorm.DB, userID uuid.UUID) (model.Cart, error) {
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&model.Cart{UserID: userID}).Error; err != nil {
+		return model.Cart{}, err
+	}
+	var cart model.Cart
+	err := tx.Where("user_id = ?", userID).First(&cart).Error
+	return cart, err
+}
+
+// GetCartByUserID mengambil keranjang beserta isinya. Pengguna yang belum pernah mengisi keranjang
+// mendapat keranjang kosong tanpa ID; barisnya baru dibuat saat item pertama ditambahkan.
 func (r *cartRepository) GetCartByUserID(userID uuid.UUID) (model.Cart, error) {
 	var cart model.Cart
 	err := r.db.Preload("CartItems.Book").Where("user_id = ?", userID).First(&cart).Error
-	return cart, err
+	if err == gorm.ErrRecordNotFound {
+		return model.Cart{UserID: userID, CartItems: []model.CartItem{}}, nil
+	}
+	if err != nil {
+		return cart, err
+	}
+
+	// Isi stok tersedia untuk setiap buku di keranjang
+	bookIDs := make([]uuid.UUID, 0, len(cart.CartItems))
+	for _, item := range cart.CartItems {
+		bookIDs = append(bookIDs, item.BookID)
+	}
+	reserved, err := NewReservationRepository(r.db).ReservedQuantities(bookIDs)
+	if err != nil {
+		return cart, err
+	}
+	for i := range cart.CartItems {
+		book := &cart.CartItems[i].Book
+		book.AvailableStock = book.Stock - reserved[book.ID]
+		if book.AvailableStock < 0 {
+			book.AvailableStock = 0
+		}
+	}
+	return cart, nil
 }
 
 func (r *cartRepository) AddItem(userID, bookID uuid.UUID, quantity int) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		// 1. Dapatkan cart milik user
-		var cart model.Cart
-		if err := tx.Where("user_id = ?", userID).First(&cart).Error; err != nil {
-			return errors.New("cart not found for user")
+		cart, err := findOrCreateCart(tx, userID)
+		if err != nil {
+			return err
 		}
 
 		// 2. Cek apakah item sudah ada di keranjang
 		var item model.CartItem
-		err := tx.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&item).Error
+		err = tx.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&item).Error
 
 		if err == nil {
 			// Item sudah ada, update kuantitasnya
@@ -61,6 +102,27 @@ func (r *cartRepository) AddItem(userID, bookID uuid.UUID, quantity int) error {
 	})
 }
 
+// SetItemQuantity mengatur kuantitas sebuah buku di keranjang, menambahkannya jika belum ada.
+func (r *cartRepository) SetItemQuantity(userID, bookID uuid.UUID, quantity int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		cart, err := findOrCreateCart(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		var item model.CartItem
+		err = tx.Where("cart_id = ? AND book_id = ?", cart.ID, bookID).First(&item).Error
+		if err == gorm.ErrRecordNotFound {
+			return tx.Create(&model.CartItem{CartID: cart.ID, BookID: bookID, Quantity: quantity}).Error
+		}
+		if err != nil {
+			return err
+		}
+		item.Quantity = quantity
+		return tx.Save(&item).Error
+	})
+}
+
 // update such a decrease quantity or increase quantity
 func (r *cartRepository) UpdateCartItem(userID, itemID uuid.UUID, quantity int) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
@@ -106,3 +168,24 @@ func (r *cartRepository) RemoveItem(userID, itemID uuid.UUID) error {
 		return tx.Delete(&item).Error
 	})
 }
+
+// FindItems mengambil item keranjang milik pengguna. Jika itemIDs kosong, semua item dikembalikan.
+func (r *cartRepository) FindItems(userID uuid.UUID, itemIDs []uuid.UUID) ([]model.CartItem, error) {
+	var items []model.CartItem
+	query := r.db.Where("cart_id = (?)", r.db.Model(&model.Cart{}).Select("id").Where("user_id = ?", userID)).
+		Order("created_at asc")
+	if len(itemIDs) > 0 {
+		query = query.Where("id IN ?", itemIDs)
+	}
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// DeleteItems menghapus item tertentu dari keranjang milik pengguna.
+func (r *cartRepository) DeleteItems(userID uuid.UUID, itemIDs []uuid.UUID) error {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("cart_id = (?) AND id IN ?", r.db.Model(&model.Cart{}).Select("id").Where("user_id = ?", userID), itemIDs).
+		Delete(&model.CartItem{}).Error
+}
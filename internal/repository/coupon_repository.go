@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CouponStats adalah ringkasan pemakaian sebuah kupon.
+type CouponStats struct {
+	ActiveRedemptions   int64                    `json:"active_redemptions"`
+	ReleasedRedemptions int64                    `json:"released_redemptions"`
+	UniqueUsers         int64                    `json:"unique_users"`
+	TotalDiscount       float64                  `json:"total_discount"`
+	LastRedeemedAt      *time.Time               `json:"last_redeemed_at"`
+	RecentRedemptions   []model.CouponRedemption `json:"recent_redemptions"`
+}
+
+// CouponRepository mendefinisikan kontrak untuk kupon dan pemakaiannya.
+type CouponRepository interface {
+	FindAll() ([]model.Coupon, error)
+	FindByID(id uuid.UUID) (model.Coupon, error)
+	FindByCode(code string) (model.Coupon, error)
+	Create(coupon *model.Coupon) error
+	Update(coupon *model.Coupon) error
+	Delete(coupon *model.Coupon) error
+	IsCodeExist(code string, id uuid.UUID) (bool, error)
+
+	IncrementUsage(id uuid.UUID) (bool, error)
+	DecrementUsage(id uuid.UUID) error
+	CountUserRedemptions(couponID, userID uuid.UUID) (int64, error)
+	CreateRedemption(redemption *model.CouponRedemption) error
+	FindActiveRedemptionsByOrder(orderID uuid.UUID) ([]model.CouponRedemption, error)
+	ReleaseRedemption(id uuid.UUID) error
+	Stats(couponID uuid.UUID) (CouponStats, error)
+}
+
+type couponRepository struct {
+	db *gorm.DB
+}
+
+// NewCouponRepository adalah constructor untuk couponRepository.
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
+
+// NormalizeCouponCode menyeragamkan kode kupon agar tidak peka huruf besar/kecil.
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (r *couponRepository) FindAll() ([]model.Coupon, error) {
+	var coupons []model.Coupon
+	err := r.db.Preload("Categories").Preload("Books").Order("created_at desc").Find(&coupons).Error
+	return coupons, err
+}
+
+func (r *couponRepository) FindByID(id uuid.UUID) (model.Coupon, error) {
+	var coupon model.Coupon
+	err := r.db.Preload("Categories").Preload("Books").First(&coupon, id).Error
+	return coupon, err
+}
+
+func (r *couponRepository) FindByCode(code string) (model.Coupon, error) {
+	var coupon model.Coupon
+	err := r.db.Preload("Categories").Preload("Books").
+		Where("code = ?", NormalizeCouponCode(code)).
+		First(&coupon).Error
+	return coupon, err
+}
+
+func (r *couponRepository) Create(coupon *model.Coupon) error {
+	return r.db.Create(coupon).Error
+}
+
+// Update menyimpan kupon sekaligus mengganti daftar kategori dan buku yang dibatasi.
+func (r *couponRepository) Update(coupon *model.Coupon) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories", "Books").Save(coupon).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(coupon).Association("Categories").Replace(coupon.Categories); err != nil {
+			return err
+		}
+		return tx.Model(coupon).Association("Books").Replace(coupon.Books)
+	})
+}
+
+func (r *couponRepository) Delete(coupon *model.Coupon) error {
+	return r.db.Delete(coupon).Error
+}
+
+func (r *couponRepository) IsCodeExist(code string, id uuid.UUID) (bool, error) {
+	var count int64
+	query := r.db.Model(&model.Coupon{}).Where("code = ?", NormalizeCouponCode(code))
+	if id != uuid.Nil {
+		query = query.Where("id <> ?", id)
+	}
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// IncrementUsage menambah pemakaian kupon secara atomik selama batas total belum tercapai.
+// Update ini juga menahan baris kupon, sehingga checkout lain dengan kupon yang sama menunggu
+// sampai transaksi selesai sebelum menghitung pemakaian per pengguna.
+func (r *couponRepository) IncrementUsage(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Coupon{}).
+		Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", id).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *couponRepository) DecrementUsage(id uuid.UUID) error {
+	return r.db.Model(&model.Coupon{}).
+		Where("id = ? AND used_count > 0", id).
+		Update("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+func (r *couponRepository) CountUserRedemptions(couponID, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ? AND status = ?", couponID, userID, model.CouponRedemptionActive).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *couponRepository) CreateRedemption(redemption *model.CouponRedemption) error {
+	return r.db.Create(redemption).Error
+}
+
+func (r *couponRepository) FindActiveRedemptionsByOrder(orderID uuid.UUID) ([]model.CouponRedemption, error) {
+	var redemptions []model.CouponRedemption
+	err := r.db.Where("order_id = ? AND status = ?", orderID, model.CouponRedemptionActive).Find(&redemptions).Error
+	return redemptions, err
+}
+
+func (r *couponRepository) ReleaseRedemption(id uuid.UUID) error {
+	return r.db.Model(&model.CouponRedemption{}).Where("id = ?", id).
+		Update("status", model.CouponRedemptionReleased).Error
+}
+
+func (r *couponRepository) Stats(couponID uuid.UUID) (CouponStats, error) {
+	var stats CouponStats
+	base := func() *gorm.DB {
+		return r.db.Model(&model.CouponRedemption{}).Where("coupon_id = ?", couponID)
+	}
+
+	if err := base().Where("status = ?", model.CouponRedemptionActive).Count(&stats.ActiveRedemptions).Error; err != nil {
+		return stats, err
+	}
+	if err := base().Where("status = ?", model.CouponRedemptionReleased).Count(&stats.ReleasedRedemptions).Error; err != nil {
+		return stats, err
+	}
+	if err := base().Where("status = ?", model.CouponRedemptionActive).
+		Distinct("user_id").Count(&stats.UniqueUsers).Error; err != nil {
+		return stats, err
+	}
+	if err := base().Where("status = ?", model.CouponRedemptionActive).
+		Select("COALESCE(SUM(amount), 0)").Scan(&stats.TotalDiscount).Error; err != nil {
+		return stats, err
+	}
+	if err := base().Select("MAX(created_at)").Scan(&stats.LastRedeemedAt).Error; err != nil {
+		return stats, err
+	}
+	err := base().Order("created_at desc").Limit(20).Find(&stats.RecentRedemptions).Error
+	return stats, err
+}
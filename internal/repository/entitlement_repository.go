@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EntitlementRepository mendefinisikan kontrak untuk data hak akses ebook.
+type EntitlementRepository interface {
+	Create(entitlement *model.Entitlement) error
+	FindByID(id uuid.UUID) (model.Entitlement, error)
+	FindByUserID(userID uuid.UUID) ([]model.Entitlement, error)
+	FindActiveByUserAndBook(userID, bookID uuid.UUID) (model.Entitlement, error)
+	ExistsForOrder(orderID, bookID uuid.UUID) (bool, error)
+	ConsumeDownload(id uuid.UUID) (bool, error)
+	LogDownload(log *model.DownloadLog) error
+	Update(entitlement *model.Entitlement) error
+	ExpireRentals() (int64, error)
+	FindRentalsExpiringBefore(deadline time.Time) ([]model.Entitlement, error)
+	MarkExpiryNotified(id uuid.UUID) error
+	RevokeByOrder(orderID uuid.UUID) error
+}
+
+type entitlementRepository struct {
+	db *gorm.DB
+}
+
+// NewEntitlementRepository adalah constructor untuk entitlementRepository.
+func NewEntitlementRepository(db *gorm.DB) EntitlementRepository {
+	return &entitlementRepository{db: db}
+}
+
+func (r *entitlementRepository) Create(entitlement *model.Entitlement) error {
+	return r.db.Create(entitlement).Error
+}
+
+func (r *entitlementRepository) FindByID(id uuid.UUID) (model.Entitlement, error) {
+	var entitlement model.Entitlement
+	err := r.db.Preload("Book").Preload("User").First(&entitlement, id).Error
+	return entitlement, err
+}
+
+func (r *entitlementRepository) FindByUserID(userID uuid.UUID) ([]model.Entitlement, error) {
+	var entitlements []model.Entitlement
+	err := r.db.Preload("Book").
+		Where("user_id = ?", userID).
+		Order("created_at desc").
+		Find(&entitlements).Error
+	return entitlements, err
+}
+
+func (r *entitlementRepository) FindActiveByUserAndBook(userID, bookID uuid.UUID) (model.Entitlement, error) {
+	var entitlement model.Entitlement
+	err := r.db.Preload("Book").
+		Where("user_id = ? AND book_id = ? AND status = ?", userID, bookID, model.EntitlementStatusActive).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("created_at desc").
+		First(&entitlement).Error
+	return entitlement, err
+}
+
+func (r *entitlementRepository) ExistsForOrder(orderID, bookID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Entitlement{}).Where("order_id = ? AND book_id = ?", orderID, bookID).Count(&count).Error
+	return count > 0, err
+}
+
+// ConsumeDownload menambah hitungan unduhan secara atomik selama kuota belum habis.
+// Mengembalikan false jika kuota unduhan sudah habis.
+func (r *entitlementRepository) ConsumeDownload(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Entitlement{}).
+		Where("id = ? AND download_count < max_downloads", id).
+		Update("download_count", gorm.Expr("download_count + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *entitlementRepository) LogDownload(log *model.DownloadLog) error {
+	return r.db.Create(log).Error
+}
+
+func (r *entitlementRepository) Update(entitlement *model.Entitlement) error {
+	return r.db.Omit("Book", "User").Save(entitlement).Error
+}
+
+// ExpireRentals menandai semua sewa yang sudah lewat masa berlakunya sebagai kedaluwarsa.
+func (r *entitlementRepository) ExpireRentals() (int64, error) {
+	result := r.db.Model(&model.Entitlement{}).
+		Where("type = ? AND status = ? AND expires_at <= ?", model.EntitlementTypeRental, model.EntitlementStatusActive, time.Now()).
+		Update("status", model.EntitlementStatusExpired)
+	return result.RowsAffected, result.Error
+}
+
+// FindRentalsExpiringBefore mencari sewa aktif yang akan berakhir sebelum deadline dan belum diberi pengingat.
+func (r *entitlementRepository) FindRentalsExpiringBefore(deadline time.Time) ([]model.Entitlement, error) {
+	var entitlements []model.Entitlement
+	err := r.db.Preload("Book").Preload("User").
+		Where("type = ? AND status = ? AND expiry_notified_at IS NULL", model.EntitlementTypeRental, model.EntitlementStatusActive).
+		Where("expires_at > ? AND expires_at <= ?", time.Now(), deadline).
+		Find(&entitlements).Error
+	return entitlements, err
+}
+
+func (r *entitlementRepository) MarkExpiryNotified(id uuid.UUID) error {
+	return r.db.Model(&model.Entitlement{}).Where("id = ?", id).Update("expiry_notified_at", time.Now()).Error
+}
+
+// RevokeByOrder mencabut semua hak akses yang dibuat oleh satu pesanan.
+func (r *entitlementRepository) RevokeByOrder(orderID uuid.UUID) error {
+	return r.db.Model(&model.Entitlement{}).
+		Where("order_id = ? AND status <> ?", orderID, model.EntitlementStatusRevoked).
+		Update("status", model.EntitlementStatusRevoked).Error
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FlashSaleRepository mendefinisikan kontrak untuk flash sale dan kuotanya.
+type FlashSaleRepository interface {
+	FindAll() ([]model.FlashSale, error)
+	FindByID(id uuid.UUID) (model.FlashSale, error)
+	Create(sale *model.FlashSale) error
+	Update(sale *model.FlashSale) error
+	Delete(sale *model.FlashSale) error
+	ReplaceItems(saleID uuid.UUID, items []model.FlashSaleItem) error
+	FindRunningItems(bookIDs []uuid.UUID, at time.Time) ([]model.FlashSaleItem, error)
+
+	IncrementSold(itemID uuid.UUID, qty int) (bool, error)
+	DecrementSold(itemID uuid.UUID, qty int) error
+	SumUserQuantity(itemID, userID uuid.UUID) (int, error)
+	CreatePurchase(purchase *model.FlashSalePurchase) error
+	FindActivePurchasesByOrder(orderID uuid.UUID) ([]model.FlashSalePurchase, error)
+	ReleasePurchase(id uuid.UUID) error
+}
+
+type flashSaleRepository struct {
+	db *gorm.DB
+}
+
+// NewFlashSaleRepository adalah constructor untuk flashSaleRepository.
+func NewFlashSaleRepository(db *gorm.DB) FlashSaleRepository {
+	return &flashSaleRepository{db: db}
+}
+
+func (r *flashSaleRepository) FindAll() ([]model.FlashSale, error) {
+	var sales []model.FlashSale
+	err := r.db.Preload("Items.Book").Order("starts_at desc").Find(&sales).Error
+	return sales, err
+}
+
+func (r *flashSaleRepository) FindByID(id uuid.UUID) (model.FlashSale, error) {
+	var sale model.FlashSale
+	err := r.db.Preload("Items.Book").First(&sale, id).Error
+	return sale, err
+}
+
+// Create menyimpan flash sale beserta itemnya tanpa ikut menyimpan ulang data buku.
+func (r *flashSaleRepository) Create(sale *model.FlashSale) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(sale).Error; err != nil {
+			return err
+		}
+		for i := range sale.Items {
+			sale.Items[i].FlashSaleID = sale.ID
+			if err := tx.Omit("FlashSale", "Book").Create(&sale.Items[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *flashSaleRepository) Update(sale *model.FlashSale) error {
+	return r.db.Omit("Items").Save(sale).Error
+}
+
+func (r *flashSaleRepository) Delete(sale *model.FlashSale) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("flash_sale_id = ?", sale.ID).Delete(&model.FlashSaleItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(sale).Error
+	})
+}
+
+// ReplaceItems mengganti daftar buku dalam flash sale. Item untuk buku yang sama dipertahankan
+// agar jumlah terjual dan riwayat pembelian tetap merujuk ke baris yang sama.
+func (r *flashSaleRepository) ReplaceItems(saleID uuid.UUID, items []model.FlashSaleItem) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []model.FlashSaleItem
+		if err := tx.Where("flash_sale_id = ?", saleID).Find(&existing).Error; err != nil {
+			return err
+		}
+		byBook := make(map[uuid.UUID]model.FlashSaleItem, len(existing))
+		for _, item := range existing {
+			byBook[item.BookID] = item
+		}
+
+		keep := make(map[uuid.UUID]bool, len(items))
+		for _, item := range items {
+			item.FlashSaleID = saleID
+			if old, ok := byBook[item.BookID]; ok {
+				item.ID = old.ID
+				item.CreatedAt = old.CreatedAt
+				item.SoldCount = old.SoldCount
+			}
+			if err := tx.Omit("FlashSale", "Book").Save(&item).Error; err != nil {
+				return err
+			}
+			keep[item.ID] = true
+		}
+
+		for _, item := range existing {
+			if !keep[item.ID] {
+				if err := tx.Delete(&item).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// FindRunningItems mengambil item flash sale yang sedang berlangsung, bisa dibatasi ke buku tertentu.
+func (r *flashSaleRepository) FindRunningItems(bookIDs []uuid.UUID, at time.Time) ([]model.FlashSaleItem, error) {
+	var items []model.FlashSaleItem
+	query := r.db.Preload("FlashSale").
+		Joins("JOIN flash_sales ON flash_sales.id = flash_sale_items.flash_sale_id AND flash_sales.deleted_at IS NULL").
+		Where("flash_sales.is_active = ? AND flash_sales.starts_at <= ? AND flash_sales.ends_at > ?", true, at, at)
+	if bookIDs != nil {
+		if len(bookIDs) == 0 {
+			return items, nil
+		}
+		query = query.Where("flash_sale_items.book_id IN ?", bookIDs)
+	}
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// IncrementSold menambah jumlah terjual secara atomik selama kuota masih cukup. false berarti
+// sisa kuota sudah tidak cukup untuk qty, misalnya karena direbut checkout lain.
+func (r *flashSaleRepository) IncrementSold(itemID uuid.UUID, qty int) (bool, error) {
+	result := r.db.Model(&model.FlashSaleItem{}).
+		Where("id = ? AND (quota = 0 OR sold_count + ? <= quota)", itemID, qty).
+		Update("sold_count", gorm.Expr("sold_count + ?", qty))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *flashSaleRepository) DecrementSold(itemID uuid.UUID, qty int) error {
+	return r.db.Model(&model.FlashSaleItem{}).
+		Where("id = ?", itemID).
+		Update("sold_count", gorm.Expr("GREATEST(sold_count - ?, 0)", qty)).Error
+}
+
+func (r *flashSaleRepository) SumUserQuantity(itemID, userID uuid.UUID) (int, error) {
+	var total int
+	err := r.db.Model(&model.FlashSalePurchase{}).
+		Where("flash_sale_item_id = ? AND user_id = ? AND status = ?", itemID, userID, model.FlashSalePurchaseActive).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&total).Error
+	return total, err
+}
+
+func (r *flashSaleRepository) CreatePurchase(purchase *model.FlashSalePurchase) error {
+	return r.db.Create(purchase).Error
+}
+
+func (r *flashSaleRepository) FindActivePurchasesByOrder(orderID uuid.UUID) ([]model.FlashSalePurchase, error) {
+	var purchases []model.FlashSalePurchase
+	err := r.db.Where("order_id = ? AND status = ?", orderID, model.FlashSalePurchaseActive).Find(&purchases).Error
+	return purchases, err
+}
+
+func (r *flashSaleRepository) ReleasePurchase(id uuid.UUID) error {
+	return r.db.Model(&model.FlashSalePurchase{}).Where("id = ?", id).
+		Update("status", model.FlashSalePurchaseReleased).Error
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GiftCardRepository mendefinisikan kontrak untuk kode gift card.
+type GiftCardRepository interface {
+	Create(card *model.GiftCard) error
+	FindByCodeForUpdate(code string) (model.GiftCard, error)
+	FindByPurchaser(userID uuid.UUID) ([]model.GiftCard, error)
+	CountByOrderAndBook(orderID, bookID uuid.UUID) (int64, error)
+	MarkRedeemed(id, userID uuid.UUID, at time.Time) (bool, error)
+	FindByOrderForUpdate(orderID uuid.UUID) ([]model.GiftCard, error)
+	VoidIssuedByOrder(orderID uuid.UUID) error
+}
+
+type giftCardRepository struct {
+	db *gorm.DB
+}
+
+// NewGiftCardRepository adalah constructor untuk giftCardRepository.
+func NewGiftCardRepository(db *gorm.DB) GiftCardRepository {
+	return &giftCardRepository{db: db}
+}
+
+func (r *giftCardRepository) Create(card *model.GiftCard) error {
+	return r.db.Create(card).Error
+}
+
+// FindByCodeForUpdate mengambil gift card sambil mengunci barisnya. Hanya bermakna di dalam transaksi.
+func (r *giftCardRepository) FindByCodeForUpdate(code string) (model.GiftCard, error) {
+	var card model.GiftCard
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&card).Error
+	return card, err
+}
+
+func (r *giftCardRepository) FindByPurchaser(userID uuid.UUID) ([]model.GiftCard, error) {
+	var cards []model.GiftCard
+	err := r.db.Where("purchased_by_id = ?", userID).Order("created_at desc").Find(&cards).Error
+	return cards, err
+}
+
+func (r *giftCardRepository) CountByOrderAndBook(orderID, bookID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.GiftCard{}).Where("order_id = ? AND book_id = ?", orderID, bookID).Count(&count).Error
+	return count, err
+}
+
+// MarkRedeemed menandai gift card sudah ditukar hanya jika statusnya masih issued.
+func (r *giftCardRepository) MarkRedeemed(id, userID uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.Model(&model.GiftCard{}).
+		Where("id = ? AND status = ?", id, model.GiftCardStatusIssued).
+		Updates(map[string]interface{}{
+			"status":         model.GiftCardStatusRedeemed,
+			"redeemed_by_id": userID,
+			"redeemed_at":    at,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindByOrderForUpdate mengambil semua gift card dari satu pesanan sambil mengunci barisnya.
+// Hanya bermakna di dalam transaksi.
+func (r *giftCardRepository) FindByOrderForUpdate(orderID uuid.UUID) ([]model.GiftCard, error) {
+	var cards []model.GiftCard
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", orderID).Find(&cards).Error
+	return cards, err
+}
+
+// VoidIssuedByOrder membatalkan gift card dari pesanan yang belum ditukar.
+func (r *giftCardRepository) VoidIssuedByOrder(orderID uuid.UUID) error {
+	return r.db.Model(&model.GiftCard{}).
+		Where("order_id = ? AND status = ?", orderID, model.GiftCardStatusIssued).
+		Update("status", model.GiftCardStatusVoided).Error
+}
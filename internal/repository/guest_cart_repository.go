@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrGuestCartBusy menandakan keranjang tamu terus diubah permintaan lain sehingga Update menyerah.
+var ErrGuestCartBusy = errors.New("guest cart is being updated by another request, please retry")
+
+// maxGuestCartRetries membatasi percobaan ulang Update saat keranjang berubah di tengah jalan.
+const maxGuestCartRetries = 5
+
+// GuestCartItem adalah satu buku di keranjang tamu.
+type GuestCartItem struct {
+	BookID   uuid.UUID `json:"book_id"`
+	Quantity int       `json:"quantity"`
+}
+
+// GuestCart adalah keranjang milik pengunjung yang belum login, diidentifikasi dengan cart token.
+type GuestCart struct {
+	Token string          `json:"token"`
+	Items []GuestCartItem `json:"items"`
+}
+
+// GuestCartRepository menyimpan keranjang tamu di Redis dengan masa berlaku.
+type GuestCartRepository interface {
+	Get(token string) (GuestCart, error)
+	Update(token string, fn func(cart *GuestCart) error) error
+	Delete(token string) error
+}
+
+type guestCartRepository struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+// NewGuestCartRepository adalah constructor untuk guestCartRepository.
+func NewGuestCartRepository(rdb *redis.Client, ttl time.Duration) GuestCartRepository {
+	return &guestCartRepository{rdb: rdb, ttl: ttl}
+}
+
+func guestCartKey(token string) string {
+	return "guest_cart:" + token
+}
+
+// Get mengambil keranjang tamu. Token yang tidak dikenal atau sudah kedaluwarsa menghasilkan keranjang kosong.
+func (r *guestCartRepository) Get(token string) (GuestCart, error) {
+	return decodeGuestCart(r.rdb.Get(context.Background(), guestCartKey(token)), token)
+}
+
+// decodeGuestCart membaca hasil GET keranjang tamu dari Redis.
+func decodeGuestCart(cmd *redis.StringCmd, token string) (GuestCart, error) {
+	cart := GuestCart{Token: token, Items: []GuestCartItem{}}
+	data, err := cmd.Result()
+	if err == redis.Nil {
+		return cart, nil
+	}
+	if err != nil {
+		return cart, err
+	}
+	if err := json.Unmarshal([]byte(data), &cart); err != nil {
+		return cart, err
+	}
+	cart.Token = token
+	return cart, nil
+}
+
+// Update membaca keranjang tamu, mengubahnya lewat fn, lalu menyimpannya dan memperpanjang masa
+// berlakunya. Kunci keranjang di-WATCH sehingga dua permintaan bersamaan tidak saling menimpa; jika
+// keranjang berubah sebelum disimpan, fn diulang dengan isi terbaru. Error dari fn membatalkan penyimpanan.
+func (r *guestCartRepository) Update(token string, fn func(cart *GuestCart) error) error {
+	ctx := context.Background()
+	key := guestCartKey(token)
+	for i := 0; i < maxGuestCartRetries; i++ {
+		err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			cart, err := decodeGuestCart(tx.Get(ctx, key), token)
+			if err != nil {
+				return err
+			}
+			if err := fn(&cart); err != nil {
+				return err
+			}
+			data, err := json.Marshal(cart)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, data, r.ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return ErrGuestCartBusy
+}
+
+func (r *guestCartRepository) Delete(token string) error {
+	return r.rdb.Del(context.Background(), guestCartKey(token)).Err()
+}
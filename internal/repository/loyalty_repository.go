@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoyaltyRepository mendefinisikan kontrak untuk saldo dan riwayat poin loyalti.
+type LoyaltyRepository interface {
+	FindOrCreateAccount(userID uuid.UUID) (model.LoyaltyAccount, error)
+	AddBalance(userID uuid.UUID, points int) error
+	SubtractBalance(userID uuid.UUID, points int) (bool, error)
+	CreateEntry(entry *model.LoyaltyEntry) error
+	FindEntries(userID uuid.UUID, limit int) ([]model.LoyaltyEntry, error)
+	FindOpenLots(userID uuid.UUID) ([]model.LoyaltyEntry, error)
+	FindExpiredLots(at time.Time, limit int) ([]model.LoyaltyEntry, error)
+	SetRemaining(entryID uuid.UUID, remaining int) error
+	SumOrderPoints(orderID uuid.UUID, entryType string) (int, error)
+	SpendSince(userID uuid.UUID, since time.Time) (float64, error)
+}
+
+type loyaltyRepository struct {
+	db *gorm.DB
+}
+
+// NewLoyaltyRepository adalah constructor untuk loyaltyRepository.
+func NewLoyaltyRepository(db *gorm.DB) LoyaltyRepository {
+	return &loyaltyRepository{db: db}
+}
+
+// FindOrCreateAccount mengambil akun poin pengguna, membuatnya dengan saldo nol jika belum ada.
+func (r *loyaltyRepository) FindOrCreateAccount(userID uuid.UUID) (model.LoyaltyAccount, error) {
+	account := model.LoyaltyAccount{UserID: userID}
+	err := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&account).Error
+	if err != nil {
+		return account, err
+	}
+	err = r.db.Where("user_id = ?", userID).First(&account).Error
+	return account, err
+}
+
+func (r *loyaltyRepository) AddBalance(userID uuid.UUID, points int) error {
+	return r.db.Model(&model.LoyaltyAccount{}).Where("user_id = ?", userID).
+		Update("balance", gorm.Expr("balance + ?", points)).Error
+}
+
+// SubtractBalance mengurangi saldo poin hanya jika saldonya cukup. Mengembalikan false jika tidak cukup.
+func (r *loyaltyRepository) SubtractBalance(userID uuid.UUID, points int) (bool, error) {
+	result := r.db.Model(&model.LoyaltyAccount{}).
+		Where("user_id = ? AND balance >= ?", userID, points).
+		Update("balance", gorm.Expr("balance - ?", points))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *loyaltyRepository) CreateEntry(entry *model.LoyaltyEntry) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *loyaltyRepository) FindEntries(userID uuid.UUID, limit int) ([]model.LoyaltyEntry, error) {
+	var entries []model.LoyaltyEntry
+	err := r.db.Preload("Actor").Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// FindOpenLots mengambil lot poin yang masih bersisa, urut dari yang paling dulu hangus.
+// Baris dikunci agar penukaran yang bersamaan tidak memakai lot yang sama.
+func (r *loyaltyRepository) FindOpenLots(userID uuid.UUID) ([]model.LoyaltyEntry, error) {
+	var lots []model.LoyaltyEntry
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND remaining > 0", userID).
+		Order("expires_at asc NULLS LAST, created_at asc").
+		Find(&lots).Error
+	return lots, err
+}
+
+func (r *loyaltyRepository) FindExpiredLots(at time.Time, limit int) ([]model.LoyaltyEntry, error) {
+	var lots []model.LoyaltyEntry
+	err := r.db.Where("remaining > 0 AND expires_at IS NOT NULL AND expires_at <= ?", at).
+		Order("expires_at asc").Limit(limit).Find(&lots).Error
+	return lots, err
+}
+
+func (r *loyaltyRepository) SetRemaining(entryID uuid.UUID, remaining int) error {
+	return r.db.Model(&model.LoyaltyEntry{}).Where("id = ?", entryID).Update("remaining", remaining).Error
+}
+
+// SumOrderPoints menjumlahkan poin dari jenis mutasi tertentu untuk sebuah pesanan.
+func (r *loyaltyRepository) SumOrderPoints(orderID uuid.UUID, entryType string) (int, error) {
+	var total int
+	err := r.db.Model(&model.LoyaltyEntry{}).
+		Where("order_id = ? AND type = ?", orderID, entryType).
+		Select("COALESCE(SUM(points), 0)").Scan(&total).Error
+	return total, err
+}
+
+// SpendSince menjumlahkan nilai belanja (setelah potongan, tanpa ongkir dan pajak) dari pesanan selesai.
+func (r *loyaltyRepository) SpendSince(userID uuid.UUID, since time.Time) (float64, error) {
+	var total float64
+	err := r.db.Model(&model.Order{}).
+		Where("user_id = ? AND status = ? AND created_at >= ?", userID, "selesai", since).
+		Select("COALESCE(SUM(subtotal - discount_total), 0)").Scan(&total).Error
+	return total, err
+}
@@ -2,19 +2,30 @@ package repository
 
 import (
 	"ngabaca/internal/model"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // OrderRepository mendefinisikan kontrak untuk data pesanan.
 type OrderRepository interface {
-	FindAll(status string) ([]model.Order, error)
+	FindAll(status, search string) ([]model.Order, error)
 	FindByID(id uuid.UUID) (model.Order, error)
+	FindByIDForUpdate(id uuid.UUID) (model.Order, error)
+	FindByOrderNumber(number string) (model.Order, error)
+	FindByOrderNumberForUpdate(number string) (model.Order, error)
+	FindByCheckoutKey(userID uuid.UUID, key string) (model.Order, error)
 	Update(order *model.Order) (*model.Order, error)
-	FindByUserID(userID uuid.UUID) ([]model.Order, error) // <-- TAMBAHKAN INI
+	FindByUserID(userID uuid.UUID, search string) ([]model.Order, error)
 	FindByIDAndUserID(id, userID uuid.UUID) (model.Order, error)
 	Create(order *model.Order) (*model.Order, error)
+	TaxSummary(from, to time.Time) ([]model.TaxSummaryRow, error)
+	SetInvoice(orderID uuid.UUID, number, path string, issuedAt time.Time) (bool, error)
+	SetItemCancelledQuantity(itemID uuid.UUID, quantity int) error
+	AddStatusHistory(entry *model.OrderStatusHistory) error
+	FindStatusHistory(orderID uuid.UUID) ([]model.OrderStatusHistory, error)
 }
 
 type orderRepository struct {
@@ -26,8 +37,15 @@ func NewOrderRepository(db *gorm.DB) OrderRepository {
 	return &orderRepository{db: db}
 }
 
-// Create menyimpan order baru ke database.
+// Create menyimpan order baru ke database sekaligus memberinya nomor pesanan.
 func (r *orderRepository) Create(order *model.Order) (*model.Order, error) {
+	if order.OrderNumber == "" {
+		number, err := r.nextOrderNumber(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		order.OrderNumber = number
+	}
 	err := r.db.Create(order).Error
 	if err != nil {
 		return nil, err
@@ -35,13 +53,36 @@ func (r *orderRepository) Create(order *model.Order) (*model.Order, error) {
 	return order, nil
 }
 
-func (r *orderRepository) FindAll(status string) ([]model.Order, error) {
+// nextOrderNumber mengambil nomor urut berikutnya untuk tahun berjalan. Baris urutan tahun itu
+// terkunci sampai transaksi checkout selesai, sehingga checkout yang bersamaan mengantre dan
+// nomor dari transaksi yang dibatalkan ikut dikembalikan (tidak ada nomor yang bolong).
+func (r *orderRepository) nextOrderNumber(at time.Time) (string, error) {
+	var last int
+	err := r.db.Raw(`INSERT INTO order_sequences (year, last_number) VALUES (?, 1)
+		ON CONFLICT (year) DO UPDATE SET last_number = order_sequences.last_number + 1
+		RETURNING last_number`, at.Year()).Scan(&last).Error
+	if err != nil {
+		return "", err
+	}
+	return model.FormatOrderNumber(at.Year(), last), nil
+}
+
+// searchOrders menyaring pesanan berdasarkan nomor pesanan atau ID-nya.
+func searchOrders(query *gorm.DB, search string) *gorm.DB {
+	if search == "" {
+		return query
+	}
+	return query.Where("(order_number ILIKE ? OR CAST(id AS TEXT) = ?)", "%"+search+"%", search)
+}
+
+func (r *orderRepository) FindAll(status, search string) ([]model.Order, error) {
 	var orders []model.Order
 	query := r.db.Preload("User").Order("created_at desc")
 
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	query = searchOrders(query, search)
 
 	err := query.Find(&orders).Error
 	return orders, err
@@ -51,27 +92,123 @@ func (r *orderRepository) FindByID(id uuid.UUID) (model.Order, error) {
 	var order model.Order
 	err := r.db.Preload("User").
 		Preload("OrderItems.Book").
+		Preload("OrderItems.Discounts").
 		Preload("Payment").
+		Preload("Shipments.Items").
+		Preload("Shipments.Events", preloadShipmentEvents).
 		First(&order, id).Error
 	return order, err
 }
 
+// FindByIDForUpdate mengambil pesanan sambil mengunci barisnya sampai transaksi selesai,
+// agar pembatalan tidak berbarengan dengan notifikasi pembayaran untuk pesanan yang sama.
+func (r *orderRepository) FindByIDForUpdate(id uuid.UUID) (model.Order, error) {
+	var order model.Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error
+	if err != nil {
+		return order, err
+	}
+	return r.FindByID(id)
+}
+
 func (r *orderRepository) Update(order *model.Order) (*model.Order, error) {
 	err := r.db.Save(order).Error
 	return order, err
 }
 
-func (r *orderRepository) FindByUserID(userID uuid.UUID) ([]model.Order, error) {
+func (r *orderRepository) FindByUserID(userID uuid.UUID, search string) ([]model.Order, error) {
 	var orders []model.Order
-	err := r.db.Where("user_id = ?", userID).Preload("OrderItems.Book").Find(&orders).Error
+	err := searchOrders(r.db.Where("user_id = ?", userID), search).Preload("OrderItems.Book").Find(&orders).Error
 	return orders, err
 }
 
+func (r *orderRepository) FindByOrderNumber(number string) (model.Order, error) {
+	var order model.Order
+	err := r.db.Where("order_number = ?", number).
+		Preload("User").
+		Preload("OrderItems.Book").
+		Preload("OrderItems.Discounts").
+		Preload("Payment").
+		Preload("Shipments.Items").
+		Preload("Shipments.Events", preloadShipmentEvents).
+		First(&order).Error
+	return order, err
+}
+
+// FindByOrderNumberForUpdate sama dengan FindByIDForUpdate, tetapi mencari lewat nomor pesanan
+// yang dipakai sebagai order_id Midtrans.
+func (r *orderRepository) FindByOrderNumberForUpdate(number string) (model.Order, error) {
+	var order model.Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_number = ?", number).First(&order).Error
+	if err != nil {
+		return order, err
+	}
+	return r.FindByID(order.ID)
+}
+
+// FindByCheckoutKey mencari pesanan pengguna yang dibuat oleh checkout dengan Idempotency-Key tertentu.
+func (r *orderRepository) FindByCheckoutKey(userID uuid.UUID, key string) (model.Order, error) {
+	var order model.Order
+	err := r.db.Where("user_id = ? AND checkout_key = ?", userID, key).
+		Preload("OrderItems.Book").
+		Preload("Payment").
+		First(&order).Error
+	return order, err
+}
+
 func (r *orderRepository) FindByIDAndUserID(id, userID uuid.UUID) (model.Order, error) {
 	var order model.Order
 	err := r.db.Where("id = ? AND user_id = ?", id, userID).
 		Preload("OrderItems.Book").
+		Preload("OrderItems.Discounts").
 		Preload("Payment").
+		Preload("Shipments.Items").
+		Preload("Shipments.Events", preloadShipmentEvents).
 		First(&order).Error
 	return order, err
 }
+
+// TaxSummary merangkum PPN per kelas, tarif pajak, dan jenis harga (termasuk atau belum termasuk PPN)
+// dari pesanan yang sudah dibayar dalam rentang waktu.
+func (r *orderRepository) TaxSummary(from, to time.Time) ([]model.TaxSummaryRow, error) {
+	var rows []model.TaxSummaryRow
+	err := r.db.Table("order_items").
+		Select("order_items.tax_class, order_items.tax_rate, orders.tax_inclusive, "+
+			"COUNT(DISTINCT order_items.order_id) AS order_count, "+
+			"COALESCE(SUM(order_items.quantity), 0) AS quantity, "+
+			"COALESCE(SUM(order_items.price * order_items.quantity - order_items.discount), 0) AS net_sales, "+
+			"COALESCE(SUM(order_items.tax_base), 0) AS tax_base, "+
+			"COALESCE(SUM(order_items.tax_amount), 0) AS tax_amount").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Joins("JOIN payments ON payments.order_id = orders.id").
+		Where("payments.status = ? AND payments.verified_at >= ? AND payments.verified_at < ?", "success", from, to).
+		Where("orders.status <> ? AND orders.deleted_at IS NULL AND order_items.deleted_at IS NULL", "batal").
+		Group("order_items.tax_class, order_items.tax_rate, orders.tax_inclusive").
+		Order("order_items.tax_class, order_items.tax_rate, orders.tax_inclusive").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// SetInvoice mencatat faktur yang sudah diterbitkan. Hanya berhasil jika pesanan belum punya faktur,
+// sehingga penerbitan yang berjalan bersamaan tidak saling menimpa; false berarti faktur sudah ada.
+func (r *orderRepository) SetInvoice(orderID uuid.UUID, number, path string, issuedAt time.Time) (bool, error) {
+	result := r.db.Model(&model.Order{}).
+		Where("id = ? AND (invoice_path IS NULL OR invoice_path = '')", orderID).
+		Updates(map[string]interface{}{"invoice_number": number, "invoice_path": path, "invoice_issued_at": issuedAt})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *orderRepository) SetItemCancelledQuantity(itemID uuid.UUID, quantity int) error {
+	return r.db.Model(&model.OrderItem{}).Where("id = ?", itemID).Update("cancelled_quantity", quantity).Error
+}
+
+func (r *orderRepository) AddStatusHistory(entry *model.OrderStatusHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// FindStatusHistory mengambil riwayat perubahan status pesanan, urut dari yang paling awal.
+func (r *orderRepository) FindStatusHistory(orderID uuid.UUID) ([]model.OrderStatusHistory, error) {
+	var entries []model.OrderStatusHistory
+	err := r.db.Where("order_id = ?", orderID).Order("changed_at asc, created_at asc").Find(&entries).Error
+	return entries, err
+}
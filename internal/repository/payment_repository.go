@@ -12,6 +12,7 @@ type PaymentRepository interface {
 	Create(payment *model.Payment) (*model.Payment, error)
 	FindByOrderID(orderID uuid.UUID) (model.Payment, error)
 	Update(payment *model.Payment) (*model.Payment, error)
+	SetSnapSession(paymentID uuid.UUID, token, redirectURL string) error
 }
 
 type paymentRepository struct {
@@ -37,3 +38,9 @@ func (r *paymentRepository) Update(payment *model.Payment) (*model.Payment, erro
 	err := r.db.Save(payment).Error
 	return payment, err
 }
+
+// SetSnapSession menyimpan token dan URL Snap dari transaksi Midtrans yang sudah dibuat.
+func (r *paymentRepository) SetSnapSession(paymentID uuid.UUID, token, redirectURL string) error {
+	return r.db.Model(&model.Payment{}).Where("id = ?", paymentID).
+		Updates(map[string]interface{}{"snap_token": token, "snap_redirect_url": redirectURL}).Error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PromotionRepository mendefinisikan kontrak untuk aturan promosi.
+type PromotionRepository interface {
+	FindAll() ([]model.Promotion, error)
+	FindByID(id uuid.UUID) (model.Promotion, error)
+	FindRunning(at time.Time) ([]model.Promotion, error)
+	Create(promotion *model.Promotion) error
+	Update(promotion *model.Promotion) error
+	Delete(promotion *model.Promotion) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+// NewPromotionRepository adalah constructor untuk promotionRepository.
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) FindAll() ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	err := r.db.Preload("Categories").Preload("Books").Order("created_at desc").Find(&promotions).Error
+	return promotions, err
+}
+
+func (r *promotionRepository) FindByID(id uuid.UUID) (model.Promotion, error) {
+	var promotion model.Promotion
+	err := r.db.Preload("Categories").Preload("Books").First(&promotion, id).Error
+	return promotion, err
+}
+
+// FindRunning mengambil promosi yang aktif dan sedang dalam masa berlakunya.
+func (r *promotionRepository) FindRunning(at time.Time) ([]model.Promotion, error) {
+	var promotions []model.Promotion
+	err := r.db.Preload("Categories").Preload("Books").
+		Where("is_active = ?", true).
+		Where("starts_at IS NULL OR starts_at <= ?", at).
+		Where("ends_at IS NULL OR ends_at > ?", at).
+		Find(&promotions).Error
+	return promotions, err
+}
+
+func (r *promotionRepository) Create(promotion *model.Promotion) error {
+	return r.db.Create(promotion).Error
+}
+
+// Update menyimpan promosi sekaligus mengganti daftar kategori dan buku.
+func (r *promotionRepository) Update(promotion *model.Promotion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Categories", "Books").Save(promotion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(promotion).Association("Categories").Replace(promotion.Categories); err != nil {
+			return err
+		}
+		return tx.Model(promotion).Association("Books").Replace(promotion.Books)
+	})
+}
+
+func (r *promotionRepository) Delete(promotion *model.Promotion) error {
+	return r.db.Delete(promotion).Error
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReadingActivity adalah ringkasan aktivitas baca terakhir per buku.
+type ReadingActivity struct {
+	BookID         uuid.UUID
+	LastActivityAt time.Time
+}
+
+// ReadingRepository mendefinisikan kontrak untuk progres baca, bookmark, dan highlight.
+type ReadingRepository interface {
+	FindProgress(userID, bookID uuid.UUID) (model.ReadingProgress, error)
+	FindProgressByBookIDs(userID uuid.UUID, bookIDs []uuid.UUID) ([]model.ReadingProgress, error)
+	UpsertProgressIfNewer(progress *model.ReadingProgress) (bool, error)
+	RecentActivity(userID uuid.UUID, limit int) ([]ReadingActivity, error)
+
+	FindBookmarks(userID, bookID uuid.UUID) ([]model.Bookmark, error)
+	CreateBookmark(bookmark *model.Bookmark) error
+	DeleteBookmark(userID, bookID, id uuid.UUID) (bool, error)
+
+	FindHighlights(userID, bookID uuid.UUID) ([]model.Highlight, error)
+	FindHighlight(userID, bookID, id uuid.UUID) (model.Highlight, error)
+	CreateHighlight(highlight *model.Highlight) error
+	UpdateHighlight(highlight *model.Highlight) error
+	DeleteHighlight(userID, bookID, id uuid.UUID) (bool, error)
+}
+
+type readingRepository struct {
+	db *gorm.DB
+}
+
+// NewReadingRepository adalah constructor untuk readingRepository.
+func NewReadingRepository(db *gorm.DB) ReadingRepository {
+	return &readingRepository{db: db}
+}
+
+func (r *readingRepository) FindProgress(userID, bookID uuid.UUID) (model.ReadingProgress, error) {
+	var progress model.ReadingProgress
+	err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).First(&progress).Error
+	return progress, err
+}
+
+func (r *readingRepository) FindProgressByBookIDs(userID uuid.UUID, bookIDs []uuid.UUID) ([]model.ReadingProgress, error) {
+	var progresses []model.ReadingProgress
+	err := r.db.Where("user_id = ? AND book_id IN ?", userID, bookIDs).Find(&progresses).Error
+	return progresses, err
+}
+
+// UpsertProgressIfNewer menyimpan progres hanya jika ClientUpdatedAt lebih baru dari yang tersimpan.
+// Mengembalikan false jika data di server lebih baru sehingga perubahan diabaikan.
+func (r *readingRepository) UpsertProgressIfNewer(progress *model.ReadingProgress) (bool, error) {
+	if progress.ID == uuid.Nil {
+		progress.ID = uuid.New()
+	}
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "book_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "position_type", "percentage", "device", "client_updated_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "reading_progresses.client_updated_at < EXCLUDED.client_updated_at"},
+		}},
+	}).Create(progress)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RecentActivity mengembalikan buku yang terakhir dibaca, diurutkan dari aktivitas terbaru
+// (progres, bookmark, maupun highlight).
+func (r *readingRepository) RecentActivity(userID uuid.UUID, limit int) ([]ReadingActivity, error) {
+	var activities []ReadingActivity
+	err := r.db.Raw(`
+		SELECT book_id, MAX(activity_at) AS last_activity_at FROM (
+			SELECT book_id, client_updated_at AS activity_at FROM reading_progresses WHERE user_id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT book_id, updated_at AS activity_at FROM bookmarks WHERE user_id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT book_id, updated_at AS activity_at FROM highlights WHERE user_id = ? AND deleted_at IS NULL
+		) activity
+		GROUP BY book_id
+		ORDER BY last_activity_at DESC
+		LIMIT ?`, userID, userID, userID, limit).
+		Scan(&activities).Error
+	return activities, err
+}
+
+func (r *readingRepository) FindBookmarks(userID, bookID uuid.UUID) ([]model.Bookmark, error) {
+	var bookmarks []model.Bookmark
+	err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).Order("created_at asc").Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+func (r *readingRepository) CreateBookmark(bookmark *model.Bookmark) error {
+	return r.db.Create(bookmark).Error
+}
+
+func (r *readingRepository) DeleteBookmark(userID, bookID, id uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ? AND book_id = ?", id, userID, bookID).Delete(&model.Bookmark{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *readingRepository) FindHighlights(userID, bookID uuid.UUID) ([]model.Highlight, error) {
+	var highlights []model.Highlight
+	err := r.db.Where("user_id = ? AND book_id = ?", userID, bookID).Order("created_at asc").Find(&highlights).Error
+	return highlights, err
+}
+
+func (r *readingRepository) FindHighlight(userID, bookID, id uuid.UUID) (model.Highlight, error) {
+	var highlight model.Highlight
+	err := r.db.Where("id = ? AND user_id = ? AND book_id = ?", id, userID, bookID).First(&highlight).Error
+	return highlight, err
+}
+
+func (r *readingRepository) CreateHighlight(highlight *model.Highlight) error {
+	return r.db.Create(highlight).Error
+}
+
+func (r *readingRepository) UpdateHighlight(highlight *model.Highlight) error {
+	return r.db.Save(highlight).Error
+}
+
+func (r *readingRepository) DeleteHighlight(userID, bookID, id uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ? AND book_id = ?", id, userID, bookID).Delete(&model.Highlight{})
+	return result.RowsAffected > 0, result.Error
+}
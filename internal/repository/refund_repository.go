@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefundTotals adalah jumlah dana yang sudah dikembalikan untuk sebuah pesanan.
+type RefundTotals struct {
+	Amount        float64
+	GatewayAmount float64
+}
+
+// RefundRepository mendefinisikan kontrak untuk data pengembalian dana.
+type RefundRepository interface {
+	Create(refund *model.Refund) error
+	FindByID(id uuid.UUID) (model.Refund, error)
+	FindByOrderID(orderID uuid.UUID) ([]model.Refund, error)
+	FindPending(updatedBefore time.Time, limit int) ([]model.Refund, error)
+	SumByOrderID(orderID uuid.UUID) (RefundTotals, error)
+	Update(refund *model.Refund) error
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+// NewRefundRepository adalah constructor untuk refundRepository.
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(refund *model.Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r *refundRepository) FindByID(id uuid.UUID) (model.Refund, error) {
+	var refund model.Refund
+	err := r.db.First(&refund, id).Error
+	return refund, err
+}
+
+func (r *refundRepository) FindByOrderID(orderID uuid.UUID) ([]model.Refund, error) {
+	var refunds []model.Refund
+	err := r.db.Where("order_id = ?", orderID).Order("created_at asc").Find(&refunds).Error
+	return refunds, err
+}
+
+// FindPending mencari refund yang belum terkirim ke payment gateway dan tidak disentuh sejak updatedBefore.
+func (r *refundRepository) FindPending(updatedBefore time.Time, limit int) ([]model.Refund, error) {
+	var refunds []model.Refund
+	err := r.db.Where("status = ? AND updated_at < ?", model.RefundStatusPending, updatedBefore).
+		Order("created_at asc").
+		Limit(limit).
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// SumByOrderID menjumlahkan pengembalian dana sebuah pesanan. Refund yang gagal tidak dihitung
+// karena dananya belum kembali ke pembeli.
+func (r *refundRepository) SumByOrderID(orderID uuid.UUID) (RefundTotals, error) {
+	var totals RefundTotals
+	err := r.db.Model(&model.Refund{}).
+		Where("order_id = ? AND status <> ?", orderID, model.RefundStatusFailed).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(SUM(gateway_amount), 0) AS gateway_amount").
+		Scan(&totals).Error
+	return totals, err
+}
+
+func (r *refundRepository) Update(refund *model.Refund) error {
+	return r.db.Save(refund).Error
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReservationRepository mendefinisikan kontrak untuk reservasi stok.
+type ReservationRepository interface {
+	Create(reservation *model.StockReservation) error
+	ReservedQuantity(bookID uuid.UUID, excludeCartOf uuid.UUID) (int, error)
+	ReservedQuantities(bookIDs []uuid.UUID) (map[uuid.UUID]int, error)
+	FindCartReservations(userID uuid.UUID) ([]model.StockReservation, error)
+	ReleaseCartReservations(userID uuid.UUID, bookIDs []uuid.UUID) error
+	FindByOrderID(orderID uuid.UUID) ([]model.StockReservation, error)
+	UpdateOrderStatus(orderID uuid.UUID, fromStatuses []string, status string) error
+	UpdateQuantity(id uuid.UUID, quantity, shortfall int) error
+	SetShortfall(id uuid.UUID, shortfall int) error
+	ExpireStale() (int64, error)
+}
+
+type reservationRepository struct {
+	db *gorm.DB
+}
+
+// NewReservationRepository adalah constructor untuk reservationRepository.
+func NewReservationRepository(db *gorm.DB) ReservationRepository {
+	return &reservationRepository{db: db}
+}
+
+// activeReservations membatasi query ke reservasi yang masih menahan stok.
+func (r *reservationRepository) activeReservations() *gorm.DB {
+	return r.db.Model(&model.StockReservation{}).
+		Where("status = ? AND expires_at > ?", model.ReservationStatusActive, time.Now())
+}
+
+func (r *reservationRepository) Create(reservation *model.StockReservation) error {
+	return r.db.Create(reservation).Error
+}
+
+// ReservedQuantity menjumlahkan stok yang sedang ditahan untuk sebuah buku.
+// Reservasi keranjang milik excludeCartOf tidak dihitung agar pengguna tidak terhalang reservasinya sendiri.
+func (r *reservationRepository) ReservedQuantity(bookID uuid.UUID, excludeCartOf uuid.UUID) (int, error) {
+	var total int
+	query := r.activeReservations().Where("book_id = ?", bookID)
+	if excludeCartOf != uuid.Nil {
+		query = query.Where("NOT (user_id = ? AND order_id IS NULL)", excludeCartOf)
+	}
+	err := query.Select("COALESCE(SUM(quantity), 0)").Scan(&total).Error
+	return total, err
+}
+
+// ReservedQuantities menjumlahkan stok yang sedang ditahan untuk beberapa buku sekaligus.
+func (r *reservationRepository) ReservedQuantities(bookIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	result := map[uuid.UUID]int{}
+	if len(bookIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		BookID   uuid.UUID
+		Quantity int
+	}
+	err := r.activeReservations().
+		Select("book_id, SUM(quantity) AS quantity").
+		Where("book_id IN ?", bookIDs).
+		Group("book_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.BookID] = row.Quantity
+	}
+	return result, nil
+}
+
+func (r *reservationRepository) FindCartReservations(userID uuid.UUID) ([]model.StockReservation, error) {
+	var reservations []model.StockReservation
+	err := r.activeReservations().
+		Where("user_id = ? AND order_id IS NULL", userID).
+		Find(&reservations).Error
+	return reservations, err
+}
+
+// ReleaseCartReservations melepas reservasi keranjang pengguna. Jika bookIDs kosong, semua dilepas.
+func (r *reservationRepository) ReleaseCartReservations(userID uuid.UUID, bookIDs []uuid.UUID) error {
+	query := r.db.Model(&model.StockReservation{}).
+		Where("user_id = ? AND order_id IS NULL AND status = ?", userID, model.ReservationStatusActive)
+	if len(bookIDs) > 0 {
+		query = query.Where("book_id IN ?", bookIDs)
+	}
+	return query.Update("status", model.ReservationStatusReleased).Error
+}
+
+func (r *reservationRepository) FindByOrderID(orderID uuid.UUID) ([]model.StockReservation, error) {
+	var reservations []model.StockReservation
+	err := r.db.Where("order_id = ?", orderID).Find(&reservations).Error
+	return reservations, err
+}
+
+func (r *reservationRepository) UpdateOrderStatus(orderID uuid.UUID, fromStatuses []string, status string) error {
+	return r.db.Model(&model.StockReservation{}).
+		Where("order_id = ? AND status IN ?", orderID, fromStatuses).
+		Update("status", status).Error
+}
+
+func (r *reservationRepository) UpdateQuantity(id uuid.UUID, quantity, shortfall int) error {
+	return r.db.Model(&model.StockReservation{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"quantity": quantity, "shortfall": shortfall}).Error
+}
+
+func (r *reservationRepository) SetShortfall(id uuid.UUID, shortfall int) error {
+	return r.db.Model(&model.StockReservation{}).Where("id = ?", id).Update("shortfall", shortfall).Error
+}
+
+// ExpireStale menandai reservasi aktif yang sudah lewat masa berlakunya.
+func (r *reservationRepository) ExpireStale() (int64, error) {
+	result := r.db.Model(&model.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", model.ReservationStatusActive, time.Now()).
+		Update("status", model.ReservationStatusExpired)
+	return result.RowsAffected, result.Error
+}
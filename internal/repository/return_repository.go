@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReturnStatusCount adalah jumlah retur untuk satu status dalam laporan.
+type ReturnStatusCount struct {
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+// ReturnRepository mendefinisikan kontrak untuk data retur (RMA).
+type ReturnRepository interface {
+	Create(ret *model.ReturnRequest) error
+	FindAll(status string) ([]model.ReturnRequest, error)
+	FindByID(id uuid.UUID) (model.ReturnRequest, error)
+	FindByIDForUpdate(id uuid.UUID) (model.ReturnRequest, error)
+	FindByUserID(userID uuid.UUID) ([]model.ReturnRequest, error)
+	CountByOrderID(orderID uuid.UUID) (int64, error)
+	ReturnedQuantities(orderID uuid.UUID) (map[uuid.UUID]int, error)
+	Update(ret *model.ReturnRequest) error
+	SetItemDisposition(itemID uuid.UUID, disposition string) error
+	CountByStatus(from, to time.Time) ([]ReturnStatusCount, error)
+	DispositionSummary(from, to time.Time) ([]model.ReturnSummaryRow, error)
+}
+
+type returnRepository struct {
+	db *gorm.DB
+}
+
+// NewReturnRepository adalah constructor untuk returnRepository.
+func NewReturnRepository(db *gorm.DB) ReturnRepository {
+	return &returnRepository{db: db}
+}
+
+// Create menyimpan retur beserta item dan fotonya.
+func (r *returnRepository) Create(ret *model.ReturnRequest) error {
+	return r.db.Create(ret).Error
+}
+
+func (r *returnRepository) withDetails() *gorm.DB {
+	return r.db.Preload("Items.OrderItem.Book").Preload("Photos")
+}
+
+func (r *returnRepository) FindAll(status string) ([]model.ReturnRequest, error) {
+	var returns []model.ReturnRequest
+	query := r.withDetails().Preload("Order.User").Order("created_at desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&returns).Error
+	return returns, err
+}
+
+func (r *returnRepository) FindByID(id uuid.UUID) (model.ReturnRequest, error) {
+	var ret model.ReturnRequest
+	err := r.withDetails().Preload("Order.User").Preload("Order.Payment").First(&ret, id).Error
+	return ret, err
+}
+
+// FindByIDForUpdate mengambil retur sambil mengunci barisnya agar dua admin tidak memproses retur yang sama bersamaan.
+func (r *returnRepository) FindByIDForUpdate(id uuid.UUID) (model.ReturnRequest, error) {
+	var ret model.ReturnRequest
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ret, id).Error; err != nil {
+		return ret, err
+	}
+	return r.FindByID(id)
+}
+
+func (r *returnRepository) FindByUserID(userID uuid.UUID) ([]model.ReturnRequest, error) {
+	var returns []model.ReturnRequest
+	err := r.withDetails().Where("user_id = ?", userID).Order("created_at desc").Find(&returns).Error
+	return returns, err
+}
+
+// CountByOrderID menghitung semua retur sebuah pesanan, termasuk yang ditolak, untuk nomor urut RMA.
+func (r *returnRepository) CountByOrderID(orderID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.ReturnRequest{}).Where("order_id = ?", orderID).Count(&count).Error
+	return count, err
+}
+
+// ReturnedQuantities menjumlahkan kuantitas per item pesanan yang sudah masuk retur yang tidak ditolak atau dibatalkan.
+func (r *returnRepository) ReturnedQuantities(orderID uuid.UUID) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		OrderItemID uuid.UUID
+		Quantity    int
+	}
+	err := r.db.Model(&model.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status NOT IN ?", orderID,
+			[]string{model.ReturnStatusRejected, model.ReturnStatusCancelled}).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	returned := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		returned[row.OrderItemID] = row.Quantity
+	}
+	return returned, nil
+}
+
+func (r *returnRepository) Update(ret *model.ReturnRequest) error {
+	return r.db.Omit(clause.Associations).Save(ret).Error
+}
+
+func (r *returnRepository) SetItemDisposition(itemID uuid.UUID, disposition string) error {
+	return r.db.Model(&model.ReturnItem{}).Where("id = ?", itemID).Update("disposition", disposition).Error
+}
+
+// CountByStatus menghitung retur yang diajukan dalam rentang waktu per statusnya saat ini.
+func (r *returnRepository) CountByStatus(from, to time.Time) ([]ReturnStatusCount, error) {
+	var rows []ReturnStatusCount
+	err := r.db.Model(&model.ReturnRequest{}).
+		Select("status, COUNT(*) AS count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("status").
+		Order("status").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// DispositionSummary merangkum barang retur yang diterima gudang dalam rentang waktu per perlakuannya.
+func (r *returnRepository) DispositionSummary(from, to time.Time) ([]model.ReturnSummaryRow, error) {
+	var rows []model.ReturnSummaryRow
+	err := r.db.Model(&model.ReturnItem{}).
+		Select(`return_items.disposition,
+			COUNT(DISTINCT return_items.return_request_id) AS returns,
+			COALESCE(SUM(return_items.quantity), 0) AS quantity,
+			COALESCE(SUM(return_items.refund_amount), 0) AS refund_amount`).
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_requests.received_at >= ? AND return_requests.received_at < ?", from, to).
+		Group("return_items.disposition").
+		Order("return_items.disposition").
+		Scan(&rows).Error
+	return rows, err
+}
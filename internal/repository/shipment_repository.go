@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentRepository mendefinisikan kontrak untuk data pengiriman dan event pelacakannya.
+type ShipmentRepository interface {
+	Create(shipment *model.Shipment) error
+	FindByID(id uuid.UUID) (model.Shipment, error)
+	FindByIDForUpdate(id uuid.UUID) (model.Shipment, error)
+	FindByOrderID(orderID uuid.UUID) ([]model.Shipment, error)
+	FindTrackable(polledBefore time.Time, limit int) ([]model.Shipment, error)
+	ShippedQuantities(orderID uuid.UUID) (map[uuid.UUID]int, error)
+	Update(shipment *model.Shipment) error
+	AddEvent(event *model.ShipmentEvent) error
+	UpdateEvent(event *model.ShipmentEvent) error
+}
+
+type shipmentRepository struct {
+	db *gorm.DB
+}
+
+// NewShipmentRepository adalah constructor untuk shipmentRepository.
+func NewShipmentRepository(db *gorm.DB) ShipmentRepository {
+	return &shipmentRepository{db: db}
+}
+
+// preloadShipmentEvents memuat event pelacakan urut dari yang paling awal.
+func preloadShipmentEvents(db *gorm.DB) *gorm.DB {
+	return db.Order("occurred_at asc, created_at asc")
+}
+
+// Create menyimpan pengiriman beserta item dan event awalnya.
+func (r *shipmentRepository) Create(shipment *model.Shipment) error {
+	return r.db.Create(shipment).Error
+}
+
+func (r *shipmentRepository) FindByID(id uuid.UUID) (model.Shipment, error) {
+	var shipment model.Shipment
+	err := r.db.Preload("Items").Preload("Events", preloadShipmentEvents).First(&shipment, id).Error
+	return shipment, err
+}
+
+// FindByIDForUpdate mengambil pengiriman sambil mengunci barisnya, agar event dari admin dan
+// dari polling kurir tidak diproses bersamaan.
+func (r *shipmentRepository) FindByIDForUpdate(id uuid.UUID) (model.Shipment, error) {
+	var shipment model.Shipment
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, id).Error; err != nil {
+		return shipment, err
+	}
+	return r.FindByID(id)
+}
+
+func (r *shipmentRepository) FindByOrderID(orderID uuid.UUID) ([]model.Shipment, error) {
+	var shipments []model.Shipment
+	err := r.db.Preload("Items").Preload("Events", preloadShipmentEvents).
+		Where("order_id = ?", orderID).
+		Order("shipped_at asc").
+		Find(&shipments).Error
+	return shipments, err
+}
+
+// FindTrackable mencari pengiriman yang belum berakhir dan belum dilacak sejak polledBefore.
+func (r *shipmentRepository) FindTrackable(polledBefore time.Time, limit int) ([]model.Shipment, error) {
+	var shipments []model.Shipment
+	err := r.db.Where("status NOT IN ?", []string{model.ShipmentStatusDelivered, model.ShipmentStatusFailed}).
+		Where("last_polled_at IS NULL OR last_polled_at < ?", polledBefore).
+		Order("last_polled_at asc NULLS FIRST").
+		Limit(limit).
+		Find(&shipments).Error
+	return shipments, err
+}
+
+// ShippedQuantities menjumlahkan kuantitas per item pesanan yang sudah masuk paket pengiriman.
+func (r *shipmentRepository) ShippedQuantities(orderID uuid.UUID) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		OrderItemID uuid.UUID
+		Quantity    int
+	}
+	err := r.db.Model(&model.ShipmentItem{}).
+		Select("shipment_items.order_item_id, SUM(shipment_items.quantity) AS quantity").
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.order_id = ?", orderID).
+		Group("shipment_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shipped := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		shipped[row.OrderItemID] = row.Quantity
+	}
+	return shipped, nil
+}
+
+func (r *shipmentRepository) Update(shipment *model.Shipment) error {
+	return r.db.Omit(clause.Associations).Save(shipment).Error
+}
+
+func (r *shipmentRepository) AddEvent(event *model.ShipmentEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *shipmentRepository) UpdateEvent(event *model.ShipmentEvent) error {
+	return r.db.Save(event).Error
+}
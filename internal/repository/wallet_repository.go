@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"ngabaca/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WalletRepository mendefinisikan kontrak untuk dompet dan buku besarnya.
+type WalletRepository interface {
+	FindOrCreate(userID uuid.UUID) (model.Wallet, error)
+	FindTransactions(userID uuid.UUID, limit int) ([]model.WalletTransaction, error)
+	AddBalance(wallet *model.Wallet, amount float64) error
+	SubtractBalance(wallet *model.Wallet, amount float64) (bool, error)
+	CreateTransaction(transaction *model.WalletTransaction) error
+	SumOrderTransactions(orderID uuid.UUID, source string) (float64, error)
+}
+
+type walletRepository struct {
+	db *gorm.DB
+}
+
+// NewWalletRepository adalah constructor untuk walletRepository.
+func NewWalletRepository(db *gorm.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+// FindOrCreate mengambil dompet pengguna, membuatnya dengan saldo nol jika belum ada.
+func (r *walletRepository) FindOrCreate(userID uuid.UUID) (model.Wallet, error) {
+	wallet := model.Wallet{UserID: userID}
+	err := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "user_id"}}, DoNothing: true}).
+		Create(&wallet).Error
+	if err != nil {
+		return wallet, err
+	}
+	err = r.db.Where("user_id = ?", userID).First(&wallet).Error
+	return wallet, err
+}
+
+func (r *walletRepository) FindTransactions(userID uuid.UUID, limit int) ([]model.WalletTransaction, error) {
+	var transactions []model.WalletTransaction
+	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Limit(limit).Find(&transactions).Error
+	return transactions, err
+}
+
+// AddBalance menambah saldo secara atomik dan mengisi wallet.Balance dengan saldo terbaru.
+func (r *walletRepository) AddBalance(wallet *model.Wallet, amount float64) error {
+	return r.db.Model(wallet).Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).
+		Update("balance", gorm.Expr("balance + ?", amount)).Error
+}
+
+// SubtractBalance mengurangi saldo hanya jika saldonya cukup, sehingga checkout yang berjalan
+// bersamaan tidak bisa membuat saldo negatif. Mengembalikan false jika saldo tidak cukup.
+func (r *walletRepository) SubtractBalance(wallet *model.Wallet, amount float64) (bool, error) {
+	result := r.db.Model(wallet).Clauses(clause.Returning{Columns: []clause.Column{{Name: "balance"}}}).
+		Where("balance >= ?", amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *walletRepository) CreateTransaction(transaction *model.WalletTransaction) error {
+	return r.db.Create(transaction).Error
+}
+
+// SumOrderTransactions menjumlahkan mutasi dompet dari sumber tertentu untuk sebuah pesanan.
+func (r *walletRepository) SumOrderTransactions(orderID uuid.UUID, source string) (float64, error) {
+	var total float64
+	err := r.db.Model(&model.WalletTransaction{}).
+		Where("order_id = ? AND source = ?", orderID, source).
+		Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+	return total, err
+}
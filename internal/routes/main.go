@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"ngabaca/database"
 	"ngabaca/internal/middleware"
 	"ngabaca/internal/server"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -12,6 +15,7 @@ import (
 func Setup(s *server.Server) {
 	// Middleware umum
 	s.App.Use(logger.New())
+	idempotency := middleware.Idempotency(database.RDB, time.Duration(s.Cfg.IdempotencyKeyTTLHours)*time.Hour)
 	s.App.Static("/", "./public")
 
 	api := s.App.Group("/api/v2")
@@ -29,12 +33,23 @@ func Setup(s *server.Server) {
 	// --- Rute Publik ---
 	api.Get("/catalog", s.PublicHandler.GetBooks)
 	api.Get("/book/:slug", s.PublicHandler.GetBookDetail)
+	api.Get("/book/:slug/preview", middleware.RateLimitByIP(s.Cfg.PreviewRateLimitPerMinute, time.Minute), s.PublicHandler.GetBookPreview)
 	api.Get("/categories", s.PublicHandler.GetCategories)
 	api.Get("/categories/:id", s.PublicHandler.GetCategoryByID)
 	api.Get("/search", s.PublicHandler.SearchBooks)
 	// Rute publik untuk melihat ulasan dipindahkan ke CustomerHandler
 	api.Get("/books/:id/reviews", s.CustomerHandler.GetBookReviews)
 
+	// Keranjang tamu, diidentifikasi dengan header X-Cart-Token
+	guestCart := api.Group("/cart")
+	guestCart.Get("/", s.CustomerHandler.GetGuestCart)
+	guestCart.Post("/", s.CustomerHandler.AddToGuestCart)
+	guestCart.Put("/:bookId", s.CustomerHandler.UpdateGuestCartItem)
+	guestCart.Delete("/:bookId", s.CustomerHandler.RemoveFromGuestCart)
+
+	// Unduhan ebook memakai URL bertanda tangan, bukan JWT
+	api.Get("/library/download/:id", s.LibraryHandler.Download)
+
 	// --- Rute Otentikasi ---
 	auth := api.Group("/auth")
 	auth.Post("/login", s.AuthHandler.Login)
@@ -44,21 +59,61 @@ func Setup(s *server.Server) {
 	auth.Post("/google/mobile", s.AuthHandler.GoogleMobileSignIn)
 
 	// --- Rute Profil Pengguna (terproteksi) ---
-	me := api.Group("/me", middleware.Protected())
+	me := api.Group("/me", middleware.Protected(), idempotency)
 	me.Get("/", s.UserHandler.GetMyProfile)
 	me.Put("/", s.UserHandler.UpdateMyProfile)
 	me.Post("/avatar", s.UserHandler.UploadMyAvatar)
 
+	addresses := me.Group("/addresses")
+	addresses.Get("/", s.AddressHandler.GetMyAddresses)
+	addresses.Post("/", s.AddressHandler.CreateMyAddress)
+	addresses.Get("/:id", s.AddressHandler.GetMyAddress)
+	addresses.Put("/:id", s.AddressHandler.UpdateMyAddress)
+	addresses.Delete("/:id", s.AddressHandler.DeleteMyAddress)
+
+	library := me.Group("/library")
+	library.Get("/", s.LibraryHandler.GetMyLibrary)
+	library.Get("/continue-reading", s.LibraryHandler.GetContinueReading)
+	library.Post("/:bookId/download", s.LibraryHandler.CreateDownloadLink)
+	library.Post("/:bookId/rental/extend", s.LibraryHandler.ExtendRental)
+	library.Post("/:bookId/rental/purchase", s.LibraryHandler.ConvertRental)
+	library.Get("/:bookId/progress", s.LibraryHandler.GetProgress)
+	library.Put("/:bookId/progress", s.LibraryHandler.UpdateProgress)
+	library.Get("/:bookId/bookmarks", s.LibraryHandler.GetBookmarks)
+	library.Post("/:bookId/bookmarks", s.LibraryHandler.CreateBookmark)
+	library.Delete("/:bookId/bookmarks/:id", s.LibraryHandler.DeleteBookmark)
+	library.Get("/:bookId/highlights", s.LibraryHandler.GetHighlights)
+	library.Post("/:bookId/highlights", s.LibraryHandler.CreateHighlight)
+	library.Put("/:bookId/highlights/:id", s.LibraryHandler.UpdateHighlight)
+	library.Delete("/:bookId/highlights/:id", s.LibraryHandler.DeleteHighlight)
+
+	wallet := me.Group("/wallet")
+	wallet.Get("/", s.WalletHandler.GetMyWallet)
+	wallet.Post("/redeem", s.WalletHandler.RedeemGiftCard)
+	me.Get("/gift-cards", s.WalletHandler.GetMyGiftCards)
+
+	points := me.Group("/points")
+	points.Get("/", s.LoyaltyHandler.GetMyPoints)
+	points.Get("/history", s.LoyaltyHandler.GetMyPointsHistory)
+
 	wishlist := me.Group("/wishlist")
 	wishlist.Get("/", s.CustomerHandler.GetMyWishlist)
 	wishlist.Post("/", s.CustomerHandler.AddToWishlist)
 	wishlist.Delete("/:bookId", s.CustomerHandler.RemoveFromWishlist)
 
 	// --- Rute Pelanggan (terproteksi) ---
-	customer := api.Group("/customer", middleware.Protected())
+	customer := api.Group("/customer", middleware.Protected(), idempotency)
 	customer.Get("/orders", s.CustomerHandler.GetCustomerOrders)
 	customer.Get("/orders/:id", s.CustomerHandler.GetCustomerOrderDetail)
+	customer.Get("/orders/:id/invoice.pdf", s.CustomerHandler.GetCustomerOrderInvoice)
+	customer.Post("/orders/:id/cancel", s.CustomerHandler.CancelCustomerOrder)
+	customer.Post("/orders/:id/returns", s.ReturnHandler.CreateReturn)
+	customer.Get("/returns", s.ReturnHandler.GetMyReturns)
+	customer.Post("/returns/photos", s.ReturnHandler.UploadReturnPhoto)
+	customer.Get("/returns/:id", s.ReturnHandler.GetMyReturn)
+	customer.Post("/returns/:id/cancel", s.ReturnHandler.CancelMyReturn)
 	customer.Post("/checkout", s.CustomerHandler.Checkout)
+	customer.Post("/checkout/preview", s.CustomerHandler.PreviewCheckout)
 	// PINDAHKAN RUTE CREATE REVIEW KE SINI
 	customer.Post("/books/:id/reviews", s.CustomerHandler.CreateReview)
 
@@ -67,6 +122,8 @@ func Setup(s *server.Server) {
 	cart.Post("/", s.CustomerHandler.AddToCart)
 	cart.Put("/:itemId", s.CustomerHandler.UpdateCartItem)
 	cart.Delete("/:itemId", s.CustomerHandler.RemoveFromCart)
+	cart.Post("/sync", s.CustomerHandler.SyncCart) // Gabungkan keranjang tamu setelah login
+	cart.Post("/reserve", s.CustomerHandler.ReserveCart)
 
 	// Rute untuk Admin (memerlukan login dengan role admin)
 	admin := api.Group("/admin", middleware.Protected(), middleware.CheckRole("admin"))
@@ -75,17 +132,69 @@ func Setup(s *server.Server) {
 	admin.Get("/books/:id", s.AdminHandler.AdminGetBook)
 	admin.Put("/books/:id", s.AdminHandler.AdminUpdateBook)
 	admin.Delete("/books/:id", s.AdminHandler.AdminDeleteBook)
+	admin.Post("/books/:id/ebook", s.AdminHandler.AdminUploadEbook)
+	admin.Post("/books/:id/preview", s.AdminHandler.AdminUploadPreview)
+	admin.Delete("/books/:id/preview", s.AdminHandler.AdminDeletePreview)
+	admin.Put("/books/:id/rental-prices", s.AdminHandler.AdminUpdateRentalPrices)
+	admin.Put("/books/:id/dimensions", s.AdminHandler.AdminUpdateBookDimensions)
+	admin.Put("/books/:id/tax-class", s.AdminHandler.AdminUpdateBookTaxClass)
 
 	// --- Manajemen Pengguna ---
 	admin.Get("/users", s.AdminHandler.AdminGetUsers)
 	admin.Put("/users/:id", s.AdminHandler.AdminUpdateUser)
+	admin.Get("/users/:id/wallet", s.WalletHandler.AdminGetUserWallet)
+	admin.Post("/users/:id/wallet/adjust", s.WalletHandler.AdminAdjustWallet)
+	admin.Get("/users/:id/points", s.LoyaltyHandler.AdminGetUserPoints)
+	admin.Post("/users/:id/points/adjust", s.LoyaltyHandler.AdminAdjustPoints)
 
 	// --- Manajemen Pesanan ---
 	admin.Get("/orders", s.AdminHandler.AdminGetOrders)
 	admin.Get("/orders/:id", s.AdminHandler.AdminGetOrderDetail)
+	admin.Get("/orders/:id/invoice.pdf", s.AdminHandler.AdminGetOrderInvoice)
 	admin.Put("/orders/:id/status", s.AdminHandler.AdminUpdateOrderStatus)
+	admin.Get("/orders/:id/history", s.AdminHandler.AdminGetOrderHistory)
+	admin.Post("/orders/:id/items/cancel", s.AdminHandler.AdminCancelOrderItems)
+	admin.Get("/orders/:id/shipments", s.AdminHandler.AdminGetOrderShipments)
+	admin.Post("/shipments/:id/events", s.AdminHandler.AdminAddShipmentEvent)
+
+	// --- Retur (RMA) ---
+	admin.Get("/returns", s.ReturnHandler.AdminGetReturns)
+	admin.Get("/returns/:id", s.ReturnHandler.AdminGetReturn)
+	admin.Post("/returns/:id/approve", s.ReturnHandler.AdminApproveReturn)
+	admin.Post("/returns/:id/reject", s.ReturnHandler.AdminRejectReturn)
+	admin.Post("/returns/:id/receive", s.ReturnHandler.AdminReceiveReturn)
+	admin.Post("/returns/:id/refund", s.ReturnHandler.AdminRefundReturn)
+
+	// --- Manajemen Kupon ---
+	admin.Get("/coupons", s.AdminHandler.AdminGetCoupons)
+	admin.Post("/coupons", s.AdminHandler.AdminCreateCoupon)
+	admin.Get("/coupons/:id", s.AdminHandler.AdminGetCoupon)
+	admin.Put("/coupons/:id", s.AdminHandler.AdminUpdateCoupon)
+	admin.Delete("/coupons/:id", s.AdminHandler.AdminDeleteCoupon)
+	admin.Get("/coupons/:id/stats", s.AdminHandler.AdminGetCouponStats)
+
+	// --- Manajemen Flash Sale ---
+	admin.Get("/flash-sales", s.AdminHandler.AdminGetFlashSales)
+	admin.Post("/flash-sales", s.AdminHandler.AdminCreateFlashSale)
+	admin.Get("/flash-sales/:id", s.AdminHandler.AdminGetFlashSale)
+	admin.Put("/flash-sales/:id", s.AdminHandler.AdminUpdateFlashSale)
+	admin.Delete("/flash-sales/:id", s.AdminHandler.AdminDeleteFlashSale)
+
+	// --- Manajemen Promosi ---
+	admin.Get("/promotions", s.AdminHandler.AdminGetPromotions)
+	admin.Post("/promotions", s.AdminHandler.AdminCreatePromotion)
+	admin.Get("/promotions/:id", s.AdminHandler.AdminGetPromotion)
+	admin.Put("/promotions/:id", s.AdminHandler.AdminUpdatePromotion)
+	admin.Delete("/promotions/:id", s.AdminHandler.AdminDeletePromotion)
+
+	// --- Laporan ---
+	admin.Get("/reports/tax", s.AdminHandler.AdminGetTaxReport)
+	admin.Get("/reports/returns", s.ReturnHandler.AdminGetReturnReport)
 
 	// Rute untuk webhook
 	s.App.Post("/midtrans/notification", s.PaymentHandler.MidtransNotification)
+	if strings.EqualFold(s.Cfg.PaymentGateway, "fake") {
+		s.App.Get("/fake-payment/:orderID", s.PaymentHandler.FakePayment)
+	}
 
 }
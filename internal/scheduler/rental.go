@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"fmt"
+	"html"
+	"ngabaca/config"
+	"ngabaca/database"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
+	"time"
+)
+
+// rentalReminderWindow adalah jarak waktu sebelum sewa berakhir untuk mengirim pengingat.
+const rentalReminderWindow = 48 * time.Hour
+
+// ExpireRentals menonaktifkan sewa ebook yang masa berlakunya sudah habis.
+func ExpireRentals() {
+	fmt.Printf("[%s] Menjalankan tugas kedaluwarsa sewa ebook...\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	expired, err := repository.NewEntitlementRepository(database.DB).ExpireRentals()
+	if err != nil {
+		fmt.Println("Error saat menandai sewa kedaluwarsa:", err)
+		return
+	}
+	fmt.Printf("Berhasil menandai %d sewa sebagai kedaluwarsa.\n", expired)
+}
+
+// NotifyExpiringRentals mengirim email pengingat untuk sewa yang akan berakhir dalam 48 jam.
+// Setiap sewa hanya diingatkan sekali; perpanjangan akan mengatur ulang penanda ini.
+func NotifyExpiringRentals(cfg config.Config) {
+	fmt.Printf("[%s] Menjalankan tugas pengingat sewa ebook...\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	entitlementRepo := repository.NewEntitlementRepository(database.DB)
+	rentals, err := entitlementRepo.FindRentalsExpiringBefore(time.Now().Add(rentalReminderWindow))
+	if err != nil {
+		fmt.Println("Error saat mencari sewa yang akan berakhir:", err)
+		return
+	}
+
+	sent := 0
+	for _, rental := range rentals {
+		subject := fmt.Sprintf("Sewa ebook \"%s\" akan segera berakhir", rental.Book.Title)
+		body := fmt.Sprintf(
+			"<p>Halo %s,</p><p>Masa sewa ebook <strong>%s</strong> akan berakhir pada %s.</p>"+
+				"<p>Perpanjang sewa atau beli ebook ini dari perpustakaanmu agar tetap bisa membacanya. "+
+				"Biaya sewa yang sudah dibayar akan dipotong dari harga beli.</p>",
+			html.EscapeString(rental.User.Name), html.EscapeString(rental.Book.Title), rental.ExpiresAt.Format("02 Jan 2006 15:04"),
+		)
+		if err := utils.SendMail(cfg, rental.User.Email, subject, body); err != nil {
+			fmt.Printf("  - Gagal mengirim pengingat sewa %s: %v\n", rental.ID, err)
+			continue
+		}
+		if err := entitlementRepo.MarkExpiryNotified(rental.ID); err != nil {
+			fmt.Printf("  - Gagal menandai pengingat sewa %s: %v\n", rental.ID, err)
+			continue
+		}
+		sent++
+	}
+	fmt.Printf("Berhasil mengirim %d dari %d pengingat sewa.\n", sent, len(rentals))
+}
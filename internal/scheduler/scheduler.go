@@ -2,8 +2,12 @@ package scheduler
 
 import (
 	"fmt"
+	"ngabaca/config"
 	"ngabaca/database"
 	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/service"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,10 +15,11 @@ import (
 
 // CancelExpiredOrders adalah fungsi yang akan dijalankan oleh cron job.
 // Fungsi ini mencari pembayaran yang 'pending' dan sudah melewati waktu kedaluwarsa.
-func CancelExpiredOrders() {
+func CancelExpiredOrders(cfg config.Config) {
 	fmt.Printf("[%s] Menjalankan tugas pembatalan pesanan kedaluwarsa...\n", time.Now().Format("2006-01-02 15:04:05"))
 
 	var expiredPayments []model.Payment
+	statusService := newOrderStatusService(cfg)
 
 	// 1. Cari semua pembayaran yang statusnya 'pending' dan sudah kedaluwarsa.
 	err := database.DB.Where("status = ? AND expires_at < ?", "pending", time.Now()).Find(&expiredPayments).Error
@@ -30,36 +35,30 @@ func CancelExpiredOrders() {
 
 	fmt.Printf("Ditemukan %d pesanan kedaluwarsa. Memproses pembatalan...\n", len(expiredPayments))
 
-	// 2. Gunakan transaksi untuk memastikan semua operasi (batal & kembalikan stok) berhasil.
+	// 2. Gunakan transaksi untuk memastikan semua operasi (batal & lepas stok) berhasil.
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
 		for _, payment := range expiredPayments {
-			// Update status payment menjadi 'failed'
-			payment.Status = "failed"
-			if err := tx.Save(&payment).Error; err != nil {
+			order, err := repository.NewOrderRepository(tx).FindByIDForUpdate(payment.OrderID)
+			if err != nil {
 				return err
 			}
 
-			// Update status order menjadi 'batal'
-			if err := tx.Model(&model.Order{}).Where("id = ?", payment.OrderID).Update("status", "batal").Error; err != nil {
-				return err
+			// 3. Batalkan pesanan lewat state machine yang sekaligus menandai pembayaran gagal dan melepas stok yang ditahan
+			// serta kupon, kuota flash sale, saldo dompet, dan poin loyalti yang dipakai
+			if order.Status == model.OrderStatusPending {
+				change := service.OrderStatusChange{Actor: model.OrderActorSystem, Note: "Batas waktu pembayaran habis"}
+				if err := statusService.Transition(tx, &order, model.OrderStatusCancelled, change); err != nil {
+					return err
+				}
+				fmt.Printf("  - Pesanan %s dibatalkan, stok, kupon, kuota flash sale, saldo dompet, dan poin loyalti dilepas\n", payment.OrderID)
+				continue
 			}
 
-			// 3. Kembalikan stok buku
-			var items []model.OrderItem
-			if err := tx.Where("order_id = ?", payment.OrderID).Find(&items).Error; err != nil {
+			// Pesanan sudah tidak menunggu pembayaran, cukup tandai pembayarannya gagal
+			payment.Status = "failed"
+			if err := tx.Save(&payment).Error; err != nil {
 				return err
 			}
-
-			for _, item := range items {
-				// Gunakan gorm.Expr untuk operasi atomik (menghindari race condition)
-				err := tx.Model(&model.Book{}).Where("id = ?", item.BookID).
-					Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error
-
-				if err != nil {
-					return err
-				}
-				fmt.Printf("  - Stok untuk buku ID %d dikembalikan sebanyak %d\n", item.BookID, item.Quantity)
-			}
 		}
 		return nil
 	})
@@ -70,3 +69,87 @@ func CancelExpiredOrders() {
 		fmt.Printf("Berhasil membatalkan %d pesanan kedaluwarsa.\n", len(expiredPayments))
 	}
 }
+
+// newOrderStatusService menyusun OrderStatusService beserta dependensinya untuk tugas terjadwal.
+func newOrderStatusService(cfg config.Config) service.OrderStatusService {
+	walletService := service.NewWalletService(database.DB)
+	return service.NewOrderStatusService(
+		service.NewLibraryService(repository.NewEntitlementRepository(database.DB), cfg),
+		service.NewReservationService(database.DB, cfg),
+		service.NewCouponService(),
+		service.NewFlashSaleService(database.DB, database.RDB),
+		walletService,
+		service.NewGiftCardService(database.DB, repository.NewGiftCardRepository(database.DB), walletService),
+		service.NewLoyaltyService(database.DB, cfg),
+	)
+}
+
+// ExpireReservations menandai reservasi stok yang sudah lewat masa berlakunya
+// sehingga stoknya kembali tersedia untuk pembeli lain.
+func ExpireReservations() {
+	expired, err := repository.NewReservationRepository(database.DB).ExpireStale()
+	if err != nil {
+		fmt.Println("Error saat menandai reservasi kedaluwarsa:", err)
+		return
+	}
+	if expired > 0 {
+		fmt.Printf("[%s] %d reservasi stok kedaluwarsa.\n", time.Now().Format("2006-01-02 15:04:05"), expired)
+	}
+}
+
+// SyncFlashSaleCounters menyamakan penghitung kuota flash sale di Redis dengan database.
+func SyncFlashSaleCounters() {
+	if err := service.NewFlashSaleService(database.DB, database.RDB).SyncCounters(); err != nil {
+		fmt.Println("Error saat menyinkronkan penghitung flash sale:", err)
+	}
+}
+
+// shipmentTracker dibuat sekali agar pelacak tiruan tetap menyimpan perjalanan paket di antara polling.
+var (
+	shipmentTracker     service.ShipmentTracker
+	shipmentTrackerOnce sync.Once
+)
+
+// PollShipments mengambil status terbaru pengiriman yang masih berjalan dari API kurir.
+// Paket yang sudah diterima menyelesaikan pesanannya.
+func PollShipments(cfg config.Config) {
+	shipmentTrackerOnce.Do(func() { shipmentTracker = service.NewShipmentTracker(cfg) })
+	tracker := shipmentTracker
+	if tracker == nil {
+		return
+	}
+	shipmentService := service.NewShipmentService(database.DB, tracker, newOrderStatusService(cfg))
+	updated, err := shipmentService.PollAll()
+	if err != nil {
+		fmt.Println("Error saat melacak pengiriman:", err)
+		return
+	}
+	if updated > 0 {
+		fmt.Printf("[%s] %d pengiriman mendapat status baru.\n", time.Now().Format("2006-01-02 15:04:05"), updated)
+	}
+}
+
+// SettleRefunds mengirim ulang refund yang belum sampai ke payment gateway.
+func SettleRefunds(cfg config.Config) {
+	refundService := service.NewRefundService(database.DB, service.NewPaymentGateway(cfg), service.NewWalletService(database.DB))
+	settled, err := refundService.SettlePending()
+	if err != nil {
+		fmt.Println("Error saat mengirim ulang refund:", err)
+		return
+	}
+	if settled > 0 {
+		fmt.Printf("[%s] %d refund berhasil dikirim ulang ke payment gateway.\n", time.Now().Format("2006-01-02 15:04:05"), settled)
+	}
+}
+
+// ExpireLoyaltyPoints menghanguskan poin loyalti yang sudah melewati masa berlakunya.
+func ExpireLoyaltyPoints(cfg config.Config) {
+	fmt.Printf("[%s] Menjalankan tugas hangus poin loyalti...\n", time.Now().Format("2006-01-02 15:04:05"))
+
+	users, err := service.NewLoyaltyService(database.DB, cfg).ExpirePoints()
+	if err != nil {
+		fmt.Println("Error saat menghanguskan poin loyalti:", err)
+		return
+	}
+	fmt.Printf("Berhasil menghanguskan poin milik %d pengguna.\n", users)
+}
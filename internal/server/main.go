@@ -8,6 +8,7 @@ import (
 	"ngabaca/internal/repository"
 	"ngabaca/internal/service"
 	"ngabaca/internal/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -25,6 +26,11 @@ type Server struct {
 	CustomerHandler *handler.CustomerHandler
 	PaymentHandler  *handler.PaymentHandler
 	UserHandler     *handler.UserHandler
+	LibraryHandler  *handler.LibraryHandler
+	WalletHandler   *handler.WalletHandler
+	LoyaltyHandler  *handler.LoyaltyHandler
+	AddressHandler  *handler.AddressHandler
+	ReturnHandler   *handler.ReturnHandler
 }
 
 // NewServer adalah constructor yang merakit semua komponen aplikasi.
@@ -56,24 +62,56 @@ func NewServer() *Server {
 	paymentRepo := repository.NewPaymentRepository(db)
 	cartRepo := repository.NewCartRepository(db)
 	whistlistRepo := repository.NewWishlistRepository(db)
+	entitlementRepo := repository.NewEntitlementRepository(db)
+	readingRepo := repository.NewReadingRepository(db)
+	couponRepo := repository.NewCouponRepository(db)
+	flashSaleRepo := repository.NewFlashSaleRepository(db)
+	promotionRepo := repository.NewPromotionRepository(db)
+	giftCardRepo := repository.NewGiftCardRepository(db)
+	addressRepo := repository.NewAddressRepository(db)
+	returnRepo := repository.NewReturnRepository(db)
+	guestCartRepo := repository.NewGuestCartRepository(database.RDB, time.Duration(cfg.GuestCartTTLHours)*time.Hour)
 
-	orderService := service.NewOrderService(db, bookRepo, orderRepo, paymentRepo)
-	paymentService := service.NewPaymentService(db, orderRepo, paymentRepo)
+	libraryService := service.NewLibraryService(entitlementRepo, cfg)
+	readingService := service.NewReadingService(readingRepo, entitlementRepo)
+	reservationService := service.NewReservationService(db, cfg)
+	shippingService := service.NewShippingService(cfg)
+	pricingService := service.NewPricingService(cfg, shippingService)
+	couponService := service.NewCouponService()
+	flashSaleService := service.NewFlashSaleService(db, database.RDB)
+	walletService := service.NewWalletService(db)
+	giftCardService := service.NewGiftCardService(db, giftCardRepo, walletService)
+	loyaltyService := service.NewLoyaltyService(db, cfg)
+	invoiceService := service.NewInvoiceService(orderRepo, cfg)
+	paymentGateway := service.NewPaymentGateway(cfg)
+	refundService := service.NewRefundService(db, paymentGateway, walletService)
+	orderStatusService := service.NewOrderStatusService(libraryService, reservationService, couponService, flashSaleService, walletService, giftCardService, loyaltyService)
+	orderService := service.NewOrderService(db, bookRepo, orderRepo, paymentRepo, libraryService, reservationService, pricingService, couponService, flashSaleService, walletService, giftCardService, loyaltyService, invoiceService, paymentGateway, refundService, orderStatusService, cfg)
+	paymentService := service.NewPaymentService(db, orderRepo, paymentRepo, orderStatusService, refundService, invoiceService)
+	cartService := service.NewCartService(db, bookRepo, guestCartRepo, cfg)
+	returnService := service.NewReturnService(db, refundService, cfg)
+	shipmentService := service.NewShipmentService(db, service.NewShipmentTracker(cfg), orderStatusService)
 	// Inisialisasi semua handler
-	adminHandler := handler.NewAdminHandler(bookRepo, userRepo, orderRepo, cfg)
+	adminHandler := handler.NewAdminHandler(bookRepo, userRepo, orderRepo, categoryRepo, couponRepo, flashSaleRepo, promotionRepo, orderService, invoiceService, shipmentService, cfg)
 	authHandler := handler.NewAuthHandler(userRepo, cfg)
 	publicHandler := handler.NewPublicHandler(bookRepo, categoryRepo)
-	customerHandler := handler.NewCustomerHandler(orderRepo, userRepo, orderService, reviewRepo, whistlistRepo, cartRepo, cfg)
+	customerHandler := handler.NewCustomerHandler(orderRepo, userRepo, orderService, reviewRepo, whistlistRepo, cartRepo, cartService, reservationService, invoiceService, cfg)
 	paymentHandler := handler.NewPaymentHandler(paymentService)
 	userHandler := handler.NewUserHandler(userRepo, cfg)
+	libraryHandler := handler.NewLibraryHandler(libraryService, readingService, orderService, userRepo)
+	walletHandler := handler.NewWalletHandler(walletService, giftCardService, userRepo)
+	loyaltyHandler := handler.NewLoyaltyHandler(loyaltyService, userRepo)
+	addressHandler := handler.NewAddressHandler(addressRepo)
+	returnHandler := handler.NewReturnHandler(returnService, returnRepo, orderRepo, cfg)
 
 	// Buat instance Fiber
 	app := fiber.New()
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-Cart-Token,Idempotency-Key",
+		ExposeHeaders: "X-Cart-Token,Idempotent-Replayed",
 	}))
 
 	// Kembalikan Server struct yang sudah lengkap
@@ -87,5 +125,10 @@ func NewServer() *Server {
 		CustomerHandler: customerHandler,
 		PaymentHandler:  paymentHandler,
 		UserHandler:     userHandler,
+		LibraryHandler:  libraryHandler,
+		WalletHandler:   walletHandler,
+		LoyaltyHandler:  loyaltyHandler,
+		AddressHandler:  addressHandler,
+		ReturnHandler:   returnHandler,
 	}
 }
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"errors"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Aturan penggabungan keranjang tamu ke keranjang pengguna.
+const (
+	CartMergeSum     = "sum"     // Jumlahkan kuantitas keduanya
+	CartMergeMax     = "max"     // Ambil kuantitas terbesar
+	CartMergeReplace = "replace" // Kuantitas dari keranjang tamu menggantikan yang lama
+)
+
+var (
+	ErrInvalidCartToken = errors.New("invalid cart token")
+	ErrCartBookNotFound = errors.New("book not found")
+	ErrCartItemNotFound = errors.New("item not found in cart")
+)
+
+// GuestCartLine adalah satu baris keranjang tamu beserta data bukunya.
+type GuestCartLine struct {
+	Book     model.Book
+	Quantity int
+}
+
+// CartMergeResult merangkum hasil penggabungan keranjang saat login.
+type CartMergeResult struct {
+	MergedItems     int         `json:"merged_items"`
+	AdjustedBookIDs []uuid.UUID `json:"adjusted_book_ids"` // Kuantitas dikurangi karena stok atau batas ebook
+	SkippedBookIDs  []uuid.UUID `json:"skipped_book_ids"`  // Buku tidak ditemukan atau stok habis
+}
+
+// CartService mengelola keranjang tamu dan penggabungannya ke keranjang pengguna.
+type CartService interface {
+	GetGuestCart(token string) ([]GuestCartLine, error)
+	AddGuestItem(token string, bookID uuid.UUID, quantity int) (string, error)
+	UpdateGuestItem(token string, bookID uuid.UUID, quantity int) error
+	RemoveGuestItem(token string, bookID uuid.UUID) error
+	MergeIntoUserCart(userID uuid.UUID, token string, items []repository.GuestCartItem) (*CartMergeResult, error)
+}
+
+type cartService struct {
+	db            *gorm.DB
+	bookRepo      repository.BookRepository
+	guestCartRepo repository.GuestCartRepository
+	cfg           config.Config
+}
+
+func NewCartService(db *gorm.DB, bookRepo repository.BookRepository, guestCartRepo repository.GuestCartRepository, cfg config.Config) CartService {
+	return &cartService{db, bookRepo, guestCartRepo, cfg}
+}
+
+// validToken memastikan cart token berformat UUID agar tidak bisa dipakai untuk kunci Redis sembarangan.
+func validToken(token string) bool {
+	_, err := uuid.Parse(token)
+	return err == nil
+}
+
+func (s *cartService) GetGuestCart(token string) ([]GuestCartLine, error) {
+	lines := []GuestCartLine{}
+	if token == "" {
+		return lines, nil
+	}
+	if !validToken(token) {
+		return nil, ErrInvalidCartToken
+	}
+
+	cart, err := s.guestCartRepo.Get(token)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range cart.Items {
+		book, err := s.bookRepo.FindByID(item.BookID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue // Buku sudah dihapus
+			}
+			return nil, err
+		}
+		lines = append(lines, GuestCartLine{Book: book, Quantity: item.Quantity})
+	}
+	return lines, nil
+}
+
+// AddGuestItem menambahkan buku ke keranjang tamu. Jika token kosong, keranjang baru dibuat
+// dan token barunya dikembalikan.
+func (s *cartService) AddGuestItem(token string, bookID uuid.UUID, quantity int) (string, error) {
+	if token == "" {
+		token = uuid.NewString()
+	} else if !validToken(token) {
+		return "", ErrInvalidCartToken
+	}
+
+	if _, err := s.bookRepo.FindByID(bookID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", ErrCartBookNotFound
+		}
+		return "", err
+	}
+
+	err := s.guestCartRepo.Update(token, func(cart *repository.GuestCart) error {
+		for i := range cart.Items {
+			if cart.Items[i].BookID == bookID {
+				cart.Items[i].Quantity += quantity
+				return nil
+			}
+		}
+		cart.Items = append(cart.Items, repository.GuestCartItem{BookID: bookID, Quantity: quantity})
+		return nil
+	})
+	return token, err
+}
+
+func (s *cartService) UpdateGuestItem(token string, bookID uuid.UUID, quantity int) error {
+	if !validToken(token) {
+		return ErrInvalidCartToken
+	}
+	return s.guestCartRepo.Update(token, func(cart *repository.GuestCart) error {
+		for i := range cart.Items {
+			if cart.Items[i].BookID == bookID {
+				cart.Items[i].Quantity = quantity
+				return nil
+			}
+		}
+		return ErrCartItemNotFound
+	})
+}
+
+func (s *cartService) RemoveGuestItem(token string, bookID uuid.UUID) error {
+	if !validToken(token) {
+		return ErrInvalidCartToken
+	}
+	return s.guestCartRepo.Update(token, func(cart *repository.GuestCart) error {
+		for i := range cart.Items {
+			if cart.Items[i].BookID == bookID {
+				cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+				return nil
+			}
+		}
+		return ErrCartItemNotFound
+	})
+}
+
+// MergeIntoUserCart menggabungkan keranjang tamu (dari token dan/atau item yang dikirim klien)
+// ke keranjang pengguna sesuai aturan CART_MERGE_STRATEGY. Keranjang tamu dihapus setelah berhasil.
+func (s *cartService) MergeIntoUserCart(userID uuid.UUID, token string, items []repository.GuestCartItem) (*CartMergeResult, error) {
+	if token != "" && !validToken(token) {
+		return nil, ErrInvalidCartToken
+	}
+
+	// Kumpulkan kuantitas tamu per buku, dengan urutan tetap seperti saat ditambahkan
+	guestQty := map[uuid.UUID]int{}
+	var order []uuid.UUID
+	collect := func(list []repository.GuestCartItem) {
+		for _, item := range list {
+			if item.Quantity <= 0 {
+				continue
+			}
+			if _, ok := guestQty[item.BookID]; !ok {
+				order = append(order, item.BookID)
+			}
+			guestQty[item.BookID] += item.Quantity
+		}
+	}
+	if token != "" {
+		guestCart, err := s.guestCartRepo.Get(token)
+		if err != nil {
+			return nil, err
+		}
+		collect(guestCart.Items)
+	}
+	collect(items)
+
+	result := &CartMergeResult{AdjustedBookIDs: []uuid.UUID{}, SkippedBookIDs: []uuid.UUID{}}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txCartRepo := repository.NewCartRepository(tx)
+		txBookRepo := repository.NewBookRepository(tx)
+
+		cart, err := txCartRepo.GetCartByUserID(userID)
+		if err != nil {
+			return err
+		}
+		userQty := map[uuid.UUID]int{}
+		for _, item := range cart.CartItems {
+			userQty[item.BookID] = item.Quantity
+		}
+
+		for _, bookID := range order {
+			book, err := txBookRepo.FindByID(bookID)
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					result.SkippedBookIDs = append(result.SkippedBookIDs, bookID)
+					continue
+				}
+				return err
+			}
+
+			wanted := s.mergeQuantity(userQty[bookID], guestQty[bookID])
+			quantity := s.capQuantity(book, wanted)
+			if quantity <= 0 {
+				result.SkippedBookIDs = append(result.SkippedBookIDs, bookID)
+				continue
+			}
+			if quantity < wanted {
+				result.AdjustedBookIDs = append(result.AdjustedBookIDs, bookID)
+			}
+
+			if err := txCartRepo.SetItemQuantity(userID, bookID, quantity); err != nil {
+				return err
+			}
+			result.MergedItems++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		if err := s.guestCartRepo.Delete(token); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// mergeQuantity menerapkan aturan penggabungan untuk satu buku.
+func (s *cartService) mergeQuantity(userQty, guestQty int) int {
+	switch s.cfg.CartMergeStrategy {
+	case CartMergeMax:
+		if userQty > guestQty {
+			return userQty
+		}
+		return guestQty
+	case CartMergeReplace:
+		return guestQty
+	default:
+		return userQty + guestQty
+	}
+}
+
+// capQuantity membatasi kuantitas ke stok tersedia (jika diaktifkan) dan ke 1 untuk ebook.
+func (s *cartService) capQuantity(book model.Book, quantity int) int {
+	if book.IsDigital() {
+		if quantity > 1 {
+			return 1
+		}
+		return quantity
+	}
+	if s.cfg.CartMergeCapToStock && book.IsShippable() && quantity > book.Stock {
+		return book.Stock
+	}
+	return quantity
+}
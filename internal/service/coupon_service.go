@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrCouponNotFound = errors.New("coupon not found")
+
+// CouponService mencatat dan melepas pemakaian kupon pada pesanan.
+type CouponService interface {
+	RedeemForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) error
+	ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error
+}
+
+type couponService struct{}
+
+func NewCouponService() CouponService {
+	return &couponService{}
+}
+
+// RedeemForOrder mencatat pemakaian kupon dari rincian harga di dalam transaksi checkout.
+// Kuota total dikurangi secara atomik, lalu batas per pengguna dicek ulang selagi baris kupon terkunci.
+func (s *couponService) RedeemForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) error {
+	if quote.Coupon == nil {
+		return nil
+	}
+	coupon := quote.Coupon
+	txCouponRepo := repository.NewCouponRepository(tx)
+
+	ok, err := txCouponRepo.IncrementUsage(coupon.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCouponExhausted
+	}
+
+	if coupon.PerUserLimit > 0 {
+		used, err := txCouponRepo.CountUserRedemptions(coupon.ID, userID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+
+	return txCouponRepo.CreateRedemption(&model.CouponRedemption{
+		CouponID: coupon.ID,
+		UserID:   userID,
+		OrderID:  orderID,
+		Amount:   quote.CouponDiscount,
+	})
+}
+
+// ReleaseForOrder mengembalikan kuota kupon dari pesanan yang batal.
+func (s *couponService) ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txCouponRepo := repository.NewCouponRepository(tx)
+	redemptions, err := txCouponRepo.FindActiveRedemptionsByOrder(orderID)
+	if err != nil {
+		return err
+	}
+	for _, r := range redemptions {
+		if err := txCouponRepo.ReleaseRedemption(r.ID); err != nil {
+			return err
+		}
+		if err := txCouponRepo.DecrementUsage(r.CouponID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
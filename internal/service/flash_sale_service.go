@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrFlashSaleSoldOut = errors.New("flash sale quota has been sold out")
+	ErrFlashSaleLimit   = errors.New("you have reached the purchase limit for this flash sale")
+)
+
+// reserveQuotaScript menambah penghitung kuota hanya jika hasilnya tidak melebihi kuota.
+// KEYS[1] = penghitung, ARGV[1] = jumlah, ARGV[2] = kuota. Mengembalikan -1 jika kuota tidak cukup.
+var reserveQuotaScript = redis.NewScript(`
+local sold = redis.call('INCRBY', KEYS[1], ARGV[1])
+if sold > tonumber(ARGV[2]) then
+	redis.call('DECRBY', KEYS[1], ARGV[1])
+	return -1
+end
+return sold
+`)
+
+// FlashSaleService menjaga kuota flash sale saat checkout.
+// Penghitung di Redis menyaring lonjakan permintaan sebelum menyentuh database, sedangkan
+// update bersyarat di database tetap menjadi penjaga terakhir agar tidak terjadi oversell.
+// Penghitung Redis tidak ikut rollback, sehingga pemanggil ReserveForOrder wajib memanggil
+// ReleaseCounters jika transaksinya gagal setelah ReserveForOrder berhasil.
+type FlashSaleService interface {
+	ReserveForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) error
+	ReleaseCounters(quote *PriceQuote)
+	ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error
+	SyncCounters() error
+}
+
+type flashSaleService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+func NewFlashSaleService(db *gorm.DB, rdb *redis.Client) FlashSaleService {
+	return &flashSaleService{db, rdb}
+}
+
+func flashSaleCounterKey(itemID uuid.UUID) string {
+	return "flash_sale:sold:" + itemID.String()
+}
+
+// ReserveForOrder mengambil kuota flash sale untuk setiap baris pesanan yang memakai harga flash sale.
+func (s *flashSaleService) ReserveForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) (err error) {
+	ctx := context.Background()
+	txFlashSaleRepo := repository.NewFlashSaleRepository(tx)
+
+	// Kembalikan penghitung Redis jika ada langkah yang gagal
+	var taken []QuoteLine
+	defer func() {
+		if err != nil {
+			s.releaseCounters(ctx, taken)
+		}
+	}()
+
+	for _, line := range quote.Lines {
+		if line.FlashSaleItemID == nil {
+			continue
+		}
+		offer := line.Book.FlashSale
+		itemID := *line.FlashSaleItemID
+
+		if offer != nil && offer.Quota > 0 {
+			if err := s.seedCounter(ctx, itemID, offer); err != nil {
+				return err
+			}
+			sold, err := reserveQuotaScript.Run(ctx, s.rdb, []string{flashSaleCounterKey(itemID)}, line.Quantity, offer.Quota).Int()
+			if err != nil {
+				return err
+			}
+			if sold < 0 {
+				return fmt.Errorf("%w: %s", ErrFlashSaleSoldOut, line.Title)
+			}
+			taken = append(taken, line)
+		}
+
+		ok, err := txFlashSaleRepo.IncrementSold(itemID, line.Quantity)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrFlashSaleSoldOut, line.Title)
+		}
+
+		if offer != nil && offer.PerCustomerLimit > 0 {
+			bought, err := txFlashSaleRepo.SumUserQuantity(itemID, userID)
+			if err != nil {
+				return err
+			}
+			if bought+line.Quantity > offer.PerCustomerLimit {
+				return fmt.Errorf("%w: %s", ErrFlashSaleLimit, line.Title)
+			}
+		}
+
+		if err := txFlashSaleRepo.CreatePurchase(&model.FlashSalePurchase{
+			FlashSaleItemID: itemID,
+			UserID:          userID,
+			OrderID:         orderID,
+			Quantity:        line.Quantity,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseCounters mengembalikan kuota di penghitung Redis yang diambil ReserveForOrder untuk quote,
+// dipanggil setelah transaksi checkout batal sehingga perubahan di database sudah ikut dibatalkan.
+func (s *flashSaleService) ReleaseCounters(quote *PriceQuote) {
+	var taken []QuoteLine
+	for _, line := range quote.Lines {
+		if line.FlashSaleItemID != nil && line.Book.FlashSale != nil && line.Book.FlashSale.Quota > 0 {
+			taken = append(taken, line)
+		}
+	}
+	s.releaseCounters(context.Background(), taken)
+}
+
+func (s *flashSaleService) releaseCounters(ctx context.Context, lines []QuoteLine) {
+	for _, line := range lines {
+		if err := s.rdb.DecrBy(ctx, flashSaleCounterKey(*line.FlashSaleItemID), int64(line.Quantity)).Err(); err != nil {
+			log.Printf("Gagal mengembalikan penghitung flash sale %s: %v", *line.FlashSaleItemID, err)
+		}
+	}
+}
+
+// seedCounter mengisi penghitung Redis dari database jika belum ada.
+func (s *flashSaleService) seedCounter(ctx context.Context, itemID uuid.UUID, offer *model.FlashSaleOffer) error {
+	sold := offer.Quota
+	if offer.Remaining != nil {
+		sold = offer.Quota - *offer.Remaining
+	}
+	ttl := time.Until(offer.EndsAt) + time.Hour
+	return s.rdb.SetNX(ctx, flashSaleCounterKey(itemID), sold, ttl).Err()
+}
+
+// ReleaseForOrder mengembalikan kuota flash sale dari pesanan yang batal. Penghitung Redis tidak
+// dikurangi karena transaksi pembatalan masih bisa rollback; penghitungnya dihapus agar diisi ulang
+// dari database pada checkout berikutnya. Selama pembatalan belum commit, isi ulang itu masih
+// menghitung kuota yang dilepas, dan SyncCounters meluruskannya kembali.
+func (s *flashSaleService) ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	ctx := context.Background()
+	txFlashSaleRepo := repository.NewFlashSaleRepository(tx)
+
+	purchases, err := txFlashSaleRepo.FindActivePurchasesByOrder(orderID)
+	if err != nil {
+		return err
+	}
+	for _, p := range purchases {
+		if err := txFlashSaleRepo.ReleasePurchase(p.ID); err != nil {
+			return err
+		}
+		if err := txFlashSaleRepo.DecrementSold(p.FlashSaleItemID, p.Quantity); err != nil {
+			return err
+		}
+		if err := s.rdb.Del(ctx, flashSaleCounterKey(p.FlashSaleItemID)).Err(); err != nil {
+			log.Printf("Gagal menghapus penghitung flash sale %s: %v", p.FlashSaleItemID, err)
+		}
+	}
+	return nil
+}
+
+// SyncCounters menyamakan penghitung Redis dengan jumlah terjual di database, misalnya setelah
+// transaksi checkout gagal di tengah jalan. Selisih sesaat aman karena database tetap memeriksa kuota.
+func (s *flashSaleService) SyncCounters() error {
+	ctx := context.Background()
+	items, err := repository.NewFlashSaleRepository(s.db).FindRunningItems(nil, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.Quota == 0 {
+			continue
+		}
+		ttl := time.Until(item.FlashSale.EndsAt) + time.Hour
+		if err := s.rdb.Set(ctx, flashSaleCounterKey(item.ID), item.SoldCount, ttl).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
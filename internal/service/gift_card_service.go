@@ -0,0 +1,145 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGiftCardNotFound        = errors.New("gift card code is invalid")
+	ErrGiftCardAlreadyRedeemed = errors.New("gift card has already been redeemed")
+	ErrGiftCardOrderRedeemed   = errors.New("a gift card from this order has already been redeemed")
+)
+
+// giftCardAlphabet tidak memuat karakter yang mudah tertukar seperti 0/O dan 1/I.
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GiftCardService menerbitkan gift card dari pesanan yang lunas dan menukarnya menjadi saldo dompet.
+type GiftCardService interface {
+	IssueForOrder(tx *gorm.DB, order *model.Order) error
+	RevokeForOrder(tx *gorm.DB, orderID uuid.UUID) error
+	Redeem(userID uuid.UUID, code string) (*model.WalletTransaction, error)
+	GetPurchased(userID uuid.UUID) ([]model.GiftCard, error)
+}
+
+type giftCardService struct {
+	db            *gorm.DB
+	giftCardRepo  repository.GiftCardRepository
+	walletService WalletService
+}
+
+func NewGiftCardService(db *gorm.DB, giftCardRepo repository.GiftCardRepository, walletService WalletService) GiftCardService {
+	return &giftCardService{db, giftCardRepo, walletService}
+}
+
+// generateGiftCardCode membuat kode acak dengan format NGB-XXXX-XXXX-XXXX.
+func generateGiftCardCode() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("NGB")
+	for i, b := range buf {
+		if i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(giftCardAlphabet[int(b)%len(giftCardAlphabet)])
+	}
+	return sb.String(), nil
+}
+
+// IssueForOrder menerbitkan kode untuk setiap gift card di dalam pesanan yang lunas.
+// Nilai gift card mengikuti harga nominal produk, bukan harga setelah potongan.
+func (s *giftCardService) IssueForOrder(tx *gorm.DB, order *model.Order) error {
+	txGiftCardRepo := repository.NewGiftCardRepository(tx)
+
+	for _, item := range order.OrderItems {
+		if !item.Book.IsGiftCard() {
+			continue
+		}
+		issued, err := txGiftCardRepo.CountByOrderAndBook(order.ID, item.BookID)
+		if err != nil {
+			return err
+		}
+		for n := int(issued); n < item.Quantity; n++ {
+			code, err := generateGiftCardCode()
+			if err != nil {
+				return err
+			}
+			if err := txGiftCardRepo.Create(&model.GiftCard{
+				Code:          code,
+				Amount:        item.Book.Price,
+				BookID:        item.BookID,
+				OrderID:       order.ID,
+				PurchasedByID: order.UserID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RevokeForOrder membatalkan gift card yang diterbitkan dari pesanan yang dibatalkan. Pembatalan
+// ditolak bila salah satu kodenya sudah ditukar, karena saldonya sudah berpindah ke dompet penukar.
+func (s *giftCardService) RevokeForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txGiftCardRepo := repository.NewGiftCardRepository(tx)
+	cards, err := txGiftCardRepo.FindByOrderForUpdate(orderID)
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		if card.Status == model.GiftCardStatusRedeemed {
+			return ErrGiftCardOrderRedeemed
+		}
+	}
+	return txGiftCardRepo.VoidIssuedByOrder(orderID)
+}
+
+// Redeem menukar gift card menjadi saldo dompet pengguna. Baris gift card dikunci dan statusnya
+// diubah secara bersyarat agar satu kode tidak bisa ditukar dua kali.
+func (s *giftCardService) Redeem(userID uuid.UUID, code string) (*model.WalletTransaction, error) {
+	var transaction *model.WalletTransaction
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txGiftCardRepo := repository.NewGiftCardRepository(tx)
+		card, err := txGiftCardRepo.FindByCodeForUpdate(strings.ToUpper(strings.TrimSpace(code)))
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrGiftCardNotFound
+			}
+			return err
+		}
+
+		ok, err := txGiftCardRepo.MarkRedeemed(card.ID, userID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if card.Status == model.GiftCardStatusVoided {
+				return ErrGiftCardNotFound
+			}
+			return ErrGiftCardAlreadyRedeemed
+		}
+
+		transaction, err = s.walletService.Credit(tx, userID, card.Amount, WalletEntry{
+			Source:     model.WalletSourceGiftCard,
+			GiftCardID: &card.ID,
+			Note:       "Penukaran gift card " + card.Code,
+		})
+		return err
+	})
+	return transaction, err
+}
+
+func (s *giftCardService) GetPurchased(userID uuid.UUID) ([]model.GiftCard, error) {
+	return s.giftCardRepo.FindByPurchaser(userID)
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvoiceNotAvailable = errors.New("invoice is only available for paid orders")
+
+// paymentMethodLabels adalah nama metode pembayaran yang dicetak di faktur, berdasarkan payment_type Midtrans.
+var paymentMethodLabels = map[string]string{
+	"wallet":        "Saldo Dompet",
+	"bank_transfer": "Transfer Bank (Virtual Account)",
+	"echannel":      "Mandiri Bill Payment",
+	"permata":       "Permata Virtual Account",
+	"credit_card":   "Kartu Kredit/Debit",
+	"gopay":         "GoPay",
+	"shopeepay":     "ShopeePay",
+	"qris":          "QRIS",
+	"cstore":        "Gerai Retail",
+	"akulaku":       "Akulaku",
+	"kredivo":       "Kredivo",
+}
+
+// InvoiceService menerbitkan dan menyimpan faktur PDF untuk pesanan yang sudah lunas.
+type InvoiceService interface {
+	IssueForOrder(orderID uuid.UUID) error
+	GetPDF(orderID uuid.UUID) ([]byte, error)
+}
+
+type invoiceService struct {
+	orderRepo repository.OrderRepository
+	cfg       config.Config
+}
+
+func NewInvoiceService(orderRepo repository.OrderRepository, cfg config.Config) InvoiceService {
+	return &invoiceService{orderRepo, cfg}
+}
+
+// IssueForOrder menerbitkan faktur untuk pesanan yang baru lunas lalu mengirimkannya sebagai lampiran
+// email konfirmasi pembayaran. Dipanggil setelah transaksi pembayaran selesai; pesanan yang sudah
+// punya faktur dilewati karena notifikasi pembayaran bisa terkirim berulang.
+func (s *invoiceService) IssueForOrder(orderID uuid.UUID) error {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return err
+	}
+	if order.Payment.Status != "success" {
+		return ErrInvoiceNotAvailable
+	}
+	if order.InvoicePath != "" {
+		return nil
+	}
+
+	pdf, issued, err := s.issue(&order)
+	if err != nil || !issued {
+		return err
+	}
+
+	// Email dikirim di latar belakang agar lambatnya server SMTP tidak menahan notifikasi pembayaran
+	go s.sendConfirmation(order, pdf)
+	return nil
+}
+
+// GetPDF mengambil faktur pesanan dari storage. Pesanan yang lunas sebelum ada fitur faktur
+// diterbitkan saat pertama kali diminta, tanpa mengirim email.
+func (s *invoiceService) GetPDF(orderID uuid.UUID) ([]byte, error) {
+	order, err := s.orderRepo.FindByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Payment.Status != "success" {
+		return nil, ErrInvoiceNotAvailable
+	}
+	if order.InvoicePath == "" {
+		pdf, issued, err := s.issue(&order)
+		if err != nil || issued {
+			return pdf, err
+		}
+	}
+
+	data, err := os.ReadFile(order.InvoicePath)
+	if err == nil || !os.IsNotExist(err) {
+		return data, err
+	}
+	// File hilang dari storage, buat ulang dengan nomor dan tanggal faktur yang sudah tercatat
+	return s.render(order)
+}
+
+// issue mencatat nomor faktur pesanan lalu menyimpan PDF-nya. Hasil false berarti faktur sudah
+// diterbitkan lebih dulu oleh proses lain, dan order diisi ulang dengan data faktur tersebut.
+func (s *invoiceService) issue(order *model.Order) ([]byte, bool, error) {
+	issuedAt := time.Now()
+	number := model.FormatInvoiceNumber(utils.DefaultString(order.OrderNumber, order.ID.String()))
+	path := filepath.Join(s.cfg.InvoiceStoragePath, number+".pdf")
+
+	claimed, err := s.orderRepo.SetInvoice(order.ID, number, path, issuedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if !claimed {
+		current, err := s.orderRepo.FindByID(order.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		*order = current
+		return nil, false, nil
+	}
+
+	order.InvoiceNumber, order.InvoicePath, order.InvoiceIssuedAt = number, path, &issuedAt
+	pdf, err := s.render(*order)
+	return pdf, true, err
+}
+
+// render membuat PDF faktur dari data pesanan dan menyimpannya ke InvoicePath.
+func (s *invoiceService) render(order model.Order) ([]byte, error) {
+	pdf := utils.RenderInvoicePDF(s.buildInvoice(order))
+	if err := os.MkdirAll(filepath.Dir(order.InvoicePath), 0o750); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(order.InvoicePath, pdf, 0o640); err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// buildInvoice menyusun isi faktur dari pesanan. order harus dimuat bersama User, OrderItems.Book, dan Payment.
+func (s *invoiceService) buildInvoice(order model.Order) utils.Invoice {
+	issuedAt := time.Now()
+	if order.InvoiceIssuedAt != nil {
+		issuedAt = *order.InvoiceIssuedAt
+	}
+
+	invoice := utils.Invoice{
+		StoreName:     s.cfg.StoreName,
+		StoreAddress:  s.cfg.StoreAddress,
+		StoreNPWP:     s.cfg.StoreNPWP,
+		Number:        order.InvoiceNumber,
+		OrderNumber:   utils.DefaultString(order.OrderNumber, order.ID.String()),
+		IssuedAt:      issuedAt,
+		PaymentMethod: paymentMethodLabel(order.Payment.PaymentMethod),
+		PaidAt:        order.Payment.VerifiedAt,
+		CustomerName:  order.User.Name,
+		CustomerEmail: order.User.Email,
+	}
+
+	if order.ShippingAddressSnapshot != nil {
+		a := order.ShippingAddressSnapshot
+		invoice.ShippingAddress = fmt.Sprintf("%s (%s)\n%s\n%s, %s\n%s %s",
+			a.RecipientName, a.Phone, a.Detail, a.District, a.City, a.Province, a.PostalCode)
+	} else if order.ShippingAddress != "" {
+		invoice.ShippingAddress = strings.TrimSpace(order.ShippingAddress + " " + order.ShippingPostalCode)
+	}
+	if order.ShippingCourier != "" {
+		invoice.ShippingMethod = strings.TrimSpace(strings.ToUpper(order.ShippingCourier) + " " + order.ShippingService)
+	}
+
+	taxBase := map[float64]float64{}
+	taxAmount := map[float64]float64{}
+	for _, item := range order.OrderItems {
+		invoice.Lines = append(invoice.Lines, utils.InvoiceLine{
+			Description: invoiceItemDescription(item),
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			Discount:    item.Discount,
+			TaxRate:     item.TaxRate,
+			Amount:      item.Price*float64(item.Quantity) - item.Discount,
+		})
+		if item.TaxAmount > 0 {
+			taxBase[item.TaxRate] += item.TaxBase
+			taxAmount[item.TaxRate] += item.TaxAmount
+		}
+	}
+	rates := make([]float64, 0, len(taxAmount))
+	for rate := range taxAmount {
+		rates = append(rates, rate)
+	}
+	sort.Float64s(rates)
+
+	invoice.Totals = append(invoice.Totals, utils.InvoiceTotal{Label: "Subtotal", Amount: order.Subtotal})
+	if order.DiscountTotal > 0 {
+		label := "Diskon"
+		if order.CouponCode != "" {
+			label = fmt.Sprintf("Diskon (kupon %s)", order.CouponCode)
+		}
+		invoice.Totals = append(invoice.Totals, utils.InvoiceTotal{Label: label, Amount: -order.DiscountTotal})
+	}
+	if order.ShippingCost > 0 {
+		invoice.Totals = append(invoice.Totals, utils.InvoiceTotal{Label: "Ongkos Kirim", Amount: order.ShippingCost})
+	}
+	if !order.TaxInclusive {
+		for _, rate := range rates {
+			invoice.Totals = append(invoice.Totals, utils.InvoiceTotal{Label: fmt.Sprintf("PPN %g%%", rate), Amount: taxAmount[rate]})
+		}
+	}
+	invoice.Totals = append(invoice.Totals, utils.InvoiceTotal{Label: "Total", Amount: order.TotalPrice, Bold: true})
+	if order.WalletAmount > 0 && order.AmountDue() > 0 {
+		invoice.Totals = append(invoice.Totals,
+			utils.InvoiceTotal{Label: "Dibayar dengan Saldo Dompet", Amount: order.WalletAmount},
+			utils.InvoiceTotal{Label: "Dibayar via " + invoice.PaymentMethod, Amount: order.AmountDue()},
+		)
+	}
+
+	if order.TaxInclusive {
+		for _, rate := range rates {
+			invoice.Notes = append(invoice.Notes, fmt.Sprintf("Harga sudah termasuk PPN %g%% sebesar Rp%.0f dengan dasar pengenaan pajak (DPP) Rp%.0f.",
+				rate, taxAmount[rate], taxBase[rate]))
+		}
+	}
+	if order.PointsRedeemed > 0 {
+		invoice.Notes = append(invoice.Notes, fmt.Sprintf("Diskon termasuk penukaran %d poin loyalti.", order.PointsRedeemed))
+	}
+	invoice.Notes = append(invoice.Notes, "Faktur ini diterbitkan secara elektronik dan sah tanpa tanda tangan.")
+	return invoice
+}
+
+// sendConfirmation mengirim email konfirmasi pembayaran dengan faktur sebagai lampiran.
+func (s *invoiceService) sendConfirmation(order model.Order, pdf []byte) {
+	orderNumber := utils.DefaultString(order.OrderNumber, order.ID.String())
+	subject := fmt.Sprintf("Pembayaran pesanan %s berhasil", orderNumber)
+	body := fmt.Sprintf(
+		"<p>Halo %s,</p><p>Pembayaran untuk pesanan <strong>%s</strong> sebesar Rp%.0f sudah kami terima pada %s.</p>"+
+			"<p>Faktur pembelian terlampir di email ini dan juga bisa diunduh dari halaman detail pesanan.</p>",
+		order.User.Name, orderNumber, order.TotalPrice, order.Payment.VerifiedAt.Format("02 Jan 2006 15:04"),
+	)
+	attachment := utils.MailAttachment{Filename: order.InvoiceNumber + ".pdf", ContentType: "application/pdf", Data: pdf}
+	if err := utils.SendMailWithAttachments(s.cfg, order.User.Email, subject, body, attachment); err != nil {
+		fmt.Printf("Gagal mengirim faktur pesanan %s: %v\n", orderNumber, err)
+	}
+}
+
+func paymentMethodLabel(method string) string {
+	if label, ok := paymentMethodLabels[method]; ok {
+		return label
+	}
+	return utils.DefaultString(method, "-")
+}
+
+// invoiceItemDescription menuliskan nama item faktur beserta jenis transaksinya untuk item sewa.
+func invoiceItemDescription(item model.OrderItem) string {
+	switch item.Kind {
+	case model.OrderItemKindRental:
+		return fmt.Sprintf("%s (sewa %d hari)", item.Book.Title, item.RentalDays)
+	case model.OrderItemKindRentalExtension:
+		return fmt.Sprintf("%s (perpanjangan sewa %d hari)", item.Book.Title, item.RentalDays)
+	case model.OrderItemKindRentalConversion:
+		return fmt.Sprintf("%s (konversi sewa ke beli)", item.Book.Title)
+	}
+	return item.Book.Title
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotEntitled       = errors.New("you don't own this ebook")
+	ErrDownloadLimit     = errors.New("download limit reached for this ebook")
+	ErrInvalidSignature  = errors.New("download link is invalid or has expired")
+	ErrEbookFileNotFound = errors.New("ebook file is not available")
+)
+
+// DownloadLink adalah URL bertanda tangan untuk mengunduh ebook.
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// DownloadFile adalah file ebook yang siap dikirim ke pembeli.
+type DownloadFile struct {
+	Path     string
+	FileName string
+}
+
+// LibraryService mengelola hak akses dan pengiriman ebook.
+type LibraryService interface {
+	GrantForOrder(tx *gorm.DB, order *model.Order) error
+	RevokeForOrder(tx *gorm.DB, orderID uuid.UUID) error
+	GetLibrary(userID uuid.UUID) ([]model.Entitlement, error)
+	CreateDownloadLink(userID, bookID uuid.UUID) (*DownloadLink, error)
+	OpenDownload(entitlementID uuid.UUID, expires int64, signature, ip, userAgent string) (*DownloadFile, error)
+}
+
+type libraryService struct {
+	entitlementRepo repository.EntitlementRepository
+	cfg             config.Config
+}
+
+func NewLibraryService(entitlementRepo repository.EntitlementRepository, cfg config.Config) LibraryService {
+	return &libraryService{entitlementRepo, cfg}
+}
+
+// GrantForOrder membuat atau memperbarui hak akses untuk setiap ebook di dalam pesanan:
+// pembelian dan sewa baru membuat entitlement, perpanjangan menambah masa sewa,
+// dan konversi mengubah sewa menjadi kepemilikan permanen.
+// Dipanggil di dalam transaksi pembayaran, sekali untuk setiap pesanan yang lunas.
+func (s *libraryService) GrantForOrder(tx *gorm.DB, order *model.Order) error {
+	txEntitlementRepo := repository.NewEntitlementRepository(tx)
+
+	for _, item := range order.OrderItems {
+		switch item.Kind {
+		case model.OrderItemKindRentalExtension, model.OrderItemKindRentalConversion:
+			if err := s.applyRentalChange(txEntitlementRepo, item); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !item.Book.IsDigital() {
+			continue
+		}
+
+		exists, err := txEntitlementRepo.ExistsForOrder(order.ID, item.BookID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		entitlement := &model.Entitlement{
+			UserID:       order.UserID,
+			BookID:       item.BookID,
+			OrderID:      order.ID,
+			Type:         model.EntitlementTypePurchase,
+			MaxDownloads: s.cfg.MaxDownloadsPerEbook,
+		}
+		if item.Kind == model.OrderItemKindRental {
+			expiresAt := time.Now().AddDate(0, 0, item.RentalDays)
+			entitlement.Type = model.EntitlementTypeRental
+			entitlement.ExpiresAt = &expiresAt
+			entitlement.RentalFeePaid = item.Price - item.Discount
+		}
+		if err := txEntitlementRepo.Create(entitlement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeForOrder mencabut hak akses ebook yang diberikan oleh pesanan yang dibatalkan setelah lunas,
+// sehingga tautan unduhan yang sudah dibuat pun tidak bisa dipakai lagi.
+func (s *libraryService) RevokeForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	return repository.NewEntitlementRepository(tx).RevokeByOrder(orderID)
+}
+
+// applyRentalChange menerapkan perpanjangan atau konversi pada sewa yang dirujuk item pesanan.
+func (s *libraryService) applyRentalChange(entitlementRepo repository.EntitlementRepository, item model.OrderItem) error {
+	if item.EntitlementID == nil {
+		return fmt.Errorf("order item %s is missing its rental reference", item.ID)
+	}
+	entitlement, err := entitlementRepo.FindByID(*item.EntitlementID)
+	if err != nil {
+		return err
+	}
+	if !entitlement.IsRental() {
+		return nil
+	}
+
+	switch item.Kind {
+	case model.OrderItemKindRentalExtension:
+		// Perpanjangan dihitung dari akhir sewa, atau dari sekarang jika sewa sudah berakhir
+		base := time.Now()
+		if entitlement.ExpiresAt != nil && entitlement.ExpiresAt.After(base) {
+			base = *entitlement.ExpiresAt
+		}
+		expiresAt := base.AddDate(0, 0, item.RentalDays)
+		entitlement.ExpiresAt = &expiresAt
+		entitlement.RentalFeePaid += item.Price - item.Discount
+		entitlement.ExpiryNotifiedAt = nil
+	case model.OrderItemKindRentalConversion:
+		entitlement.Type = model.EntitlementTypePurchase
+		entitlement.ExpiresAt = nil
+		entitlement.ExpiryNotifiedAt = nil
+	}
+	entitlement.Status = model.EntitlementStatusActive
+	return entitlementRepo.Update(&entitlement)
+}
+
+func (s *libraryService) GetLibrary(userID uuid.UUID) ([]model.Entitlement, error) {
+	return s.entitlementRepo.FindByUserID(userID)
+}
+
+// CreateDownloadLink menerbitkan URL unduhan berumur pendek untuk ebook milik pengguna.
+func (s *libraryService) CreateDownloadLink(userID, bookID uuid.UUID) (*DownloadLink, error) {
+	entitlement, err := s.entitlementRepo.FindActiveByUserAndBook(userID, bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotEntitled
+		}
+		return nil, err
+	}
+	if entitlement.RemainingDownloads() == 0 {
+		return nil, ErrDownloadLimit
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.cfg.DownloadLinkTTLMinutes) * time.Minute)
+	signature := utils.SignResource(s.cfg.DownloadSigningSecret, entitlement.ID.String(), expiresAt.Unix())
+
+	return &DownloadLink{
+		URL: fmt.Sprintf("%s/api/v2/library/download/%s?expires=%d&signature=%s",
+			s.cfg.AppURL, entitlement.ID, expiresAt.Unix(), signature),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// OpenDownload memverifikasi URL bertanda tangan, menyiapkan file ber-watermark,
+// memotong kuota unduhan, dan mencatat akses.
+func (s *libraryService) OpenDownload(entitlementID uuid.UUID, expires int64, signature, ip, userAgent string) (*DownloadFile, error) {
+	if !utils.VerifyResourceSignature(s.cfg.DownloadSigningSecret, entitlementID.String(), expires, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	entitlement, err := s.entitlementRepo.FindByID(entitlementID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotEntitled
+		}
+		return nil, err
+	}
+	if !entitlement.IsActive() {
+		return nil, ErrNotEntitled
+	}
+
+	if entitlement.Book.PrivateFilePath == "" {
+		return nil, ErrEbookFileNotFound
+	}
+	if _, err := os.Stat(entitlement.Book.PrivateFilePath); err != nil {
+		return nil, ErrEbookFileNotFound
+	}
+
+	path, err := s.watermarkedFile(entitlement)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.entitlementRepo.ConsumeDownload(entitlement.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDownloadLimit
+	}
+
+	if err := s.entitlementRepo.LogDownload(&model.DownloadLog{
+		EntitlementID: entitlement.ID,
+		UserID:        entitlement.UserID,
+		BookID:        entitlement.BookID,
+		IPAddress:     ip,
+		UserAgent:     userAgent,
+	}); err != nil {
+		fmt.Println("Gagal mencatat log unduhan:", err)
+	}
+
+	return &DownloadFile{
+		Path:     path,
+		FileName: entitlement.Book.Slug + filepath.Ext(entitlement.Book.PrivateFilePath),
+	}, nil
+}
+
+// watermarkedFile mengembalikan salinan ebook yang sudah dicap identitas pembeli.
+// Salinan disimpan per entitlement dan dibuat ulang jika file sumber berubah.
+func (s *libraryService) watermarkedFile(entitlement model.Entitlement) (string, error) {
+	source := entitlement.Book.PrivateFilePath
+	ext := strings.ToLower(filepath.Ext(source))
+	cachePath := filepath.Join(s.cfg.WatermarkCachePath, entitlement.ID.String()+ext)
+
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return "", ErrEbookFileNotFound
+	}
+	if cacheInfo, err := os.Stat(cachePath); err == nil && cacheInfo.ModTime().After(sourceInfo.ModTime()) {
+		return cachePath, nil
+	}
+
+	if err := os.MkdirAll(s.cfg.WatermarkCachePath, 0o750); err != nil {
+		return "", err
+	}
+
+	stamp := utils.BuyerStamp{
+		Name:    entitlement.User.Name,
+		Email:   entitlement.User.Email,
+		OrderID: entitlement.OrderID.String(),
+	}
+
+	// Tulis ke file sementara lalu rename agar unduhan paralel tidak membaca file setengah jadi
+	tmpPath := cachePath + "." + uuid.NewString() + ".tmp"
+	switch ext {
+	case ".pdf":
+		err = utils.WatermarkPDF(source, tmpPath, stamp)
+	case ".epub":
+		err = utils.WatermarkEPUB(source, tmpPath, stamp)
+	default:
+		err = fmt.Errorf("unsupported ebook format %s", ext)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to watermark ebook: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return cachePath, nil
+}
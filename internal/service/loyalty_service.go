@@ -0,0 +1,417 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInsufficientPoints   = errors.New("insufficient loyalty points")
+	ErrInvalidPointsAmount  = errors.New("points must not be zero")
+	ErrPointsRedeemDisabled = errors.New("loyalty points cannot be redeemed at the moment")
+)
+
+// LoyaltySummary adalah saldo poin, tingkat keanggotaan, dan mutasi terakhir seorang pengguna.
+type LoyaltySummary struct {
+	Balance         int                  `json:"balance"`
+	BalanceValue    float64              `json:"balance_value"` // Nilai rupiah saldo poin jika ditukar
+	Tier            string               `json:"tier"`
+	Multiplier      float64              `json:"multiplier"`
+	Spend12Months   float64              `json:"spend_12_months"`
+	NextTier        string               `json:"next_tier,omitempty"`
+	SpendToNextTier float64              `json:"spend_to_next_tier,omitempty"`
+	Entries         []model.LoyaltyEntry `json:"entries"`
+}
+
+// LoyaltyService mengelola perolehan, penukaran, dan masa berlaku poin loyalti.
+// Setiap perubahan saldo selalu disertai baris riwayat.
+type LoyaltyService interface {
+	GetSummary(userID uuid.UUID) (*LoyaltySummary, error)
+	GetHistory(userID uuid.UUID) ([]model.LoyaltyEntry, error)
+	AwardForOrder(tx *gorm.DB, order *model.Order) error
+	RedeemForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) error
+	ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error
+	ReturnForCancelledItems(tx *gorm.DB, order *model.Order, cancelled map[uuid.UUID]int) error
+	Adjust(actorID, userID uuid.UUID, points int, note string) (*model.LoyaltyEntry, error)
+	ExpirePoints() (int, error)
+}
+
+type loyaltyService struct {
+	db  *gorm.DB
+	cfg config.Config
+}
+
+func NewLoyaltyService(db *gorm.DB, cfg config.Config) LoyaltyService {
+	return &loyaltyService{db, cfg}
+}
+
+// tierFor menentukan tingkat keanggotaan dan pengali poin dari total belanja 12 bulan terakhir.
+func (s *loyaltyService) tierFor(spend float64) (tier string, multiplier float64, next string, nextSpend float64) {
+	switch {
+	case s.cfg.LoyaltyGoldSpend > 0 && spend >= s.cfg.LoyaltyGoldSpend:
+		return model.LoyaltyTierGold, s.cfg.LoyaltyGoldMultiplier, "", 0
+	case s.cfg.LoyaltySilverSpend > 0 && spend >= s.cfg.LoyaltySilverSpend:
+		return model.LoyaltyTierSilver, s.cfg.LoyaltySilverMultiplier, model.LoyaltyTierGold, s.cfg.LoyaltyGoldSpend
+	}
+	return model.LoyaltyTierRegular, 1, model.LoyaltyTierSilver, s.cfg.LoyaltySilverSpend
+}
+
+func (s *loyaltyService) GetSummary(userID uuid.UUID) (*LoyaltySummary, error) {
+	loyaltyRepo := repository.NewLoyaltyRepository(s.db)
+	account, err := loyaltyRepo.FindOrCreateAccount(userID)
+	if err != nil {
+		return nil, err
+	}
+	spend, err := loyaltyRepo.SpendSince(userID, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := loyaltyRepo.FindEntries(userID, 20)
+	if err != nil {
+		return nil, err
+	}
+
+	tier, multiplier, next, nextSpend := s.tierFor(spend)
+	summary := &LoyaltySummary{
+		Balance:       account.Balance,
+		BalanceValue:  roundRupiah(float64(account.Balance) * s.cfg.LoyaltyPointValue),
+		Tier:          tier,
+		Multiplier:    multiplier,
+		Spend12Months: spend,
+		Entries:       entries,
+	}
+	if next != "" && nextSpend > spend {
+		summary.NextTier = next
+		summary.SpendToNextTier = nextSpend - spend
+	}
+	return summary, nil
+}
+
+func (s *loyaltyService) GetHistory(userID uuid.UUID) ([]model.LoyaltyEntry, error) {
+	return repository.NewLoyaltyRepository(s.db).FindEntries(userID, 100)
+}
+
+// expiry menghitung tanggal hangus untuk poin yang masuk sekarang. Nil berarti poin tidak pernah hangus.
+func (s *loyaltyService) expiry() *time.Time {
+	if s.cfg.LoyaltyPointsExpiryMonths <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().AddDate(0, s.cfg.LoyaltyPointsExpiryMonths, 0)
+	return &expiresAt
+}
+
+// credit menambah saldo poin dan mencatatnya sebagai lot baru yang bisa dipakai atau hangus.
+func (s *loyaltyService) credit(tx *gorm.DB, entry model.LoyaltyEntry) (*model.LoyaltyEntry, error) {
+	txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+	if _, err := txLoyaltyRepo.FindOrCreateAccount(entry.UserID); err != nil {
+		return nil, err
+	}
+	if err := txLoyaltyRepo.AddBalance(entry.UserID, entry.Points); err != nil {
+		return nil, err
+	}
+	entry.Remaining = entry.Points
+	if entry.ExpiresAt == nil {
+		entry.ExpiresAt = s.expiry()
+	}
+	if err := txLoyaltyRepo.CreateEntry(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// debit mengurangi saldo poin dan memakai lot yang paling dulu hangus. entry.Points diisi nilai positif.
+// Saldo tidak akan pernah negatif karena pengurangan dilakukan dengan update bersyarat.
+func (s *loyaltyService) debit(tx *gorm.DB, entry model.LoyaltyEntry) (*model.LoyaltyEntry, error) {
+	txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+	if _, err := txLoyaltyRepo.FindOrCreateAccount(entry.UserID); err != nil {
+		return nil, err
+	}
+	ok, err := txLoyaltyRepo.SubtractBalance(entry.UserID, entry.Points)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInsufficientPoints
+	}
+
+	lots, err := txLoyaltyRepo.FindOpenLots(entry.UserID)
+	if err != nil {
+		return nil, err
+	}
+	left := entry.Points
+	for _, lot := range lots {
+		if left == 0 {
+			break
+		}
+		used := min(lot.Remaining, left)
+		if err := txLoyaltyRepo.SetRemaining(lot.ID, lot.Remaining-used); err != nil {
+			return nil, err
+		}
+		left -= used
+	}
+
+	entry.Points = -entry.Points
+	if err := txLoyaltyRepo.CreateEntry(&entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// AwardForOrder memberikan poin untuk pesanan yang selesai. Nilai belanja dihitung setelah potongan,
+// tanpa ongkir, pajak, dan gift card, lalu dikalikan pengali tingkat keanggotaan pembeli.
+// Setiap pesanan hanya mendapat poin sekali.
+func (s *loyaltyService) AwardForOrder(tx *gorm.DB, order *model.Order) error {
+	if s.cfg.LoyaltySpendPerPoint <= 0 {
+		return nil
+	}
+	txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+	awarded, err := txLoyaltyRepo.SumOrderPoints(order.ID, model.LoyaltyEntryEarn)
+	if err != nil {
+		return err
+	}
+	if awarded > 0 {
+		return nil
+	}
+
+	spend := 0.0
+	for _, item := range order.OrderItems {
+		if item.Book.IsGiftCard() {
+			continue
+		}
+		// Item yang dibatalkan setelah dibayar tidak dihitung
+		spend += (item.Price*float64(item.Quantity) - item.Discount) * float64(item.ActiveQuantity()) / float64(item.Quantity)
+	}
+
+	// Tingkat dihitung dari belanja sebelum pesanan ini selesai
+	spend12Months, err := txLoyaltyRepo.SpendSince(order.UserID, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		return err
+	}
+	tier, multiplier, _, _ := s.tierFor(spend12Months)
+
+	points := int(math.Floor(spend / s.cfg.LoyaltySpendPerPoint * multiplier))
+	if points <= 0 {
+		return nil
+	}
+	_, err = s.credit(tx, model.LoyaltyEntry{
+		UserID:  order.UserID,
+		Type:    model.LoyaltyEntryEarn,
+		Points:  points,
+		OrderID: &order.ID,
+		Note:    fmt.Sprintf("Tingkat %s (x%.2f)", tier, multiplier),
+	})
+	return err
+}
+
+// RedeemForOrder memotong poin yang ditukar pada rincian harga di dalam transaksi checkout.
+func (s *loyaltyService) RedeemForOrder(tx *gorm.DB, userID, orderID uuid.UUID, quote *PriceQuote) error {
+	if quote.PointsRedeemed <= 0 {
+		return nil
+	}
+	_, err := s.debit(tx, model.LoyaltyEntry{
+		UserID:  userID,
+		Type:    model.LoyaltyEntryRedeem,
+		Points:  quote.PointsRedeemed,
+		OrderID: &orderID,
+	})
+	return err
+}
+
+// ReleaseForOrder mengembalikan poin yang ditukar dan menarik poin yang didapat dari pesanan yang batal.
+// Aman dipanggil berulang karena hanya selisih terhadap mutasi sebelumnya yang diproses.
+// Poin yang dikembalikan menjadi lot baru dengan masa berlaku baru.
+func (s *loyaltyService) ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+	redeemed, err := txLoyaltyRepo.SumOrderPoints(orderID, model.LoyaltyEntryRedeem)
+	if err != nil {
+		return err
+	}
+	reversed, err := txLoyaltyRepo.SumOrderPoints(orderID, model.LoyaltyEntryReversal)
+	if err != nil {
+		return err
+	}
+	earned, err := txLoyaltyRepo.SumOrderPoints(orderID, model.LoyaltyEntryEarn)
+	if err != nil {
+		return err
+	}
+	revoked, err := txLoyaltyRepo.SumOrderPoints(orderID, model.LoyaltyEntryRevoke)
+	if err != nil {
+		return err
+	}
+	toReturn := -redeemed - reversed
+	toRevoke := earned + revoked
+	if toReturn <= 0 && toRevoke <= 0 {
+		return nil
+	}
+
+	var order model.Order
+	if err := tx.Select("id", "user_id").First(&order, orderID).Error; err != nil {
+		return err
+	}
+	if toReturn > 0 {
+		if _, err := s.credit(tx, model.LoyaltyEntry{UserID: order.UserID, Type: model.LoyaltyEntryReversal, Points: toReturn, OrderID: &orderID}); err != nil {
+			return err
+		}
+	}
+	if toRevoke > 0 {
+		// Poin yang sudah terlanjur dipakai tidak bisa ditarik, jadi hanya sebatas saldo yang ada
+		account, err := txLoyaltyRepo.FindOrCreateAccount(order.UserID)
+		if err != nil {
+			return err
+		}
+		toRevoke = min(toRevoke, account.Balance)
+		if toRevoke > 0 {
+			if _, err := s.debit(tx, model.LoyaltyEntry{UserID: order.UserID, Type: model.LoyaltyEntryRevoke, Points: toRevoke, OrderID: &orderID}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReturnForCancelledItems mengembalikan bagian poin yang ditukar untuk kuantitas item yang dibatalkan
+// sebagian (cancelled berisi ID item dan kuantitasnya), sebanding dengan potongan poin yang dibebankan
+// ke item tersebut. Sisa poin dikembalikan ReleaseForOrder jika seluruh pesanan batal kemudian.
+func (s *loyaltyService) ReturnForCancelledItems(tx *gorm.DB, order *model.Order, cancelled map[uuid.UUID]int) error {
+	if order.PointsRedeemed <= 0 {
+		return nil
+	}
+	total, share := 0.0, 0.0
+	for _, item := range order.OrderItems {
+		amount := pointsDiscount(item)
+		total += amount
+		if quantity := cancelled[item.ID]; quantity > 0 {
+			share += amount * float64(quantity) / float64(item.Quantity)
+		}
+	}
+	if total <= 0 || share <= 0 {
+		return nil
+	}
+
+	txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+	redeemed, err := txLoyaltyRepo.SumOrderPoints(order.ID, model.LoyaltyEntryRedeem)
+	if err != nil {
+		return err
+	}
+	reversed, err := txLoyaltyRepo.SumOrderPoints(order.ID, model.LoyaltyEntryReversal)
+	if err != nil {
+		return err
+	}
+	points := min(int(math.Round(float64(order.PointsRedeemed)*share/total)), -redeemed-reversed)
+	if points <= 0 {
+		return nil
+	}
+	_, err = s.credit(tx, model.LoyaltyEntry{
+		UserID:  order.UserID,
+		Type:    model.LoyaltyEntryReversal,
+		Points:  points,
+		OrderID: &order.ID,
+		Note:    "Pembatalan sebagian item pesanan",
+	})
+	return err
+}
+
+// pointsDiscount menjumlahkan potongan poin loyalti pada sebuah item. Potongan poin dicatat tanpa
+// promosi maupun kupon sebagai sumbernya.
+func pointsDiscount(item model.OrderItem) float64 {
+	amount := 0.0
+	for _, d := range item.Discounts {
+		if d.PromotionID == nil && d.CouponID == nil {
+			amount += d.Amount
+		}
+	}
+	return amount
+}
+
+// Adjust menambah (nilai positif) atau mengurangi (nilai negatif) poin dari panel admin.
+// Admin yang melakukan penyesuaian dicatat di riwayat sebagai jejak audit.
+func (s *loyaltyService) Adjust(actorID, userID uuid.UUID, points int, note string) (*model.LoyaltyEntry, error) {
+	if points == 0 {
+		return nil, ErrInvalidPointsAmount
+	}
+
+	var entry *model.LoyaltyEntry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		adjustment := model.LoyaltyEntry{UserID: userID, Type: model.LoyaltyEntryAdjust, ActorID: &actorID, Note: note}
+		var err error
+		if points > 0 {
+			adjustment.Points = points
+			entry, err = s.credit(tx, adjustment)
+		} else {
+			adjustment.Points = -points
+			entry, err = s.debit(tx, adjustment)
+		}
+		return err
+	})
+	return entry, err
+}
+
+// ExpirePoints menghanguskan sisa lot poin yang sudah melewati masa berlakunya.
+// Mengembalikan jumlah pengguna yang poinnya hangus.
+func (s *loyaltyService) ExpirePoints() (int, error) {
+	now := time.Now()
+	expiredLots, err := repository.NewLoyaltyRepository(s.db).FindExpiredLots(now, 500)
+	if err != nil {
+		return 0, err
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(expiredLots))
+	for _, lot := range expiredLots {
+		userIDs = append(userIDs, lot.UserID)
+	}
+
+	processed := 0
+	for _, userID := range uniqueIDs(userIDs) {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			txLoyaltyRepo := repository.NewLoyaltyRepository(tx)
+			// Lot dikunci dulu agar tidak bentrok dengan penukaran poin yang sedang berjalan
+			lots, err := txLoyaltyRepo.FindOpenLots(userID)
+			if err != nil {
+				return err
+			}
+			expired := 0
+			for _, lot := range lots {
+				if lot.ExpiresAt == nil || lot.ExpiresAt.After(now) {
+					continue
+				}
+				if err := txLoyaltyRepo.SetRemaining(lot.ID, 0); err != nil {
+					return err
+				}
+				expired += lot.Remaining
+			}
+			if expired == 0 {
+				return nil
+			}
+
+			account, err := txLoyaltyRepo.FindOrCreateAccount(userID)
+			if err != nil {
+				return err
+			}
+			expired = min(expired, account.Balance)
+			if expired == 0 {
+				return nil
+			}
+			if _, err := txLoyaltyRepo.SubtractBalance(userID, expired); err != nil {
+				return err
+			}
+			return txLoyaltyRepo.CreateEntry(&model.LoyaltyEntry{
+				UserID: userID,
+				Type:   model.LoyaltyEntryExpire,
+				Points: -expired,
+			})
+		})
+		if err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
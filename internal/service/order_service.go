@@ -1,27 +1,93 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"ngabaca/config"
 	"ngabaca/internal/model"
 	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/midtrans/midtrans-go/snap"
 	"gorm.io/gorm"
 )
 
 type CreateOrderItemRequest struct {
-	BookID   uuid.UUID `json:"book_id" validate:"required"`
-	Quantity int       `json:"quantity" validate:"required,gt=0"`
+	BookID     uuid.UUID `json:"book_id" validate:"required"`
+	Quantity   int       `json:"quantity" validate:"required,gt=0"`
+	RentalDays int       `json:"rental_days" validate:"omitempty,oneof=7 14 30"` // Isi untuk menyewa ebook alih-alih membeli
 }
 
+var (
+	ErrRentalNotFound      = errors.New("you don't have an active rental for this ebook")
+	ErrRentalNotAvailable  = errors.New("this rental duration is not available for the ebook")
+	ErrOrderNotCancellable = errors.New("this order can no longer be cancelled")
+
+	ErrOrderItemsNotCancellable = errors.New("items can only be cancelled on paid orders that are not fully shipped")
+	ErrOrderItemNotCancellable  = errors.New("only physical books from this order can be cancelled")
+	ErrOrderItemCancelExceeded  = errors.New("cancel quantity exceeds what is left to ship for this item")
+)
+
+// CancelOrderItemRequest adalah kuantitas satu item pesanan yang dibatalkan.
+type CancelOrderItemRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" validate:"required"`
+	Quantity    int       `json:"quantity" validate:"required,gt=0"`
+}
+
+// CancelOrderItemsRequest adalah permintaan admin untuk membatalkan buku fisik yang belum dikirim,
+// misalnya karena stoknya terlambat datang. Items kosong berarti semua buku yang belum dikirim.
+type CancelOrderItemsRequest struct {
+	Items        []CancelOrderItemRequest `json:"items" validate:"omitempty,dive"`
+	Reason       string                   `json:"reason" validate:"required,max=255"`
+	RefundMethod string                   `json:"refund_method" validate:"omitempty,oneof=original_payment store_credit"` // Default ke metode pembayaran asal
+}
+
+// CreateOrderRequest berisi item pesanan, baik dikirim langsung lewat Items
+// maupun diambil dari keranjang di server (FromCart atau CartItemIDs).
 type CreateOrderRequest struct {
-	Items           []CreateOrderItemRequest `json:"items" validate:"required,min=1,dive"`
-	ShippingAddress string                   `json:"shipping_address" validate:"required"`
-	Notes           string                   `json:"notes"`
+	Items              []CreateOrderItemRequest `json:"items" validate:"omitempty,dive"`
+	FromCart           bool                     `json:"from_cart"`     // Checkout seluruh isi keranjang
+	CartItemIDs        []uuid.UUID              `json:"cart_item_ids"` // Checkout sebagian item keranjang
+	CouponCode         string                   `json:"coupon_code" validate:"omitempty,max=32"`
+	RedeemPoints       int                      `json:"redeem_points" validate:"gte=0"` // Poin loyalti yang ditukar menjadi potongan
+	UseWallet          bool                     `json:"use_wallet"`                     // Bayar dengan saldo dompet
+	WalletAmount       float64                  `json:"wallet_amount" validate:"gte=0"` // Batas saldo yang dipakai, 0 berarti sebanyak mungkin
+	AddressID          *uuid.UUID               `json:"address_id"`                     // Alamat dari buku alamat, default ke alamat utama
+	ShippingAddress    string                   `json:"shipping_address"`               // Wajib jika pesanan berisi buku fisik
+	ShippingPostalCode string                   `json:"shipping_postal_code" validate:"omitempty,len=5,numeric"`
+	ShippingCourier    string                   `json:"shipping_courier"` // Kode kurir dari shipping_options di preview
+	ShippingService    string                   `json:"shipping_service"` // Kode layanan dari shipping_options di preview
+	Notes              string                   `json:"notes"`
+	CheckoutKey        string                   `json:"-"` // Idempotency-Key dari header checkout, diisi handler
+}
+
+// quoteOptions mengambil pilihan yang memengaruhi harga dari request.
+func (r *CreateOrderRequest) quoteOptions() QuoteOptions {
+	return QuoteOptions{
+		CouponCode:            r.CouponCode,
+		RedeemPoints:          r.RedeemPoints,
+		DestinationPostalCode: r.ShippingPostalCode,
+		ShippingCourier:       r.ShippingCourier,
+		ShippingService:       r.ShippingService,
+	}
 }
+
+// usesCart menandakan item pesanan diambil dari keranjang di server.
+func (r *CreateOrderRequest) usesCart() bool {
+	return r.FromCart || len(r.CartItemIDs) > 0
+}
+
 type OrderService interface {
 	CreateOrder(userID uuid.UUID, req *CreateOrderRequest) (*model.Order, float64, error)
+	PreviewOrder(userID uuid.UUID, req *CreateOrderRequest) (*PriceQuote, error)
+	UpdateStatus(orderID uuid.UUID, status string, change OrderStatusChange) (*model.Order, error)
+	CancelOrderByCustomer(userID, orderID uuid.UUID, reason string) (*model.Order, error)
+	CancelItems(actorID, orderID uuid.UUID, req *CancelOrderItemsRequest) (*model.Order, error)
+	CreateRentalExtensionOrder(userID, bookID uuid.UUID, days int) (*model.Order, error)
+	CreateRentalConversionOrder(userID, bookID uuid.UUID) (*model.Order, error)
+	StartPayment(order *model.Order, user model.User) (*snap.Response, error)
 }
 
 // CheckExisting implements repository.ReviewRepository.
@@ -40,81 +106,661 @@ func (s *orderService) FindByBookID(bookID uuid.UUID) ([]model.Review, error) {
 }
 
 type orderService struct {
-	db          *gorm.DB
-	bookRepo    repository.BookRepository
-	orderRepo   repository.OrderRepository
-	paymentRepo repository.PaymentRepository
+	db                 *gorm.DB
+	bookRepo           repository.BookRepository
+	orderRepo          repository.OrderRepository
+	paymentRepo        repository.PaymentRepository
+	libraryService     LibraryService
+	reservationService ReservationService
+	pricingService     PricingService
+	couponService      CouponService
+	flashSaleService   FlashSaleService
+	walletService      WalletService
+	giftCardService    GiftCardService
+	loyaltyService     LoyaltyService
+	invoiceService     InvoiceService
+	paymentGateway     PaymentGateway
+	refundService      RefundService
+	statusService      OrderStatusService
+	cfg                config.Config
+}
+
+func NewOrderService(db *gorm.DB, bookRepo repository.BookRepository, orderRepo repository.OrderRepository, paymentRepo repository.PaymentRepository, libraryService LibraryService, reservationService ReservationService, pricingService PricingService, couponService CouponService, flashSaleService FlashSaleService, walletService WalletService, giftCardService GiftCardService, loyaltyService LoyaltyService, invoiceService InvoiceService, paymentGateway PaymentGateway, refundService RefundService, statusService OrderStatusService, cfg config.Config) OrderService {
+	return &orderService{db, bookRepo, orderRepo, paymentRepo, libraryService, reservationService, pricingService, couponService, flashSaleService, walletService, giftCardService, loyaltyService, invoiceService, paymentGateway, refundService, statusService, cfg}
+}
+
+// resolveItems menentukan item pesanan, baik dari request maupun dari keranjang di server.
+// ID item keranjang yang dipakai ikut dikembalikan agar bisa dihapus setelah checkout.
+func (s *orderService) resolveItems(tx *gorm.DB, userID uuid.UUID, req *CreateOrderRequest) ([]CreateOrderItemRequest, []uuid.UUID, error) {
+	if !req.usesCart() {
+		if len(req.Items) == 0 {
+			return nil, nil, fmt.Errorf("Order must contain at least one item")
+		}
+		return req.Items, nil, nil
+	}
+	if len(req.Items) > 0 {
+		return nil, nil, fmt.Errorf("Send either items or cart items, not both")
+	}
+
+	cartItems, err := repository.NewCartRepository(tx).FindItems(userID, req.CartItemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cartItems) == 0 {
+		return nil, nil, fmt.Errorf("Your cart is empty")
+	}
+	if len(req.CartItemIDs) > 0 && len(cartItems) != len(uniqueIDs(req.CartItemIDs)) {
+		return nil, nil, fmt.Errorf("Some cart items were not found")
+	}
+
+	items := make([]CreateOrderItemRequest, 0, len(cartItems))
+	cartItemIDs := make([]uuid.UUID, 0, len(cartItems))
+	for _, ci := range cartItems {
+		items = append(items, CreateOrderItemRequest{BookID: ci.BookID, Quantity: ci.Quantity})
+		cartItemIDs = append(cartItemIDs, ci.ID)
+	}
+	return items, cartItemIDs, nil
+}
+
+// resolveAddress mengambil alamat dari buku alamat, baik yang dipilih lewat AddressID maupun
+// alamat utama jika pembeli tidak menulis alamat sendiri, lalu mengisi alamat dan kode pos di request.
+// Mengembalikan nil jika pembeli memakai alamat teks bebas atau belum punya alamat tersimpan.
+func (s *orderService) resolveAddress(tx *gorm.DB, userID uuid.UUID, req *CreateOrderRequest) (*model.AddressSnapshot, error) {
+	addressRepo := repository.NewAddressRepository(tx)
+	var address model.Address
+	var err error
+	switch {
+	case req.AddressID != nil:
+		address, err = addressRepo.FindByIDAndUserID(*req.AddressID, userID)
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("Address not found")
+		}
+	case req.ShippingAddress == "":
+		address, err = addressRepo.FindDefault(userID)
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := address.Snapshot()
+	req.ShippingAddress = snapshot.String()
+	req.ShippingPostalCode = snapshot.PostalCode
+	return snapshot, nil
 }
 
-func NewOrderService(db *gorm.DB, bookRepo repository.BookRepository, orderRepo repository.OrderRepository, paymentRepo repository.PaymentRepository) OrderService {
-	return &orderService{db, bookRepo, orderRepo, paymentRepo}
+// PreviewOrder menghitung rincian harga pesanan tanpa membuat pesanan maupun menahan stok.
+func (s *orderService) PreviewOrder(userID uuid.UUID, req *CreateOrderRequest) (*PriceQuote, error) {
+	items, _, err := s.resolveItems(s.db, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.resolveAddress(s.db, userID, req); err != nil {
+		return nil, err
+	}
+	return s.pricingService.Quote(s.db, userID, items, req.quoteOptions())
 }
 
 // CreateOrder berisi semua logika transaksi checkout
 func (s *orderService) CreateOrder(userID uuid.UUID, req *CreateOrderRequest) (*model.Order, float64, error) {
-	var totalPrice float64
-	var orderItems []model.OrderItem
 	var order model.Order
+	var flashSaleQuote *PriceQuote
+
+	// Tarif pengiriman diambil sebelum transaksi karena bisa memanggil API kurir. Di dalam transaksi
+	// layanan yang dipilih cukup dicocokkan ulang dengan tarif ini.
+	items, _, err := s.resolveItems(s.db, userID, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := s.resolveAddress(s.db, userID, req); err != nil {
+		return nil, 0, err
+	}
+	shippingRates, err := s.pricingService.ShippingRates(s.db, items, req.ShippingPostalCode)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Gunakan Transaksi Database
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		// Buat instance repository baru yang menggunakan 'tx' (transaksi)
-		txBookRepo := repository.NewBookRepository(tx)
 		txOrderRepo := repository.NewOrderRepository(tx)
 		txPaymentRepo := repository.NewPaymentRepository(tx)
+		txCartRepo := repository.NewCartRepository(tx)
 
-		for _, item := range req.Items {
-			book, err := txBookRepo.FindByID(item.BookID)
-			if err != nil {
-				return fmt.Errorf("Book with ID %s not found", item.BookID)
+		// Susun item dari request atau keranjang, lalu hitung harganya lewat mesin harga yang sama dengan preview
+		items, cartItemIDs, err := s.resolveItems(tx, userID, req)
+		if err != nil {
+			return err
+		}
+		addressSnapshot, err := s.resolveAddress(tx, userID, req)
+		if err != nil {
+			return err
+		}
+		opts := req.quoteOptions()
+		opts.ShippingRates = shippingRates
+		quote, err := s.pricingService.Quote(tx, userID, items, opts)
+		if err != nil {
+			return err
+		}
+		if quote.NeedsShipping {
+			if req.ShippingAddress == "" {
+				return fmt.Errorf("Shipping address is required for physical books")
+			}
+			if req.ShippingPostalCode == "" {
+				return ErrShippingDestinationRequired
 			}
-			if book.Stock < item.Quantity {
-				return fmt.Errorf("Insufficient stock for book %s", book.Title)
+			if quote.ShippingService == "" {
+				return ErrShippingServiceRequired
 			}
+		} else if addressSnapshot != nil {
+			// Pesanan tanpa buku fisik tidak perlu menyimpan alamat dari buku alamat
+			addressSnapshot = nil
+			req.ShippingAddress, req.ShippingPostalCode = "", ""
+		}
+
+		// ID pesanan dibuat lebih awal agar reservasi stok bisa langsung merujuknya
+		orderID := uuid.New()
+		reservationExpiry := s.reservationService.CheckoutExpiry()
 
-			// Kurangi stok buku
-			book.Stock -= item.Quantity
-			if _, err := txBookRepo.Update(&book); err != nil {
+		// Tahan stok buku fisik lewat reservasi; stok baru dipotong saat pembayaran berhasil
+		for _, line := range quote.Lines {
+			if !line.Book.IsShippable() {
+				continue
+			}
+			if err := s.reservationService.ReserveForOrder(tx, userID, orderID, line.BookID, line.Quantity, reservationExpiry); err != nil {
 				return err
 			}
-
-			totalPrice += book.Price * float64(item.Quantity)
-			orderItems = append(orderItems, model.OrderItem{
-				BookID:   item.BookID,
-				Quantity: item.Quantity,
-				Price:    book.Price,
-			})
 		}
 
 		// Buat record Order
 		orderToCreate := &model.Order{
-			UserID:          userID,
-			TotalPrice:      totalPrice,
-			Status:          "pending",
-			ShippingAddress: req.ShippingAddress,
-			Notes:           req.Notes,
-			OrderItems:      orderItems,
+			Basemodel:               model.Basemodel{ID: orderID},
+			UserID:                  userID,
+			Subtotal:                quote.Subtotal,
+			DiscountTotal:           quote.DiscountTotal,
+			CouponCode:              quote.CouponCode,
+			PointsRedeemed:          quote.PointsRedeemed,
+			Taxes:                   quote.Tax,
+			TaxInclusive:            quote.TaxInclusive,
+			ShippingCost:            quote.ShippingCost,
+			TotalPrice:              quote.GrandTotal,
+			Status:                  model.OrderStatusPending,
+			ShippingAddress:         req.ShippingAddress,
+			ShippingAddressSnapshot: addressSnapshot,
+			Notes:                   req.Notes,
+			ShippingPostalCode:      req.ShippingPostalCode,
+			ShippingCourier:         quote.ShippingCourier,
+			ShippingService:         quote.ShippingService,
+			OrderItems:              quote.OrderItems(),
+			CheckoutKey:             req.CheckoutKey,
 		}
+
+		// Potong saldo dompet lebih dulu agar sisa tagihan untuk payment gateway sudah pasti
+		if req.UseWallet {
+			if err := s.walletService.PayForOrder(tx, orderToCreate, req.WalletAmount); err != nil {
+				return err
+			}
+		}
+
 		createdOrder, err := txOrderRepo.Create(orderToCreate)
 		if err != nil {
 			return err
 		}
 		order = *createdOrder
+		if err := s.statusService.RecordCreated(tx, &order, OrderStatusChange{Actor: model.OrderActorCustomer, ActorID: &userID}); err != nil {
+			return err
+		}
 
-		// Buat record Payment
+		// Catat pemakaian kupon, poin loyalti, dan kuota flash sale di transaksi yang sama dengan pesanan
+		if err := s.couponService.RedeemForOrder(tx, userID, order.ID, quote); err != nil {
+			return err
+		}
+		if err := s.loyaltyService.RedeemForOrder(tx, userID, order.ID, quote); err != nil {
+			return err
+		}
+		if err := s.flashSaleService.ReserveForOrder(tx, userID, order.ID, quote); err != nil {
+			return err
+		}
+		flashSaleQuote = quote
+
+		// Buat record Payment. Pesanan berisi buku fisik harus dibayar sebelum reservasi stok habis.
+		paymentExpiry := time.Now().Add(24 * time.Hour)
+		if quote.NeedsShipping {
+			paymentExpiry = reservationExpiry
+		}
 		paymentToCreate := &model.Payment{
 			OrderID:    order.ID,
 			Status:     "pending",
-			TotalPrice: order.TotalPrice,
+			TotalPrice: order.AmountDue(),
 			Currency:   "IDR",
-			ExpiresAt:  time.Now().Add(24 * time.Hour),
+			ExpiresAt:  paymentExpiry,
+		}
+
+		// Pesanan yang lunas sepenuhnya dengan saldo dompet tidak perlu melewati payment gateway
+		paidByWallet := order.WalletAmount > 0 && order.AmountDue() <= 0
+		if paidByWallet {
+			paymentToCreate.Status = "success"
+			paymentToCreate.PaymentMethod = "wallet"
+			paymentToCreate.VerifiedAt = time.Now()
 		}
 		if _, err := txPaymentRepo.Create(paymentToCreate); err != nil {
 			return err
 		}
+		order.Payment = *paymentToCreate
 
+		// Item yang sudah dipesan dikeluarkan dari keranjang
+		if err := txCartRepo.DeleteItems(userID, cartItemIDs); err != nil {
+			return err
+		}
+
+		if paidByWallet {
+			return s.completePaidOrder(tx, &order, "Dibayar penuh dengan saldo dompet")
+		}
 		return nil // Commit transaksi
 	})
+	if err != nil && flashSaleQuote != nil {
+		// Penghitung Redis tidak ikut rollback, jadi kuota yang sudah diambil dikembalikan manual
+		s.flashSaleService.ReleaseCounters(flashSaleQuote)
+	}
+	if err == nil && order.Payment.Status == "success" {
+		s.issueInvoice(order.ID)
+	}
+
+	return &order, order.TotalPrice, err
+}
+
+// issueInvoice menerbitkan faktur untuk pesanan yang langsung lunas tanpa payment gateway.
+// Kegagalannya hanya dicatat, faktur akan dibuat ulang saat pertama kali diunduh.
+func (s *orderService) issueInvoice(orderID uuid.UUID) {
+	if err := s.invoiceService.IssueForOrder(orderID); err != nil {
+		fmt.Printf("Gagal menerbitkan faktur pesanan %s: %v\n", orderID, err)
+	}
+}
+
+// completePaidOrder menandai pesanan lunas lalu menjalankan akibatnya (akses ebook, stok, gift card).
+func (s *orderService) completePaidOrder(tx *gorm.DB, order *model.Order, note string) error {
+	paid, err := repository.NewOrderRepository(tx).FindByID(order.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.statusService.Transition(tx, &paid, model.OrderStatusProcessing, OrderStatusChange{Actor: model.OrderActorSystem, Note: note}); err != nil {
+		return err
+	}
+	*order = paid
+	return nil
+}
+
+// UpdateStatus mengubah status pesanan lewat mesin status, misalnya dari panel admin. Status yang
+// sama dengan status sekarang tidak mengubah apa pun. Pembatalan melepas stok, saldo dompet, kupon,
+// poin loyalti, dan kuota flash sale yang ditahan, lalu mengembalikan sisa dana pesanan yang sudah
+// dibayar ke metode pembayaran asal; pesanan selesai memberikan poin loyalti.
+func (s *orderService) UpdateStatus(orderID uuid.UUID, status string, change OrderStatusChange) (*model.Order, error) {
+	var order model.Order
+	var refund *model.Refund
+	cancelGateway := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = repository.NewOrderRepository(tx).FindByIDForUpdate(orderID)
+		if err != nil || order.Status == status {
+			return err
+		}
+		paid := order.Payment.Status == "success"
+		if err := s.statusService.Transition(tx, &order, status, change); err != nil {
+			return err
+		}
+		if status != model.OrderStatusCancelled {
+			return nil
+		}
+		if !paid {
+			cancelGateway = order.AmountDue() > 0
+			return nil
+		}
+		if amount := order.NetTotal(); amount > 0 {
+			reason := utils.DefaultString(change.Note, fmt.Sprintf("Pesanan %s dibatalkan admin", order.OrderNumber))
+			refund, err = s.refundService.RefundOrder(tx, &order, amount, model.RefundMethodOriginal, reason)
+		}
+		return err
+	})
+	if err != nil {
+		return &order, err
+	}
+
+	if cancelGateway {
+		s.closeGatewayTransaction(&order)
+	}
+	settleRefund(s.refundService, refund)
+	return &order, nil
+}
+
+// closeGatewayTransaction membatalkan transaksi gateway pesanan yang sudah batal agar tidak bisa
+// dibayar lagi. Dipanggil setelah pembatalan tersimpan; jika pembeli ternyata sudah membayar,
+// notifikasi pelunasannya akan mengembalikan dana untuk pesanan yang sudah batal ini.
+func (s *orderService) closeGatewayTransaction(order *model.Order) {
+	if err := s.paymentGateway.CancelTransaction(order); err != nil {
+		fmt.Printf("Gagal membatalkan transaksi gateway pesanan %s: %v\n", order.OrderNumber, err)
+	}
+}
+
+// CancelOrderByCustomer membatalkan pesanan atas permintaan pembelinya. Pesanan pending dibatalkan
+// bersama transaksinya di payment gateway. Pesanan diproses yang hanya berisi buku fisik dan belum
+// punya pengiriman dikembalikan stoknya dan dananya dikembalikan ke metode pembayaran asal.
+func (s *orderService) CancelOrderByCustomer(userID, orderID uuid.UUID, reason string) (*model.Order, error) {
+	var order model.Order
+	var refund *model.Refund
+	cancelGateway := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = repository.NewOrderRepository(tx).FindByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return gorm.ErrRecordNotFound
+		}
+
+		paid := false
+		switch {
+		case order.Status == model.OrderStatusPending && order.Payment.Status != "success":
+			cancelGateway = order.AmountDue() > 0
+		case order.Status == model.OrderStatusProcessing && order.Payment.Status == "success" && s.cfg.CustomerCancelProcessing && onlyShippableItems(order) && len(order.Shipments) == 0:
+			paid = true
+		default:
+			return ErrOrderNotCancellable
+		}
+
+		change := OrderStatusChange{Actor: model.OrderActorCustomer, ActorID: &userID, Note: reason}
+		if err := s.statusService.Transition(tx, &order, model.OrderStatusCancelled, change); err != nil {
+			return err
+		}
+		if paid {
+			refund, err = s.refundService.RefundOrder(tx, &order, order.NetTotal(), model.RefundMethodOriginal, reason)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return &order, err
+	}
+
+	if cancelGateway {
+		s.closeGatewayTransaction(&order)
+	}
+	settleRefund(s.refundService, refund)
+	return &order, nil
+}
+
+// CancelItems membatalkan sebagian buku fisik pesanan lunas yang belum dikirim. Stoknya dikembalikan,
+// nilainya dicatat sebagai pengurang total pesanan dan dikembalikan ke pembeli, lalu status pesanan
+// disesuaikan dengan sisa buku yang masih harus dikirim. Ongkir ikut dikembalikan jika tidak ada
+// lagi buku yang dikirim sama sekali.
+func (s *orderService) CancelItems(actorID, orderID uuid.UUID, req *CancelOrderItemsRequest) (*model.Order, error) {
+	var order model.Order
+	var refund *model.Refund
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txOrderRepo := repository.NewOrderRepository(tx)
+		var err error
+		order, err = txOrderRepo.FindByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if (order.Status != model.OrderStatusProcessing && order.Status != model.OrderStatusPartiallyShipped) || order.Payment.Status != "success" {
+			return ErrOrderItemsNotCancellable
+		}
+		shipped, err := repository.NewShipmentRepository(tx).ShippedQuantities(order.ID)
+		if err != nil {
+			return err
+		}
+
+		lines := req.Items
+		if len(lines) == 0 {
+			for _, item := range order.OrderItems {
+				if left := item.ActiveQuantity() - shipped[item.ID]; item.Book.IsShippable() && left > 0 {
+					lines = append(lines, CancelOrderItemRequest{OrderItemID: item.ID, Quantity: left})
+				}
+			}
+			if len(lines) == 0 {
+				return ErrShipmentNothingToShip
+			}
+		}
+
+		amount := 0.0
+		cancelled := make(map[uuid.UUID]int, len(lines))
+		for _, line := range lines {
+			item := findOrderItem(order, line.OrderItemID)
+			if item == nil || !item.Book.IsShippable() {
+				return ErrOrderItemNotCancellable
+			}
+			if line.Quantity > item.ActiveQuantity()-shipped[item.ID] {
+				return ErrOrderItemCancelExceeded
+			}
+			if err := s.reservationService.RestockItem(tx, order.ID, item.BookID, line.Quantity); err != nil {
+				return err
+			}
+			item.CancelledQuantity += line.Quantity
+			if err := txOrderRepo.SetItemCancelledQuantity(item.ID, item.CancelledQuantity); err != nil {
+				return err
+			}
+			amount += returnRefundAmount(order, *item, line.Quantity)
+			cancelled[item.ID] += line.Quantity
+		}
+		// Dana yang dikembalikan sudah dikurangi potongan poin, jadi bagian poinnya dikembalikan sebagai poin
+		if err := s.loyaltyService.ReturnForCancelledItems(tx, &order, cancelled); err != nil {
+			return err
+		}
+		if fulfilmentStatus(order, shipped) == "" {
+			amount += order.ShippingCost
+		}
+		amount = roundRupiah(amount)
+		order.CancelledAmount += amount
+		if _, err := txOrderRepo.Update(&order); err != nil {
+			return err
+		}
+
+		change := OrderStatusChange{Actor: model.OrderActorAdmin, ActorID: &actorID, Note: req.Reason}
+		if err := syncFulfilmentStatus(tx, s.statusService, &order, change); err != nil {
+			return err
+		}
+		if amount > 0 {
+			method := utils.DefaultString(req.RefundMethod, model.RefundMethodOriginal)
+			reason := fmt.Sprintf("Pembatalan sebagian pesanan %s: %s", order.OrderNumber, req.Reason)
+			refund, err = s.refundService.RefundOrder(tx, &order, amount, method, reason)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return &order, err
+	}
+
+	settleRefund(s.refundService, refund)
+	return &order, nil
+}
+
+// findOrderItem mencari item pesanan berdasarkan ID-nya. Hasilnya menunjuk ke elemen
+// order.OrderItems sehingga perubahan ikut tersimpan di pesanan.
+func findOrderItem(order model.Order, itemID uuid.UUID) *model.OrderItem {
+	for i := range order.OrderItems {
+		if order.OrderItems[i].ID == itemID {
+			return &order.OrderItems[i]
+		}
+	}
+	return nil
+}
+
+// onlyShippableItems menandakan pesanan hanya berisi buku fisik, sehingga belum ada barang
+// yang diterima pembeli selama pesanan belum dikirim.
+func onlyShippableItems(order model.Order) bool {
+	for _, item := range order.OrderItems {
+		if !item.Book.IsShippable() {
+			return false
+		}
+	}
+	return len(order.OrderItems) > 0
+}
+
+// StartPayment membuat sesi pembayaran Snap untuk pesanan, atau mengembalikan sesi yang sudah dibuat
+// sebelumnya karena Midtrans menolak order_id yang sama dua kali. Sesi yang gagal disimpan tetap
+// dikembalikan ke pembeli.
+func (s *orderService) StartPayment(order *model.Order, user model.User) (*snap.Response, error) {
+	if order.Payment.SnapToken != "" {
+		return &snap.Response{Token: order.Payment.SnapToken, RedirectURL: order.Payment.SnapRedirectURL}, nil
+	}
+
+	snapRes, err := s.paymentGateway.CreateTransaction(order, user)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.paymentRepo.SetSnapSession(order.Payment.ID, snapRes.Token, snapRes.RedirectURL); err != nil {
+		fmt.Printf("Gagal menyimpan sesi Snap pesanan %s: %v\n", order.ID, err)
+	}
+	return snapRes, nil
+}
+
+// CreateRentalExtensionOrder membuat pesanan untuk memperpanjang sewa ebook yang masih aktif.
+// Masa sewa baru ditambahkan setelah pembayaran berhasil.
+func (s *orderService) CreateRentalExtensionOrder(userID, bookID uuid.UUID, days int) (*model.Order, error) {
+	var order model.Order
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		rental, err := s.findActiveRental(tx, userID, bookID)
+		if err != nil {
+			return err
+		}
+		price, ok := rental.Book.RentalPrice(days)
+		if !ok {
+			return ErrRentalNotAvailable
+		}
+
+		order, err = s.createSingleItemOrder(tx, userID, QuoteLine{
+			BookID:        bookID,
+			Quantity:      1,
+			UnitPrice:     price,
+			Kind:          model.OrderItemKindRentalExtension,
+			RentalDays:    days,
+			EntitlementID: &rental.ID,
+			Book:          rental.Book,
+		})
+		return err
+	})
+
+	return &order, err
+}
+
+// CreateRentalConversionOrder membuat pesanan untuk mengubah sewa menjadi pembelian.
+// Biaya sewa yang sudah dibayar dikreditkan ke harga buku; jika sisa tagihan nol,
+// konversi langsung diterapkan tanpa melalui pembayaran.
+func (s *orderService) CreateRentalConversionOrder(userID, bookID uuid.UUID) (*model.Order, error) {
+	var order model.Order
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		rental, err := s.findActiveRental(tx, userID, bookID)
+		if err != nil {
+			return err
+		}
+
+		price := rental.Book.Price - rental.RentalFeePaid
+		if price < 0 {
+			price = 0
+		}
+
+		order, err = s.createSingleItemOrder(tx, userID, QuoteLine{
+			BookID:        bookID,
+			Quantity:      1,
+			UnitPrice:     price,
+			Kind:          model.OrderItemKindRentalConversion,
+			EntitlementID: &rental.ID,
+			Book:          rental.Book,
+		})
+		if err != nil || order.TotalPrice > 0 {
+			return err
+		}
+
+		// Tidak ada yang perlu dibayar, tandai lunas dan terapkan konversi sekarang
+		order.Payment.Status = "success"
+		order.Payment.VerifiedAt = time.Now()
+		if _, err := repository.NewPaymentRepository(tx).Update(&order.Payment); err != nil {
+			return err
+		}
+		return s.completePaidOrder(tx, &order, "Tidak ada sisa tagihan")
+	})
+	if err == nil && order.Payment.Status == "success" {
+		s.issueInvoice(order.ID)
+	}
+
+	return &order, err
+}
+
+// uniqueIDs membuang ID duplikat.
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	result := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// findActiveRental mengambil sewa aktif milik pengguna untuk sebuah buku.
+func (s *orderService) findActiveRental(tx *gorm.DB, userID, bookID uuid.UUID) (model.Entitlement, error) {
+	entitlement, err := repository.NewEntitlementRepository(tx).FindActiveByUserAndBook(userID, bookID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return entitlement, ErrRentalNotFound
+		}
+		return entitlement, err
+	}
+	if !entitlement.IsRental() {
+		return entitlement, ErrRentalNotFound
+	}
+	return entitlement, nil
+}
+
+// createSingleItemOrder membuat pesanan pending beserta record pembayarannya untuk satu item
+// yang harganya sudah ditentukan (perpanjangan atau konversi sewa).
+func (s *orderService) createSingleItemOrder(tx *gorm.DB, userID uuid.UUID, line QuoteLine) (model.Order, error) {
+	quote, err := s.pricingService.QuoteLines(tx, userID, []QuoteLine{line}, QuoteOptions{})
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	createdOrder, err := repository.NewOrderRepository(tx).Create(&model.Order{
+		UserID:        userID,
+		Subtotal:      quote.Subtotal,
+		DiscountTotal: quote.DiscountTotal,
+		Taxes:         quote.Tax,
+		TaxInclusive:  quote.TaxInclusive,
+		ShippingCost:  quote.ShippingCost,
+		TotalPrice:    quote.GrandTotal,
+		Status:        model.OrderStatusPending,
+		OrderItems:    quote.OrderItems(),
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+	if err := s.statusService.RecordCreated(tx, createdOrder, OrderStatusChange{Actor: model.OrderActorCustomer, ActorID: &userID}); err != nil {
+		return model.Order{}, err
+	}
 
-	return &order, totalPrice, err
+	payment := &model.Payment{
+		OrderID:    createdOrder.ID,
+		Status:     "pending",
+		TotalPrice: createdOrder.TotalPrice,
+		Currency:   "IDR",
+		ExpiresAt:  time.Now().Add(24 * time.Hour),
+	}
+	if _, err := repository.NewPaymentRepository(tx).Create(payment); err != nil {
+		return model.Order{}, err
+	}
+	createdOrder.Payment = *payment
+	return *createdOrder, nil
 }
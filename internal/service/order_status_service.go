@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidOrderTransition = errors.New("order status cannot be changed this way")
+
+// OrderStatusChange menjelaskan siapa yang mengubah status pesanan dan catatannya. Untuk
+// pembatalan, Note juga disimpan sebagai alasan pembatalan.
+type OrderStatusChange struct {
+	Actor   string     // customer, admin, atau system
+	ActorID *uuid.UUID // Pengguna yang melakukan perubahan, kosong untuk system
+	Note    string
+}
+
+// OrderStatusService adalah satu-satunya jalan untuk mengubah status pesanan. Setiap perubahan
+// diperiksa terhadap daftar perpindahan yang diizinkan, menjalankan akibatnya (stok, saldo,
+// poin, akses ebook), dan dicatat di riwayat status.
+type OrderStatusService interface {
+	Transition(tx *gorm.DB, order *model.Order, to string, change OrderStatusChange) error
+	RecordCreated(tx *gorm.DB, order *model.Order, change OrderStatusChange) error
+}
+
+type orderStatusService struct {
+	libraryService     LibraryService
+	reservationService ReservationService
+	couponService      CouponService
+	flashSaleService   FlashSaleService
+	walletService      WalletService
+	giftCardService    GiftCardService
+	loyaltyService     LoyaltyService
+}
+
+func NewOrderStatusService(libraryService LibraryService, reservationService ReservationService, couponService CouponService, flashSaleService FlashSaleService, walletService WalletService, giftCardService GiftCardService, loyaltyService LoyaltyService) OrderStatusService {
+	return &orderStatusService{libraryService, reservationService, couponService, flashSaleService, walletService, giftCardService, loyaltyService}
+}
+
+// Transition mengubah status pesanan di dalam transaksi tx beserta akibatnya, lalu menyimpan
+// pesanan dan mencatat riwayatnya. order harus dimuat bersama OrderItems.Book dan Payment,
+// sebaiknya dengan FindByIDForUpdate agar perubahan status tidak berbarengan.
+func (s *orderStatusService) Transition(tx *gorm.DB, order *model.Order, to string, change OrderStatusChange) error {
+	from := order.Status
+	if !model.CanTransitionOrder(from, to, change.Actor) {
+		return fmt.Errorf("%w: %s cannot move an order from %s to %s", ErrInvalidOrderTransition, change.Actor, from, to)
+	}
+
+	now := time.Now()
+	switch to {
+	case model.OrderStatusProcessing:
+		if err := s.fulfil(tx, order); err != nil {
+			return err
+		}
+	case model.OrderStatusPartiallyShipped, model.OrderStatusShipped:
+		if order.ShippedAt == nil {
+			order.ShippedAt = &now
+		}
+	case model.OrderStatusCompleted:
+		if err := s.loyaltyService.AwardForOrder(tx, order); err != nil {
+			return err
+		}
+		order.CompletedAt = &now
+	case model.OrderStatusCancelled:
+		if err := s.cancel(tx, order, from); err != nil {
+			return err
+		}
+		order.CancelledBy = change.Actor
+		order.CancelReason = change.Note
+		order.CancelledAt = &now
+	}
+
+	order.Status = to
+	if _, err := repository.NewOrderRepository(tx).Update(order); err != nil {
+		return err
+	}
+	if err := s.record(tx, order.ID, from, to, change, now); err != nil {
+		return err
+	}
+
+	// Pesanan yang hanya berisi ebook dan gift card sudah terkirim seluruhnya begitu lunas,
+	// sehingga langsung diselesaikan tanpa menunggu pengiriman
+	if to == model.OrderStatusProcessing && !order.HasShippableItems() {
+		return s.Transition(tx, order, model.OrderStatusCompleted, OrderStatusChange{
+			Actor: model.OrderActorSystem,
+			Note:  "Pesanan digital selesai otomatis setelah pembayaran",
+		})
+	}
+	return nil
+}
+
+// RecordCreated mencatat status awal pesanan yang baru dibuat sebagai entri pertama riwayatnya.
+func (s *orderStatusService) RecordCreated(tx *gorm.DB, order *model.Order, change OrderStatusChange) error {
+	return s.record(tx, order.ID, "", order.Status, change, order.CreatedAt)
+}
+
+func (s *orderStatusService) record(tx *gorm.DB, orderID uuid.UUID, from, to string, change OrderStatusChange, at time.Time) error {
+	return repository.NewOrderRepository(tx).AddStatusHistory(&model.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      change.Actor,
+		ActorID:    change.ActorID,
+		Note:       change.Note,
+		ChangedAt:  at,
+	})
+}
+
+// fulfil memberi akses ebook, memotong stok yang ditahan, dan menerbitkan gift card untuk
+// pesanan yang baru lunas, baik lunas lewat payment gateway maupun langsung dengan saldo dompet.
+func (s *orderStatusService) fulfil(tx *gorm.DB, order *model.Order) error {
+	if err := s.libraryService.GrantForOrder(tx, order); err != nil {
+		return err
+	}
+	if err := s.reservationService.CommitOrder(tx, order.ID); err != nil {
+		return err
+	}
+	return s.giftCardService.IssueForOrder(tx, order)
+}
+
+// cancel melepas semua yang ditahan pesanan. Pesanan yang belum lunas melepas reservasi stok dan
+// saldo dompetnya; pesanan lunas yang belum dikirim mengembalikan stok yang sudah dipotong.
+// Pengembalian dana pesanan lunas ditangani terpisah oleh pemanggil.
+func (s *orderStatusService) cancel(tx *gorm.DB, order *model.Order, from string) error {
+	if order.Payment.Status != "success" {
+		if err := s.reservationService.ReleaseOrder(tx, order.ID); err != nil {
+			return err
+		}
+		if err := s.walletService.ReleaseForOrder(tx, order.ID); err != nil {
+			return err
+		}
+		if order.Payment.ID != uuid.Nil {
+			order.Payment.Status = "failed"
+			if _, err := repository.NewPaymentRepository(tx).Update(&order.Payment); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := s.giftCardService.RevokeForOrder(tx, order.ID); err != nil {
+			return err
+		}
+		if err := s.libraryService.RevokeForOrder(tx, order.ID); err != nil {
+			return err
+		}
+		if from == model.OrderStatusProcessing {
+			if err := s.reservationService.RestockOrder(tx, order.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.couponService.ReleaseForOrder(tx, order.ID); err != nil {
+		return err
+	}
+	if err := s.flashSaleService.ReleaseForOrder(tx, order.ID); err != nil {
+		return err
+	}
+	return s.loyaltyService.ReleaseForOrder(tx, order.ID)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"strings"
+	"time"
+
+	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+var ErrGatewayTransactionSettled = errors.New("payment has already been completed at the payment gateway")
+
+// PaymentGateway membungkus interaksi dengan penyedia pembayaran.
+type PaymentGateway interface {
+	CreateTransaction(order *model.Order, user model.User) (*snap.Response, error)
+	CancelTransaction(order *model.Order) error
+	RefundTransaction(order *model.Order, refundKey string, amount float64, reason string) error
+}
+
+// NewPaymentGateway memilih payment gateway dari PAYMENT_GATEWAY. Nilai "fake" memakai gateway
+// tiruan lokal sehingga checkout, pembatalan, dan refund bisa dicoba tanpa akun Midtrans.
+func NewPaymentGateway(cfg config.Config) PaymentGateway {
+	if strings.EqualFold(cfg.PaymentGateway, "fake") {
+		return NewFakeGateway(cfg.AppURL)
+	}
+	return NewMidtransGateway(cfg)
+}
+
+type midtransGateway struct {
+	serverKey string
+	env       midtrans.EnvironmentType
+}
+
+// NewMidtransGateway membuat PaymentGateway berbasis Midtrans Snap.
+func NewMidtransGateway(cfg config.Config) PaymentGateway {
+	env := midtrans.Sandbox
+	if cfg.MidtransIsProduction {
+		env = midtrans.Production
+	}
+	return &midtransGateway{serverKey: cfg.MidtransServerKey, env: env}
+}
+
+// CreateTransaction membuat sesi pembayaran Snap sebesar sisa tagihan pesanan setelah dipotong saldo dompet.
+func (g *midtransGateway) CreateTransaction(order *model.Order, user model.User) (*snap.Response, error) {
+	var s = snap.Client{}
+	s.New(g.serverKey, g.env)
+
+	snapReq := &snap.Request{
+		TransactionDetails: midtrans.TransactionDetails{
+			OrderID:  midtransOrderID(order),
+			GrossAmt: int64(order.AmountDue()),
+		},
+		CustomerDetail: &midtrans.CustomerDetails{
+			FName: user.Name,
+			Email: user.Email,
+			Phone: user.PhoneNumber,
+		},
+	}
+
+	// Samakan batas waktu pembayaran di Midtrans dengan batas waktu pembayaran pesanan
+	if !order.Payment.ExpiresAt.IsZero() {
+		minutes := int64(math.Ceil(time.Until(order.Payment.ExpiresAt).Minutes()))
+		if minutes > 0 {
+			snapReq.Expiry = &snap.ExpiryDetails{Unit: "minute", Duration: minutes}
+		}
+	}
+
+	snapRes, errSnap := s.CreateTransaction(snapReq)
+	if errSnap != nil {
+		return nil, errSnap
+	}
+	return snapRes, nil
+}
+
+// midtransOrderID memakai nomor pesanan sebagai order_id Midtrans. Pesanan lama yang belum
+// punya nomor pesanan tetap memakai format NGABACA-<uuid>-<unix>.
+func midtransOrderID(order *model.Order) string {
+	if order.OrderNumber != "" {
+		return order.OrderNumber
+	}
+	return fmt.Sprintf("NGABACA-%s-%d", order.ID.String(), time.Now().Unix())
+}
+
+// midtransReference adalah rujukan transaksi untuk Core API Midtrans. transaction_id dari notifikasi
+// dipakai jika ada karena order_id pesanan lama memuat waktu pembuatan transaksi.
+func midtransReference(order *model.Order) string {
+	if order.Payment.TransactionID != "" {
+		return order.Payment.TransactionID
+	}
+	return order.OrderNumber
+}
+
+func (g *midtransGateway) coreClient() coreapi.Client {
+	var c coreapi.Client
+	c.New(g.serverKey, g.env)
+	return c
+}
+
+// CancelTransaction membatalkan transaksi Midtrans yang belum dibayar agar tidak bisa dibayar lagi.
+// Transaksi yang belum pernah tercatat di Midtrans (pembeli belum memilih metode pembayaran)
+// dianggap sudah batal.
+func (g *midtransGateway) CancelTransaction(order *model.Order) error {
+	ref := midtransReference(order)
+	if ref == "" {
+		return nil
+	}
+	c := g.coreClient()
+	if _, err := c.CancelTransaction(ref); err != nil {
+		switch err.StatusCode {
+		case http.StatusNotFound:
+			return nil
+		case http.StatusPreconditionFailed:
+			return ErrGatewayTransactionSettled
+		}
+		return err
+	}
+	return nil
+}
+
+// RefundTransaction mengembalikan sebagian atau seluruh pembayaran lewat Midtrans. refundKey
+// membuat permintaan yang diulang tidak dikembalikan dua kali.
+func (g *midtransGateway) RefundTransaction(order *model.Order, refundKey string, amount float64, reason string) error {
+	c := g.coreClient()
+	req := &coreapi.RefundReq{RefundKey: refundKey, Amount: int64(amount), Reason: reason}
+	if _, err := c.RefundTransaction(midtransReference(order), req); err != nil {
+		return err
+	}
+	return nil
+}
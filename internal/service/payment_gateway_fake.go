@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"ngabaca/internal/model"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/midtrans/midtrans-go/snap"
+)
+
+// fakeGateway adalah payment gateway tiruan untuk pengembangan lokal. Tidak ada panggilan ke
+// layanan luar; pembayaran disimulasikan dengan membuka RedirectURL-nya, yang menjalankan
+// notifikasi pelunasan seperti webhook Midtrans.
+type fakeGateway struct {
+	appURL    string
+	mu        sync.Mutex
+	cancelled map[string]bool
+	refunded  map[string]float64
+}
+
+// NewFakeGateway membuat PaymentGateway tiruan yang menyimpan transaksinya di memori.
+func NewFakeGateway(appURL string) PaymentGateway {
+	return &fakeGateway{
+		appURL:    strings.TrimRight(appURL, "/"),
+		cancelled: map[string]bool{},
+		refunded:  map[string]float64{},
+	}
+}
+
+func (g *fakeGateway) CreateTransaction(order *model.Order, user model.User) (*snap.Response, error) {
+	orderID := midtransOrderID(order)
+	return &snap.Response{
+		Token:       "fake-" + uuid.NewString(),
+		RedirectURL: g.appURL + "/fake-payment/" + orderID,
+	}, nil
+}
+
+func (g *fakeGateway) CancelTransaction(order *model.Order) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if order.Payment.Status == "success" {
+		return ErrGatewayTransactionSettled
+	}
+	g.cancelled[order.ID.String()] = true
+	return nil
+}
+
+func (g *fakeGateway) RefundTransaction(order *model.Order, refundKey string, amount float64, reason string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := order.ID.String()
+	if order.Payment.Status != "success" {
+		return fmt.Errorf("fake gateway: order %s has not been paid", key)
+	}
+	if g.refunded[key]+amount > order.AmountDue() {
+		return fmt.Errorf("fake gateway: refund exceeds the paid amount of order %s", key)
+	}
+	g.refunded[key] += amount
+	fmt.Printf("[fake gateway] Refund %s sebesar Rp%.0f untuk pesanan %s: %s\n", refundKey, amount, key, reason)
+	return nil
+}
@@ -16,44 +16,84 @@ type PaymentService interface {
 }
 
 type paymentService struct {
-	db          *gorm.DB // Dibutuhkan untuk transaksi
-	orderRepo   repository.OrderRepository
-	paymentRepo repository.PaymentRepository
+	db             *gorm.DB // Dibutuhkan untuk transaksi
+	orderRepo      repository.OrderRepository
+	paymentRepo    repository.PaymentRepository
+	statusService  OrderStatusService
+	refundService  RefundService
+	invoiceService InvoiceService
 }
 
-func NewPaymentService(db *gorm.DB, orderRepo repository.OrderRepository, paymentRepo repository.PaymentRepository) PaymentService {
-	return &paymentService{db, orderRepo, paymentRepo}
+func NewPaymentService(db *gorm.DB, orderRepo repository.OrderRepository, paymentRepo repository.PaymentRepository, statusService OrderStatusService, refundService RefundService, invoiceService InvoiceService) PaymentService {
+	return &paymentService{db, orderRepo, paymentRepo, statusService, refundService, invoiceService}
 }
 
-func (s *paymentService) UpdatePaymentStatus(payload map[string]interface{}) error {
-	orderIDStr, ok := payload["order_id"].(string)
-	if !ok {
-		return fmt.Errorf("invalid notification payload: missing order_id")
+// paymentFailureReasons adalah alasan pembatalan pesanan untuk setiap status gagal dari Midtrans.
+var paymentFailureReasons = map[string]string{
+	"deny":   "Pembayaran ditolak",
+	"cancel": "Pembayaran dibatalkan",
+	"expire": "Batas waktu pembayaran habis",
+}
+
+// parseMidtransOrderID mengambil UUID pesanan dari order_id Midtrans lama dengan format NGABACA-<uuid>-<unix>.
+func parseMidtransOrderID(orderIDStr string) (uuid.UUID, error) {
+	trimmed := strings.TrimPrefix(orderIDStr, "NGABACA-")
+	if trimmed == orderIDStr || len(trimmed) < 36 {
+		return uuid.Nil, fmt.Errorf("invalid order_id format")
+	}
+	orderID, err := uuid.Parse(trimmed[:36])
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid order_id format: not a valid UUID")
 	}
+	return orderID, nil
+}
 
-	parts := strings.Split(orderIDStr, "-")
-	if len(parts) < 2 {
-		return fmt.Errorf("invalid order_id format")
+// findMidtransOrder mencari dan mengunci pesanan dari order_id Midtrans, sehingga notifikasi
+// pembayaran menunggu pembatalan yang sedang berjalan untuk pesanan yang sama. Pesanan baru memakai
+// nomor pesanan, sedangkan transaksi yang dibuat sebelum ada nomor pesanan masih memakai format
+// NGABACA-<uuid>-<unix>.
+func findMidtransOrder(orderRepo repository.OrderRepository, orderIDStr string) (model.Order, error) {
+	if !strings.HasPrefix(orderIDStr, "NGABACA-") {
+		order, err := orderRepo.FindByOrderNumberForUpdate(orderIDStr)
+		if err != nil {
+			return order, fmt.Errorf("order %s not found", orderIDStr)
+		}
+		return order, nil
 	}
-	orderID, err := uuid.Parse(parts[1])
+
+	orderID, err := parseMidtransOrderID(orderIDStr)
 	if err != nil {
-		return fmt.Errorf("invalid order_id format: not a valid UUID")
+		return model.Order{}, err
+	}
+	order, err := orderRepo.FindByIDForUpdate(orderID)
+	if err != nil {
+		return order, fmt.Errorf("order with id %s not found", orderID)
+	}
+	return order, nil
+}
+
+func (s *paymentService) UpdatePaymentStatus(payload map[string]interface{}) error {
+	orderIDStr, ok := payload["order_id"].(string)
+	if !ok {
+		return fmt.Errorf("invalid notification payload: missing order_id")
 	}
 
 	// Gunakan transaksi untuk memastikan update Order dan Payment konsisten
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	var paidOrderID uuid.UUID
+	var lateRefund *model.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// Buat instance repo dengan 'tx' agar semua operasi masuk dalam transaksi
 		txOrderRepo := repository.NewOrderRepository(tx)
 		txPaymentRepo := repository.NewPaymentRepository(tx)
 
-		order, err := txOrderRepo.FindByID(orderID)
+		order, err := findMidtransOrder(txOrderRepo, orderIDStr)
 		if err != nil {
-			return fmt.Errorf("order with id %s not found", orderID)
+			return err
 		}
 
 		payment, err := txPaymentRepo.FindByOrderID(order.ID)
 		if err != nil {
-			return fmt.Errorf("payment for order id %s not found", orderID)
+			return fmt.Errorf("payment for order %s not found", orderIDStr)
 		}
 
 		// Logika update status (dipindahkan dari handler)
@@ -66,25 +106,72 @@ func (s *paymentService) UpdatePaymentStatus(payload map[string]interface{}) err
 		payment.TransactionID = transactionID
 		payment.PaymentGatewayResponse = model.JSONB(payload)
 
-		if transactionStatus == "capture" || transactionStatus == "settlement" {
-			if fraudStatus == "accept" || fraudStatus == "challenge" {
+		if (transactionStatus == "capture" || transactionStatus == "settlement") && fraudStatus == "challenge" {
+			// Pembayaran kartu yang ditahan fraud detection Midtrans menunggu keputusan accept atau deny.
+			// Status pembayaran challenge juga membuatnya dilewati scheduler pembatalan pesanan kedaluwarsa.
+			if order.Status == model.OrderStatusPending {
+				change := OrderStatusChange{Actor: model.OrderActorSystem, Note: "Pembayaran ditahan untuk pemeriksaan fraud"}
+				if err := s.statusService.Transition(tx, &order, model.OrderStatusChallenge, change); err != nil {
+					return err
+				}
+				payment.Status = "challenge"
+			}
+		} else if transactionStatus == "capture" || transactionStatus == "settlement" {
+			if payment.Status != "success" {
 				payment.Status = "success"
-				order.Status = "diproses"
 				payment.VerifiedAt = time.Now()
+				order.Payment = payment
+
+				// Berikan akses ebook, potong stok yang ditahan, dan terbitkan gift card.
+				// Hanya sekali per pesanan karena notifikasi Midtrans bisa terkirim berulang.
+				if model.CanTransitionOrder(order.Status, model.OrderStatusProcessing, model.OrderActorSystem) {
+					change := OrderStatusChange{Actor: model.OrderActorSystem, Note: "Pembayaran diterima via " + paymentMethodLabel(paymentType)}
+					if err := s.statusService.Transition(tx, &order, model.OrderStatusProcessing, change); err != nil {
+						return err
+					}
+					paidOrderID = order.ID
+				} else if order.Status == model.OrderStatusCancelled {
+					// Pelunasan tiba setelah pesanan dibatalkan (misalnya oleh scheduler atau pembeli),
+					// jadi dana yang masuk lewat gateway langsung dikembalikan
+					if _, err := txPaymentRepo.Update(&payment); err != nil {
+						return err
+					}
+					reason := fmt.Sprintf("Pembayaran pesanan %s diterima setelah pesanan dibatalkan", orderIDStr)
+					lateRefund, err = s.refundService.RefundOrder(tx, &order, order.AmountDue(), model.RefundMethodOriginal, reason)
+					return err
+				}
 			}
 		} else if transactionStatus == "deny" || transactionStatus == "cancel" || transactionStatus == "expire" {
+			// Notifikasi gagal yang datang terlambat setelah pelunasan tidak mengubah pembayaran yang sudah berhasil
+			if payment.Status == "success" {
+				return nil
+			}
+			// Lepas stok, kupon, kuota flash sale, saldo dompet, dan poin loyalti yang ditahan, kecuali pesanan sudah dibatalkan sebelumnya (misal oleh scheduler)
+			if model.CanTransitionOrder(order.Status, model.OrderStatusCancelled, model.OrderActorSystem) {
+				change := OrderStatusChange{Actor: model.OrderActorSystem, Note: paymentFailureReasons[transactionStatus]}
+				if err := s.statusService.Transition(tx, &order, model.OrderStatusCancelled, change); err != nil {
+					return err
+				}
+			}
 			payment.Status = "failed"
-			order.Status = "batal"
 		}
 
 		// Simpan perubahan menggunakan repository
-		if _, err := txPaymentRepo.Update(&payment); err != nil {
-			return err
-		}
-		if _, err := txOrderRepo.Update(&order); err != nil {
-			return err
-		}
-
-		return nil
+		_, err = txPaymentRepo.Update(&payment)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	settleRefund(s.refundService, lateRefund)
+
+	// Faktur diterbitkan setelah pembayaran tersimpan. Kegagalannya tidak membatalkan pembayaran,
+	// faktur akan dibuat ulang saat pertama kali diunduh.
+	if paidOrderID != uuid.Nil {
+		if err := s.invoiceService.IssueForOrder(paidOrderID); err != nil {
+			fmt.Printf("Gagal menerbitkan faktur pesanan %s: %v\n", paidOrderID, err)
+		}
+	}
+	return nil
 }
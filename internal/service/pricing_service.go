@@ -0,0 +1,551 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponInvalid       = errors.New("coupon code is invalid")
+	ErrCouponInactive      = errors.New("coupon is not active")
+	ErrCouponExpired       = errors.New("coupon has expired")
+	ErrCouponExhausted     = errors.New("coupon usage limit has been reached")
+	ErrCouponUserLimit     = errors.New("you have reached the usage limit for this coupon")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to any item in this order")
+)
+
+// QuoteOptions berisi pilihan pembeli yang memengaruhi harga.
+type QuoteOptions struct {
+	CouponCode            string
+	RedeemPoints          int
+	DestinationPostalCode string
+	ShippingCourier       string
+	ShippingService       string
+	ShippingRates         []ShippingRate // Tarif yang sudah diambil sebelumnya; nil berarti diambil saat menghitung harga
+}
+
+// QuoteLine adalah satu baris item dalam rincian harga.
+type QuoteLine struct {
+	BookID          uuid.UUID      `json:"book_id"`
+	Title           string         `json:"title"`
+	Format          string         `json:"format"`
+	Kind            string         `json:"kind"`
+	RentalDays      int            `json:"rental_days,omitempty"`
+	EntitlementID   *uuid.UUID     `json:"entitlement_id,omitempty"`
+	FlashSaleItemID *uuid.UUID     `json:"flash_sale_item_id,omitempty"` // Diisi jika memakai harga flash sale
+	Quantity        int            `json:"quantity"`
+	UnitPrice       float64        `json:"unit_price"`
+	LineSubtotal    float64        `json:"line_subtotal"` // UnitPrice x Quantity
+	Discount        float64        `json:"discount"`
+	LineTotal       float64        `json:"line_total"`          // LineSubtotal - Discount
+	Discounts       []LineDiscount `json:"discounts,omitempty"` // Rincian sumber Discount
+	TaxClass        string         `json:"tax_class"`
+	TaxRate         float64        `json:"tax_rate"`
+	TaxBase         float64        `json:"tax_base"`
+	Tax             float64        `json:"tax"`
+
+	Book model.Book `json:"-"`
+}
+
+// LineDiscount adalah bagian potongan yang dibebankan ke sebuah baris beserta sumbernya.
+type LineDiscount struct {
+	PromotionID *uuid.UUID `json:"promotion_id,omitempty"`
+	CouponID    *uuid.UUID `json:"coupon_id,omitempty"`
+	Label       string     `json:"label"`
+	Amount      float64    `json:"amount"`
+}
+
+// QuoteDiscount adalah satu potongan harga yang diterapkan pada pesanan.
+type QuoteDiscount struct {
+	Code        string     `json:"code"`
+	PromotionID *uuid.UUID `json:"promotion_id,omitempty"`
+	Description string     `json:"description"`
+	Amount      float64    `json:"amount"`
+}
+
+// PriceQuote adalah rincian harga lengkap sebuah pesanan.
+type PriceQuote struct {
+	Lines           []QuoteLine     `json:"lines"`
+	Subtotal        float64         `json:"subtotal"`
+	Discounts       []QuoteDiscount `json:"discounts"`
+	DiscountTotal   float64         `json:"discount_total"`
+	ShippingCost    float64         `json:"shipping_cost"`
+	Tax             float64         `json:"tax"`
+	TaxInclusive    bool            `json:"tax_inclusive"` // Tax sudah termasuk di harga, tidak ditambahkan ke total
+	GrandTotal      float64         `json:"grand_total"`
+	NeedsShipping   bool            `json:"needs_shipping"`
+	ShippingOptions []ShippingRate  `json:"shipping_options,omitempty"` // Layanan yang tersedia untuk tujuan pengiriman
+	ShippingCourier string          `json:"shipping_courier,omitempty"`
+	ShippingService string          `json:"shipping_service,omitempty"`
+	CouponCode      string          `json:"coupon_code,omitempty"`
+	PointsRedeemed  int             `json:"points_redeemed,omitempty"`
+
+	Coupon         *model.Coupon `json:"-"`
+	CouponDiscount float64       `json:"-"`
+}
+
+// PricingService adalah satu-satunya tempat perhitungan harga pesanan, dipakai oleh
+// preview maupun checkout agar total yang ditampilkan sama dengan yang ditagihkan.
+type PricingService interface {
+	Quote(tx *gorm.DB, userID uuid.UUID, items []CreateOrderItemRequest, opts QuoteOptions) (*PriceQuote, error)
+	QuoteLines(tx *gorm.DB, userID uuid.UUID, lines []QuoteLine, opts QuoteOptions) (*PriceQuote, error)
+	ShippingRates(db *gorm.DB, items []CreateOrderItemRequest, destinationPostalCode string) ([]ShippingRate, error)
+}
+
+type pricingService struct {
+	cfg             config.Config
+	shippingService ShippingService
+	taxCalculator   TaxCalculator
+}
+
+func NewPricingService(cfg config.Config, shippingService ShippingService) PricingService {
+	return &pricingService{cfg, shippingService, NewTaxCalculator(cfg)}
+}
+
+// roundRupiah membulatkan nominal ke rupiah penuh karena Midtrans hanya menerima bilangan bulat.
+func roundRupiah(amount float64) float64 {
+	return math.Round(amount)
+}
+
+// Quote memvalidasi item pesanan, menentukan harga satuannya, lalu menghitung rincian harga.
+func (s *pricingService) Quote(tx *gorm.DB, userID uuid.UUID, items []CreateOrderItemRequest, opts QuoteOptions) (*PriceQuote, error) {
+	txBookRepo := repository.NewBookRepository(tx)
+	txEntitlementRepo := repository.NewEntitlementRepository(tx)
+	txFlashSaleRepo := repository.NewFlashSaleRepository(tx)
+
+	lines := make([]QuoteLine, 0, len(items))
+	for _, item := range items {
+		book, err := txBookRepo.FindByID(item.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("Book with ID %s not found", item.BookID)
+		}
+
+		price := book.Price
+		kind := model.OrderItemKindPurchase
+		if item.RentalDays > 0 {
+			if !book.IsDigital() {
+				return nil, fmt.Errorf("Book %s is not available for rent", book.Title)
+			}
+			rentalPrice, ok := book.RentalPrice(item.RentalDays)
+			if !ok {
+				return nil, fmt.Errorf("Ebook %s cannot be rented for %d days", book.Title, item.RentalDays)
+			}
+			price = rentalPrice
+			kind = model.OrderItemKindRental
+		}
+
+		// Harga flash sale hanya berlaku untuk pembelian, bukan sewa
+		var flashSaleItemID *uuid.UUID
+		if kind == model.OrderItemKindPurchase && book.FlashSale != nil {
+			offer := book.FlashSale
+			if offer.Remaining != nil && item.Quantity > *offer.Remaining {
+				return nil, fmt.Errorf("Only %d copies of %s are left at the flash sale price", *offer.Remaining, book.Title)
+			}
+			if offer.PerCustomerLimit > 0 {
+				bought, err := txFlashSaleRepo.SumUserQuantity(offer.FlashSaleItemID, userID)
+				if err != nil {
+					return nil, err
+				}
+				if bought+item.Quantity > offer.PerCustomerLimit {
+					return nil, fmt.Errorf("You can buy at most %d copies of %s during this flash sale", offer.PerCustomerLimit, book.Title)
+				}
+			}
+			price = offer.SalePrice
+			itemID := offer.FlashSaleItemID
+			flashSaleItemID = &itemID
+		}
+
+		if book.IsDigital() {
+			// Ebook tidak memakai stok, cukup pastikan dibeli sekali dan belum dimiliki
+			if item.Quantity != 1 {
+				return nil, fmt.Errorf("Ebook %s can only be purchased with quantity 1", book.Title)
+			}
+			if _, err := txEntitlementRepo.FindActiveByUserAndBook(userID, book.ID); err == nil {
+				return nil, fmt.Errorf("You already have access to the ebook %s", book.Title)
+			} else if err != gorm.ErrRecordNotFound {
+				return nil, err
+			}
+		}
+
+		lines = append(lines, QuoteLine{
+			BookID:          book.ID,
+			Quantity:        item.Quantity,
+			UnitPrice:       price,
+			Kind:            kind,
+			RentalDays:      item.RentalDays,
+			FlashSaleItemID: flashSaleItemID,
+			Book:            book,
+		})
+	}
+
+	return s.QuoteLines(tx, userID, lines, opts)
+}
+
+// QuoteLines menghitung rincian harga untuk baris yang harga satuannya sudah ditentukan.
+// Urutannya: subtotal, ongkos kirim, promosi, kupon, poin loyalti, lalu pajak atas nilai barang setelah potongan.
+func (s *pricingService) QuoteLines(tx *gorm.DB, userID uuid.UUID, lines []QuoteLine, opts QuoteOptions) (*PriceQuote, error) {
+	quote := &PriceQuote{Lines: lines, Discounts: []QuoteDiscount{}}
+
+	for i := range quote.Lines {
+		line := &quote.Lines[i]
+		line.Title = line.Book.Title
+		line.Format = line.Book.Format
+		line.LineSubtotal = roundRupiah(line.UnitPrice * float64(line.Quantity))
+		quote.Subtotal += line.LineSubtotal
+		if line.Book.IsShippable() {
+			quote.NeedsShipping = true
+		}
+	}
+
+	if err := s.applyShipping(quote, opts); err != nil {
+		return nil, err
+	}
+	if err := s.applyPromotions(tx, quote); err != nil {
+		return nil, err
+	}
+	if opts.CouponCode != "" {
+		if err := s.applyCoupon(tx, userID, quote, opts.CouponCode); err != nil {
+			return nil, err
+		}
+	}
+	if opts.RedeemPoints > 0 {
+		if err := s.applyPoints(tx, userID, quote, opts.RedeemPoints); err != nil {
+			return nil, err
+		}
+	}
+	s.applyTax(quote)
+	s.finalize(quote)
+	return quote, nil
+}
+
+// ShippingRates mengambil layanan pengiriman untuk item pesanan ke kode pos tujuan. Checkout
+// memanggilnya sebelum membuka transaksi karena penyedia tarif bisa berupa API kurir; hasilnya
+// diteruskan lewat QuoteOptions.ShippingRates. Hasilnya kosong jika tidak ada buku fisik.
+func (s *pricingService) ShippingRates(db *gorm.DB, items []CreateOrderItemRequest, destinationPostalCode string) ([]ShippingRate, error) {
+	bookRepo := repository.NewBookRepository(db)
+	lines := make([]QuoteLine, 0, len(items))
+	for _, item := range items {
+		book, err := bookRepo.FindByID(item.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("Book with ID %s not found", item.BookID)
+		}
+		lines = append(lines, QuoteLine{Quantity: item.Quantity, Book: book})
+	}
+
+	pkg, ok := s.shippingPackage(lines, destinationPostalCode)
+	if !ok {
+		return []ShippingRate{}, nil
+	}
+	return s.shippingService.Rates(pkg)
+}
+
+// shippingPackage menyusun paket dari buku fisik di baris pesanan. false berarti tidak ada yang
+// perlu dikirim atau tujuannya belum diisi.
+func (s *pricingService) shippingPackage(lines []QuoteLine, destinationPostalCode string) (ShippingPackage, bool) {
+	pkg := ShippingPackage{
+		OriginPostalCode:      s.shippingService.OriginPostalCode(),
+		DestinationPostalCode: destinationPostalCode,
+	}
+	shippable := false
+	for _, line := range lines {
+		if !line.Book.IsShippable() {
+			continue
+		}
+		shippable = true
+		weight := line.Book.WeightGrams
+		if weight <= 0 {
+			weight = s.cfg.ShippingDefaultWeightGrams
+		}
+		pkg.WeightGrams += weight * line.Quantity
+		pkg.VolumeCm3 += line.Book.LengthCm * line.Book.WidthCm * line.Book.HeightCm * float64(line.Quantity)
+	}
+	return pkg, shippable && destinationPostalCode != ""
+}
+
+// applyShipping mengambil layanan pengiriman yang tersedia ke kode pos tujuan lalu mengenakan
+// ongkos kirim layanan yang dipilih. Jika tarif sudah diambil sebelumnya, layanan yang dipilih
+// hanya dicocokkan dengan tarif tersebut. Tanpa tujuan atau pilihan layanan, ongkos kirim masih 0.
+func (s *pricingService) applyShipping(quote *PriceQuote, opts QuoteOptions) error {
+	pkg, ok := s.shippingPackage(quote.Lines, opts.DestinationPostalCode)
+	if !ok {
+		return nil
+	}
+
+	rates := opts.ShippingRates
+	if rates == nil {
+		var err error
+		if rates, err = s.shippingService.Rates(pkg); err != nil {
+			return err
+		}
+	}
+	quote.ShippingOptions = rates
+	if opts.ShippingCourier == "" && opts.ShippingService == "" {
+		return nil
+	}
+
+	rate, ok := findShippingRate(rates, opts.ShippingCourier, opts.ShippingService)
+	if !ok {
+		return ErrShippingServiceUnavailable
+	}
+	quote.ShippingCost = rate.Cost
+	quote.ShippingCourier = rate.Courier
+	quote.ShippingService = rate.Service
+	return nil
+}
+
+// applyPromotions menerapkan kombinasi promosi otomatis dengan potongan terbesar.
+func (s *pricingService) applyPromotions(tx *gorm.DB, quote *PriceQuote) error {
+	promotions, err := repository.NewPromotionRepository(tx).FindRunning(time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Ringkasan potongan digabung per promosi, urut sesuai kemunculan pertamanya
+	var summaries []QuoteDiscount
+	position := map[uuid.UUID]int{}
+	for _, app := range bestPromotions(quote.Lines, promotions) {
+		indexes := make([]int, 0, len(app.Units))
+		for i := range app.Units {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		weights := make([]float64, len(indexes))
+		for n, i := range indexes {
+			weights[n] = quote.Lines[i].UnitPrice * float64(app.Units[i])
+		}
+
+		promotionID := app.Promotion.ID
+		spreadDiscount(quote.Lines, indexes, weights, app.Discount, LineDiscount{PromotionID: &promotionID, Label: app.Promotion.Name})
+
+		if n, ok := position[promotionID]; ok {
+			summaries[n].Amount += app.Discount
+			continue
+		}
+		position[promotionID] = len(summaries)
+		summaries = append(summaries, QuoteDiscount{PromotionID: &promotionID, Description: app.Promotion.Name, Amount: app.Discount})
+	}
+
+	for _, d := range summaries {
+		quote.addDiscount(d)
+	}
+	return nil
+}
+
+// applyCoupon memvalidasi kode kupon lalu membagikan potongannya ke baris yang memenuhi syarat.
+// Kupon gratis ongkir memotong ongkos kirim dan tidak mengubah harga baris.
+func (s *pricingService) applyCoupon(tx *gorm.DB, userID uuid.UUID, quote *PriceQuote, code string) error {
+	couponRepo := repository.NewCouponRepository(tx)
+	coupon, err := couponRepo.FindByCode(code)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrCouponInvalid
+		}
+		return err
+	}
+
+	now := time.Now()
+	if !coupon.IsActive || (coupon.StartsAt != nil && now.Before(*coupon.StartsAt)) {
+		return ErrCouponInactive
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return ErrCouponExpired
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return ErrCouponExhausted
+	}
+	if coupon.PerUserLimit > 0 {
+		used, err := couponRepo.CountUserRedemptions(coupon.ID, userID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+
+	var eligible []int
+	eligibleSubtotal := 0.0
+	for i, line := range quote.Lines {
+		// Gift card setara uang tunai sehingga tidak bisa didiskon dengan kupon
+		if !line.Book.IsGiftCard() && coupon.AppliesTo(line.Book) {
+			eligible = append(eligible, i)
+			eligibleSubtotal += line.LineSubtotal - line.Discount
+		}
+	}
+	if len(eligible) == 0 || eligibleSubtotal <= 0 {
+		return ErrCouponNotApplicable
+	}
+	if coupon.MinSpend > 0 && eligibleSubtotal < coupon.MinSpend {
+		return fmt.Errorf("a minimum spend of Rp%.0f is required for this coupon", coupon.MinSpend)
+	}
+
+	var amount float64
+	switch coupon.Type {
+	case model.CouponTypePercentage:
+		amount = roundRupiah(eligibleSubtotal * coupon.Value / 100)
+		if coupon.MaxDiscount > 0 && amount > coupon.MaxDiscount {
+			amount = coupon.MaxDiscount
+		}
+	case model.CouponTypeFixed:
+		amount = math.Min(coupon.Value, eligibleSubtotal)
+	case model.CouponTypeFreeShipping:
+		if !quote.NeedsShipping || quote.ShippingCost == 0 {
+			return ErrCouponNotApplicable
+		}
+		amount = quote.ShippingCost
+	}
+
+	if coupon.Type != model.CouponTypeFreeShipping {
+		distributeDiscount(quote.Lines, eligible, amount, LineDiscount{CouponID: &coupon.ID, Label: coupon.Code})
+	}
+	quote.addDiscount(QuoteDiscount{Code: coupon.Code, Description: coupon.Description, Amount: amount})
+	quote.Coupon = &coupon
+	quote.CouponCode = coupon.Code
+	quote.CouponDiscount = amount
+	return nil
+}
+
+// applyPoints menukar poin loyalti menjadi potongan atas nilai barang yang tersisa setelah promosi dan kupon.
+// Gift card tidak bisa dibayar dengan poin.
+func (s *pricingService) applyPoints(tx *gorm.DB, userID uuid.UUID, quote *PriceQuote, points int) error {
+	if s.cfg.LoyaltyPointValue <= 0 {
+		return ErrPointsRedeemDisabled
+	}
+	account, err := repository.NewLoyaltyRepository(tx).FindOrCreateAccount(userID)
+	if err != nil {
+		return err
+	}
+	if account.Balance < points {
+		return ErrInsufficientPoints
+	}
+
+	var eligible []int
+	eligibleSubtotal := 0.0
+	for i, line := range quote.Lines {
+		if !line.Book.IsGiftCard() && line.LineSubtotal-line.Discount > 0 {
+			eligible = append(eligible, i)
+			eligibleSubtotal += line.LineSubtotal - line.Discount
+		}
+	}
+	maxPoints := int(math.Floor(eligibleSubtotal / s.cfg.LoyaltyPointValue))
+	if points > maxPoints {
+		return fmt.Errorf("you can redeem at most %d points for this order", maxPoints)
+	}
+
+	amount := roundRupiah(float64(points) * s.cfg.LoyaltyPointValue)
+	label := fmt.Sprintf("Loyalty points (%d)", points)
+	distributeDiscount(quote.Lines, eligible, amount, LineDiscount{Label: label})
+	quote.addDiscount(QuoteDiscount{Description: label, Amount: amount})
+	quote.PointsRedeemed = points
+	return nil
+}
+
+// distributeDiscount membagi potongan ke baris secara proporsional terhadap nilainya setelah potongan sebelumnya.
+func distributeDiscount(lines []QuoteLine, indexes []int, amount float64, source LineDiscount) {
+	weights := make([]float64, len(indexes))
+	for n, i := range indexes {
+		weights[n] = lines[i].LineSubtotal - lines[i].Discount
+	}
+	spreadDiscount(lines, indexes, weights, amount, source)
+}
+
+// spreadDiscount membagi potongan ke baris sesuai bobotnya dan mencatat sumbernya di setiap baris.
+// Sisa pembulatan dibebankan ke baris terakhir agar jumlahnya tepat.
+func spreadDiscount(lines []QuoteLine, indexes []int, weights []float64, amount float64, source LineDiscount) {
+	base := 0.0
+	for _, w := range weights {
+		base += w
+	}
+	if base <= 0 {
+		return
+	}
+
+	remaining := amount
+	for n, i := range indexes {
+		share := remaining
+		if n < len(indexes)-1 {
+			share = roundRupiah(amount * weights[n] / base)
+		}
+		lines[i].Discount += share
+		detail := source
+		detail.Amount = share
+		lines[i].Discounts = append(lines[i].Discounts, detail)
+		remaining -= share
+	}
+}
+
+// addDiscount mencatat satu potongan ke rincian harga.
+func (q *PriceQuote) addDiscount(d QuoteDiscount) {
+	q.Discounts = append(q.Discounts, d)
+	q.DiscountTotal += d.Amount
+}
+
+// applyTax menghitung PPN per baris dari nilai barang setelah potongan sesuai kelas pajak bukunya.
+func (s *pricingService) applyTax(quote *PriceQuote) {
+	quote.TaxInclusive = s.taxCalculator.Inclusive()
+	for i := range quote.Lines {
+		line := &quote.Lines[i]
+		tax := s.taxCalculator.Line(line.Book.EffectiveTaxClass(), line.LineSubtotal-line.Discount)
+		line.TaxClass = tax.Class
+		line.TaxRate = tax.Rate
+		line.TaxBase = tax.Base
+		line.Tax = tax.Amount
+		quote.Tax += tax.Amount
+	}
+}
+
+// finalize menghitung total per baris dan total akhir pesanan.
+func (s *pricingService) finalize(quote *PriceQuote) {
+	for i := range quote.Lines {
+		line := &quote.Lines[i]
+		line.LineTotal = line.LineSubtotal - line.Discount
+	}
+	quote.GrandTotal = quote.Subtotal - quote.DiscountTotal + quote.ShippingCost
+	if !quote.TaxInclusive {
+		quote.GrandTotal += quote.Tax
+	}
+	if quote.GrandTotal < 0 {
+		quote.GrandTotal = 0
+	}
+}
+
+// OrderItems mengubah baris rincian harga menjadi item pesanan.
+func (q *PriceQuote) OrderItems() []model.OrderItem {
+	items := make([]model.OrderItem, 0, len(q.Lines))
+	for _, line := range q.Lines {
+		discounts := make([]model.OrderItemDiscount, 0, len(line.Discounts))
+		for _, d := range line.Discounts {
+			discounts = append(discounts, model.OrderItemDiscount{
+				PromotionID: d.PromotionID,
+				CouponID:    d.CouponID,
+				Label:       d.Label,
+				Amount:      d.Amount,
+			})
+		}
+		items = append(items, model.OrderItem{
+			BookID:          line.BookID,
+			Quantity:        line.Quantity,
+			Price:           line.UnitPrice,
+			Discount:        line.Discount,
+			Kind:            line.Kind,
+			RentalDays:      line.RentalDays,
+			EntitlementID:   line.EntitlementID,
+			FlashSaleItemID: line.FlashSaleItemID,
+			Discounts:       discounts,
+			TaxClass:        line.TaxClass,
+			TaxRate:         line.TaxRate,
+			TaxBase:         line.TaxBase,
+			TaxAmount:       line.Tax,
+		})
+	}
+	return items
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"testing"
+)
+
+func newTestPricingService(provider ShippingProvider) *pricingService {
+	cfg := config.Config{ShippingDefaultWeightGrams: 500}
+	return NewPricingService(cfg, NewShippingServiceWithProviders("10110", provider)).(*pricingService)
+}
+
+func physicalQuote(quantity int) *PriceQuote {
+	return &PriceQuote{
+		NeedsShipping: true,
+		Lines: []QuoteLine{{
+			Quantity: quantity,
+			Book:     model.Book{Format: model.BookFormatPhysical, WeightGrams: 700},
+		}},
+	}
+}
+
+func TestApplyShippingSelectsChosenRate(t *testing.T) {
+	provider := NewFakeShippingProvider().(*fakeShippingProvider)
+	pricing := newTestPricingService(provider)
+	quote := physicalQuote(2)
+
+	err := pricing.applyShipping(quote, QuoteOptions{DestinationPostalCode: "40111", ShippingCourier: "FAKE", ShippingService: "exp"})
+	if err != nil {
+		t.Fatalf("applyShipping: %v", err)
+	}
+	if len(quote.ShippingOptions) != 2 {
+		t.Errorf("got %d shipping options, want 2", len(quote.ShippingOptions))
+	}
+	// 2 x 700 gram dibulatkan menjadi 2 kg dengan tarif EXP 20000/kg
+	if quote.ShippingCost != 40000 || quote.ShippingCourier != "fake" || quote.ShippingService != "EXP" {
+		t.Errorf("got %s %s %v, want fake EXP 40000", quote.ShippingCourier, quote.ShippingService, quote.ShippingCost)
+	}
+}
+
+func TestApplyShippingWithoutChoiceOnlyListsOptions(t *testing.T) {
+	pricing := newTestPricingService(NewFakeShippingProvider())
+	quote := physicalQuote(1)
+
+	if err := pricing.applyShipping(quote, QuoteOptions{DestinationPostalCode: "40111"}); err != nil {
+		t.Fatalf("applyShipping: %v", err)
+	}
+	if len(quote.ShippingOptions) != 2 || quote.ShippingCost != 0 {
+		t.Errorf("got %d options and cost %v, want 2 options and no cost", len(quote.ShippingOptions), quote.ShippingCost)
+	}
+}
+
+func TestApplyShippingUnavailableService(t *testing.T) {
+	pricing := newTestPricingService(NewFakeShippingProvider())
+	quote := physicalQuote(1)
+
+	err := pricing.applyShipping(quote, QuoteOptions{DestinationPostalCode: "40111", ShippingCourier: "fake", ShippingService: "SDS"})
+	if !errors.Is(err, ErrShippingServiceUnavailable) {
+		t.Errorf("err = %v, want %v", err, ErrShippingServiceUnavailable)
+	}
+}
+
+func TestApplyShippingUsesPrefetchedRates(t *testing.T) {
+	provider := NewFakeShippingProvider().(*fakeShippingProvider)
+	pricing := newTestPricingService(provider)
+	prefetched := []ShippingRate{{Courier: "jne", Service: "REG", Cost: 12000}}
+
+	quote := physicalQuote(1)
+	opts := QuoteOptions{DestinationPostalCode: "40111", ShippingCourier: "jne", ShippingService: "REG", ShippingRates: prefetched}
+	if err := pricing.applyShipping(quote, opts); err != nil {
+		t.Fatalf("applyShipping: %v", err)
+	}
+	if quote.ShippingCost != 12000 {
+		t.Errorf("shipping cost = %v, want 12000", quote.ShippingCost)
+	}
+
+	// Layanan yang tidak ada di tarif sebelumnya ditolak tanpa mengambil tarif ulang
+	quote = physicalQuote(1)
+	opts.ShippingCourier = "fake"
+	if err := pricing.applyShipping(quote, opts); !errors.Is(err, ErrShippingServiceUnavailable) {
+		t.Errorf("err = %v, want %v", err, ErrShippingServiceUnavailable)
+	}
+	if provider.Calls() != 0 {
+		t.Errorf("provider called %d times, want 0 with prefetched rates", provider.Calls())
+	}
+}
+
+func TestApplyShippingSkipsDigitalOrders(t *testing.T) {
+	provider := NewFakeShippingProvider().(*fakeShippingProvider)
+	pricing := newTestPricingService(provider)
+	quote := &PriceQuote{Lines: []QuoteLine{{Quantity: 1, Book: model.Book{Format: model.BookFormatEbook}}}}
+
+	if err := pricing.applyShipping(quote, QuoteOptions{DestinationPostalCode: "40111", ShippingCourier: "fake", ShippingService: "REG"}); err != nil {
+		t.Fatalf("applyShipping: %v", err)
+	}
+	if quote.ShippingCost != 0 || provider.Calls() != 0 {
+		t.Errorf("digital order got shipping cost %v after %d provider calls", quote.ShippingCost, provider.Calls())
+	}
+}
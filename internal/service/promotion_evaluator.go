@@ -0,0 +1,208 @@
+package service
+
+import (
+	"fmt"
+	"ngabaca/internal/model"
+	"sort"
+	"strings"
+)
+
+// maxExhaustiveUnits membatasi jumlah unit yang dicari kombinasi terbaiknya secara menyeluruh.
+// Di atas batas ini evaluator memakai pilihan terbaik bertahap agar waktu hitung tetap wajar.
+const maxExhaustiveUnits = 40
+
+// promotionApplication adalah satu kali penerapan promosi pada sejumlah unit buku.
+type promotionApplication struct {
+	Promotion model.Promotion
+	Units     map[int]int // indeks baris -> jumlah unit yang dipakai
+	Discount  float64
+}
+
+// promotionEvaluator mencari kombinasi promosi dengan potongan terbesar. Setiap unit buku
+// hanya boleh dipakai oleh satu penerapan promosi (promosi tidak bertumpuk).
+type promotionEvaluator struct {
+	lines      []QuoteLine
+	promotions []model.Promotion
+	eligible   [][]int // per promosi: indeks baris yang bisa ikut, urut dari harga tertinggi
+	memo       map[string]evaluation
+}
+
+type evaluation struct {
+	discount float64
+	apps     []promotionApplication
+}
+
+// bestPromotions mengembalikan penerapan promosi terbaik untuk baris-baris pesanan.
+// Baris sewa, gift card, dan baris yang sudah memakai harga flash sale tidak ikut promosi.
+func bestPromotions(lines []QuoteLine, promotions []model.Promotion) []promotionApplication {
+	if len(promotions) == 0 {
+		return nil
+	}
+
+	e := &promotionEvaluator{lines: lines, promotions: promotions, memo: map[string]evaluation{}}
+	remaining := make([]int, len(lines))
+	totalUnits := 0
+	for i, line := range lines {
+		if line.Kind == model.OrderItemKindPurchase && line.FlashSaleItemID == nil && !line.Book.IsGiftCard() {
+			remaining[i] = line.Quantity
+			totalUnits += line.Quantity
+		}
+	}
+	for _, p := range promotions {
+		e.eligible = append(e.eligible, e.eligibleLines(p))
+	}
+
+	if totalUnits > maxExhaustiveUnits {
+		return e.greedy(remaining)
+	}
+	return e.search(remaining).apps
+}
+
+func (e *promotionEvaluator) eligibleLines(p model.Promotion) []int {
+	var indexes []int
+	for i, line := range e.lines {
+		switch p.Type {
+		case model.PromotionTypeBundle:
+			for _, b := range p.Books {
+				if b.ID == line.BookID {
+					indexes = append(indexes, i)
+					break
+				}
+			}
+		default:
+			if p.AppliesTo(line.Book) {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return e.lines[indexes[a]].UnitPrice > e.lines[indexes[b]].UnitPrice
+	})
+	return indexes
+}
+
+// search mencoba setiap promosi secara rekursif dan mengingat hasil untuk sisa unit yang sama.
+func (e *promotionEvaluator) search(remaining []int) evaluation {
+	key := stateKey(remaining)
+	if cached, ok := e.memo[key]; ok {
+		return cached
+	}
+
+	best := evaluation{}
+	for i := range e.promotions {
+		app, ok := e.apply(i, remaining)
+		if !ok {
+			continue
+		}
+		next := consume(remaining, app.Units)
+		rest := e.search(next)
+		if total := app.Discount + rest.discount; total > best.discount {
+			best = evaluation{
+				discount: total,
+				apps:     append([]promotionApplication{app}, rest.apps...),
+			}
+		}
+	}
+
+	e.memo[key] = best
+	return best
+}
+
+// greedy berulang kali memilih penerapan dengan potongan terbesar sampai tidak ada lagi yang bisa diterapkan.
+func (e *promotionEvaluator) greedy(remaining []int) []promotionApplication {
+	var apps []promotionApplication
+	for {
+		var best *promotionApplication
+		for i := range e.promotions {
+			app, ok := e.apply(i, remaining)
+			if ok && (best == nil || app.Discount > best.Discount) {
+				best = &app
+			}
+		}
+		if best == nil {
+			return apps
+		}
+		apps = append(apps, *best)
+		remaining = consume(remaining, best.Units)
+	}
+}
+
+// apply menyusun satu penerapan promosi dari unit termahal yang masih tersisa.
+func (e *promotionEvaluator) apply(index int, remaining []int) (promotionApplication, bool) {
+	p := e.promotions[index]
+	app := promotionApplication{Promotion: p, Units: map[int]int{}}
+
+	switch p.Type {
+	case model.PromotionTypeBuyXCheapestFree, model.PromotionTypeMultiBuy:
+		if p.Quantity < 2 {
+			return app, false
+		}
+		needed := p.Quantity
+		sum, cheapest := 0.0, 0.0
+		for _, i := range e.eligible[index] {
+			take := min(remaining[i], needed)
+			if take == 0 {
+				continue
+			}
+			app.Units[i] = take
+			sum += e.lines[i].UnitPrice * float64(take)
+			cheapest = e.lines[i].UnitPrice
+			needed -= take
+			if needed == 0 {
+				break
+			}
+		}
+		if needed > 0 {
+			return app, false
+		}
+		if p.Type == model.PromotionTypeBuyXCheapestFree {
+			app.Discount = cheapest
+		} else {
+			app.Discount = sum - p.Price
+		}
+
+	case model.PromotionTypeBundle:
+		if len(p.Books) == 0 {
+			return app, false
+		}
+		sum := 0.0
+		for _, b := range p.Books {
+			found := false
+			for _, i := range e.eligible[index] {
+				if e.lines[i].BookID == b.ID && remaining[i]-app.Units[i] > 0 {
+					app.Units[i]++
+					sum += e.lines[i].UnitPrice
+					found = true
+					break
+				}
+			}
+			if !found {
+				return app, false
+			}
+		}
+		app.Discount = sum - p.Price
+
+	default:
+		return app, false
+	}
+
+	app.Discount = roundRupiah(app.Discount)
+	return app, app.Discount > 0
+}
+
+func consume(remaining []int, units map[int]int) []int {
+	next := make([]int, len(remaining))
+	copy(next, remaining)
+	for i, n := range units {
+		next[i] -= n
+	}
+	return next
+}
+
+func stateKey(remaining []int) string {
+	parts := make([]string, len(remaining))
+	for i, n := range remaining {
+		parts[i] = fmt.Sprint(n)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"errors"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProgressConflict = errors.New("a newer reading progress already exists")
+	ErrReadingNotFound  = errors.New("reading data not found")
+)
+
+// continueReadingLimit membatasi jumlah buku di daftar "lanjutkan membaca".
+const continueReadingLimit = 20
+
+type UpdateProgressRequest struct {
+	Position     string    `json:"position" validate:"required"`
+	PositionType string    `json:"position_type" validate:"required,oneof=page cfi"`
+	Percentage   float64   `json:"percentage" validate:"gte=0,lte=100"`
+	Device       string    `json:"device" validate:"omitempty,max=100"`
+	UpdatedAt    time.Time `json:"updated_at" validate:"required"` // Waktu perubahan di perangkat klien
+}
+
+type CreateBookmarkRequest struct {
+	Position     string `json:"position" validate:"required"`
+	PositionType string `json:"position_type" validate:"required,oneof=page cfi"`
+	Label        string `json:"label" validate:"omitempty,max=200"`
+}
+
+type CreateHighlightRequest struct {
+	StartPosition string `json:"start_position" validate:"required"`
+	EndPosition   string `json:"end_position" validate:"required"`
+	PositionType  string `json:"position_type" validate:"required,oneof=page cfi"`
+	Text          string `json:"text" validate:"omitempty,max=5000"`
+	Note          string `json:"note" validate:"omitempty,max=2000"`
+	Color         string `json:"color" validate:"omitempty,max=20"`
+}
+
+type UpdateHighlightRequest struct {
+	Note  *string `json:"note" validate:"omitempty,max=2000"`
+	Color *string `json:"color" validate:"omitempty,max=20"`
+}
+
+// ContinueReadingItem adalah satu buku di daftar "lanjutkan membaca".
+type ContinueReadingItem struct {
+	BookID         uuid.UUID              `json:"book_id"`
+	Title          string                 `json:"title"`
+	Slug           string                 `json:"slug"`
+	Author         string                 `json:"author"`
+	CoverImageURL  string                 `json:"cover_image_url"`
+	LastActivityAt time.Time              `json:"last_activity_at"`
+	Progress       *model.ReadingProgress `json:"progress"`
+}
+
+// ReadingService mengelola sinkronisasi progres baca, bookmark, dan highlight ebook.
+type ReadingService interface {
+	GetProgress(userID, bookID uuid.UUID) (model.ReadingProgress, error)
+	UpdateProgress(userID, bookID uuid.UUID, req *UpdateProgressRequest) (model.ReadingProgress, error)
+	ContinueReading(userID uuid.UUID) ([]ContinueReadingItem, error)
+
+	GetBookmarks(userID, bookID uuid.UUID) ([]model.Bookmark, error)
+	CreateBookmark(userID, bookID uuid.UUID, req *CreateBookmarkRequest) (model.Bookmark, error)
+	DeleteBookmark(userID, bookID, bookmarkID uuid.UUID) error
+
+	GetHighlights(userID, bookID uuid.UUID) ([]model.Highlight, error)
+	CreateHighlight(userID, bookID uuid.UUID, req *CreateHighlightRequest) (model.Highlight, error)
+	UpdateHighlight(userID, bookID, highlightID uuid.UUID, req *UpdateHighlightRequest) (model.Highlight, error)
+	DeleteHighlight(userID, bookID, highlightID uuid.UUID) error
+}
+
+type readingService struct {
+	readingRepo     repository.ReadingRepository
+	entitlementRepo repository.EntitlementRepository
+}
+
+func NewReadingService(readingRepo repository.ReadingRepository, entitlementRepo repository.EntitlementRepository) ReadingService {
+	return &readingService{readingRepo, entitlementRepo}
+}
+
+// ensureEntitled memastikan pengguna masih memiliki akses aktif ke ebook.
+func (s *readingService) ensureEntitled(userID, bookID uuid.UUID) error {
+	if _, err := s.entitlementRepo.FindActiveByUserAndBook(userID, bookID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrNotEntitled
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *readingService) GetProgress(userID, bookID uuid.UUID) (model.ReadingProgress, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return model.ReadingProgress{}, err
+	}
+	progress, err := s.readingRepo.FindProgress(userID, bookID)
+	if err == gorm.ErrRecordNotFound {
+		return progress, ErrReadingNotFound
+	}
+	return progress, err
+}
+
+// UpdateProgress menyimpan progres baca. Jika server sudah punya progres yang lebih baru,
+// progres tersebut dikembalikan bersama ErrProgressConflict.
+func (s *readingService) UpdateProgress(userID, bookID uuid.UUID, req *UpdateProgressRequest) (model.ReadingProgress, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return model.ReadingProgress{}, err
+	}
+
+	progress := model.ReadingProgress{
+		UserID:          userID,
+		BookID:          bookID,
+		Position:        req.Position,
+		PositionType:    req.PositionType,
+		Percentage:      req.Percentage,
+		Device:          req.Device,
+		ClientUpdatedAt: req.UpdatedAt.UTC(),
+	}
+	applied, err := s.readingRepo.UpsertProgressIfNewer(&progress)
+	if err != nil {
+		return model.ReadingProgress{}, err
+	}
+
+	current, err := s.readingRepo.FindProgress(userID, bookID)
+	if err != nil {
+		return model.ReadingProgress{}, err
+	}
+	if !applied {
+		return current, ErrProgressConflict
+	}
+	return current, nil
+}
+
+func (s *readingService) ContinueReading(userID uuid.UUID) ([]ContinueReadingItem, error) {
+	activities, err := s.readingRepo.RecentActivity(userID, continueReadingLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	bookIDs := make([]uuid.UUID, 0, len(activities))
+	for _, a := range activities {
+		bookIDs = append(bookIDs, a.BookID)
+	}
+	progressByBook := map[uuid.UUID]model.ReadingProgress{}
+	if len(bookIDs) > 0 {
+		progresses, err := s.readingRepo.FindProgressByBookIDs(userID, bookIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range progresses {
+			progressByBook[p.BookID] = p
+		}
+	}
+
+	items := make([]ContinueReadingItem, 0, len(activities))
+	for _, a := range activities {
+		// Lewati buku yang aksesnya sudah tidak aktif
+		entitlement, err := s.entitlementRepo.FindActiveByUserAndBook(userID, a.BookID)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				continue
+			}
+			return nil, err
+		}
+
+		item := ContinueReadingItem{
+			BookID:         a.BookID,
+			Title:          entitlement.Book.Title,
+			Slug:           entitlement.Book.Slug,
+			Author:         entitlement.Book.Author,
+			CoverImageURL:  entitlement.Book.CoverImageURL,
+			LastActivityAt: a.LastActivityAt,
+		}
+		if p, ok := progressByBook[a.BookID]; ok {
+			item.Progress = &p
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *readingService) GetBookmarks(userID, bookID uuid.UUID) ([]model.Bookmark, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return nil, err
+	}
+	return s.readingRepo.FindBookmarks(userID, bookID)
+}
+
+func (s *readingService) CreateBookmark(userID, bookID uuid.UUID, req *CreateBookmarkRequest) (model.Bookmark, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return model.Bookmark{}, err
+	}
+	bookmark := model.Bookmark{
+		UserID:       userID,
+		BookID:       bookID,
+		Position:     req.Position,
+		PositionType: req.PositionType,
+		Label:        req.Label,
+	}
+	err := s.readingRepo.CreateBookmark(&bookmark)
+	return bookmark, err
+}
+
+func (s *readingService) DeleteBookmark(userID, bookID, bookmarkID uuid.UUID) error {
+	deleted, err := s.readingRepo.DeleteBookmark(userID, bookID, bookmarkID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrReadingNotFound
+	}
+	return nil
+}
+
+func (s *readingService) GetHighlights(userID, bookID uuid.UUID) ([]model.Highlight, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return nil, err
+	}
+	return s.readingRepo.FindHighlights(userID, bookID)
+}
+
+func (s *readingService) CreateHighlight(userID, bookID uuid.UUID, req *CreateHighlightRequest) (model.Highlight, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return model.Highlight{}, err
+	}
+	highlight := model.Highlight{
+		UserID:        userID,
+		BookID:        bookID,
+		StartPosition: req.StartPosition,
+		EndPosition:   req.EndPosition,
+		PositionType:  req.PositionType,
+		Text:          req.Text,
+		Note:          req.Note,
+		Color:         req.Color,
+	}
+	err := s.readingRepo.CreateHighlight(&highlight)
+	return highlight, err
+}
+
+func (s *readingService) UpdateHighlight(userID, bookID, highlightID uuid.UUID, req *UpdateHighlightRequest) (model.Highlight, error) {
+	if err := s.ensureEntitled(userID, bookID); err != nil {
+		return model.Highlight{}, err
+	}
+	highlight, err := s.readingRepo.FindHighlight(userID, bookID, highlightID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return highlight, ErrReadingNotFound
+		}
+		return highlight, err
+	}
+	if req.Note != nil {
+		highlight.Note = *req.Note
+	}
+	if req.Color != nil {
+		highlight.Color = *req.Color
+	}
+	err = s.readingRepo.UpdateHighlight(&highlight)
+	return highlight, err
+}
+
+func (s *readingService) DeleteHighlight(userID, bookID, highlightID uuid.UUID) error {
+	deleted, err := s.readingRepo.DeleteHighlight(userID, bookID, highlightID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrReadingNotFound
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefundExceedsPaid   = errors.New("refund amount exceeds what is left to refund for this order")
+	ErrInvalidRefundAmount = errors.New("refund amount must be greater than zero")
+	ErrInvalidRefundMethod = errors.New("refund method must be original_payment or store_credit")
+	ErrOrderNotPaid        = errors.New("order has not been paid")
+)
+
+// gatewayRefundableMethods adalah payment_type Midtrans yang mendukung refund lewat API.
+// Pembayaran lain (misal virtual account) dikembalikan sebagai saldo dompet.
+var gatewayRefundableMethods = map[string]bool{
+	"credit_card": true,
+	"gopay":       true,
+	"shopeepay":   true,
+	"qris":        true,
+	"akulaku":     true,
+	"kredivo":     true,
+}
+
+// maxRefundAttempts adalah batas percobaan refund ke payment gateway sebelum refund ditandai gagal.
+const maxRefundAttempts = 5
+
+// RefundService mengembalikan dana pesanan yang sudah dibayar, ke metode pembayaran asal atau ke saldo dompet.
+type RefundService interface {
+	RefundOrder(tx *gorm.DB, order *model.Order, amount float64, method, reason string) (*model.Refund, error)
+	SettleRefund(refundID uuid.UUID) error
+	SettlePending() (int, error)
+	GetRefunds(orderID uuid.UUID) ([]model.Refund, error)
+}
+
+type refundService struct {
+	db             *gorm.DB
+	paymentGateway PaymentGateway
+	walletService  WalletService
+}
+
+func NewRefundService(db *gorm.DB, paymentGateway PaymentGateway, walletService WalletService) RefundService {
+	return &refundService{db, paymentGateway, walletService}
+}
+
+func (s *refundService) GetRefunds(orderID uuid.UUID) ([]model.Refund, error) {
+	return repository.NewRefundRepository(s.db).FindByOrderID(orderID)
+}
+
+// RefundOrder mencatat pengembalian dana di dalam transaksi tx. Untuk metode pembayaran asal, bagian
+// yang dibayar lewat payment gateway dicatat sebagai refund pending, sisanya (bagian saldo dompet atau
+// metode yang tidak mendukung refund) langsung dikreditkan ke dompet. Pemanggil harus memanggil
+// SettleRefund setelah transaksi di-commit; gateway tidak pernah dipanggil dari dalam tx agar dana
+// tidak terkirim untuk transaksi yang akhirnya dibatalkan. order harus dimuat bersama Payment.
+func (s *refundService) RefundOrder(tx *gorm.DB, order *model.Order, amount float64, method, reason string) (*model.Refund, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidRefundAmount
+	}
+	if method != model.RefundMethodOriginal && method != model.RefundMethodStoreCredit {
+		return nil, ErrInvalidRefundMethod
+	}
+	if order.Payment.Status != "success" {
+		return nil, ErrOrderNotPaid
+	}
+
+	txRefundRepo := repository.NewRefundRepository(tx)
+	refunded, err := txRefundRepo.SumByOrderID(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > roundRupiah(order.TotalPrice-refunded.Amount) {
+		return nil, ErrRefundExceedsPaid
+	}
+
+	refund := &model.Refund{
+		Basemodel: model.Basemodel{ID: uuid.New()},
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		Method:    method,
+		Amount:    amount,
+		Reason:    reason,
+	}
+	if method == model.RefundMethodOriginal && gatewayRefundableMethods[order.Payment.PaymentMethod] {
+		refund.GatewayAmount = min(amount, max(order.AmountDue()-refunded.GatewayAmount, 0))
+	}
+	refund.WalletAmount = roundRupiah(amount - refund.GatewayAmount)
+	refund.Status = model.RefundStatusCompleted
+	if refund.GatewayAmount > 0 {
+		refund.Status = model.RefundStatusPending
+	} else {
+		now := time.Now()
+		refund.CompletedAt = &now
+	}
+
+	if refund.WalletAmount > 0 {
+		entry := WalletEntry{Source: model.WalletSourceRefund, OrderID: &order.ID, Note: reason}
+		if _, err := s.walletService.Credit(tx, order.UserID, refund.WalletAmount, entry); err != nil {
+			return nil, err
+		}
+	}
+	if err := txRefundRepo.Create(refund); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// SettleRefund mengirim bagian payment gateway dari refund pending. ID refund dipakai sebagai refund
+// key, jadi percobaan ulang tidak mengembalikan dana dua kali. Refund yang terus ditolak gateway
+// ditandai gagal setelah maxRefundAttempts percobaan.
+func (s *refundService) SettleRefund(refundID uuid.UUID) error {
+	refundRepo := repository.NewRefundRepository(s.db)
+	refund, err := refundRepo.FindByID(refundID)
+	if err != nil || refund.Status != model.RefundStatusPending {
+		return err
+	}
+	order, err := repository.NewOrderRepository(s.db).FindByID(refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	refund.Attempts++
+	gatewayErr := s.paymentGateway.RefundTransaction(&order, refund.ID.String(), refund.GatewayAmount, refund.Reason)
+	if gatewayErr == nil {
+		now := time.Now()
+		refund.Status = model.RefundStatusCompleted
+		refund.CompletedAt = &now
+		refund.GatewayError = ""
+	} else {
+		refund.GatewayError = gatewayErr.Error()
+		if refund.Attempts >= maxRefundAttempts {
+			refund.Status = model.RefundStatusFailed
+		}
+	}
+	if err := refundRepo.Update(&refund); err != nil {
+		return err
+	}
+	return gatewayErr
+}
+
+// SettlePending mencoba ulang refund yang masih pending, misalnya karena server berhenti setelah
+// refund tercatat atau gateway sempat menolak. Hasilnya adalah jumlah refund yang berhasil dikirim.
+func (s *refundService) SettlePending() (int, error) {
+	refunds, err := repository.NewRefundRepository(s.db).FindPending(time.Now().Add(-5*time.Minute), 100)
+	if err != nil {
+		return 0, err
+	}
+	settled := 0
+	for _, refund := range refunds {
+		if err := s.SettleRefund(refund.ID); err != nil {
+			fmt.Printf("Refund %s untuk pesanan %s gagal dikirim ke payment gateway: %v\n", refund.ID, refund.OrderID, err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+// settleRefund mengirim refund yang baru di-commit ke payment gateway. Kegagalan hanya dicatat karena
+// perubahan pesanan sudah tersimpan; refund tetap pending dan dicoba ulang oleh scheduler.
+func settleRefund(refundService RefundService, refund *model.Refund) {
+	if refund == nil || refund.Status != model.RefundStatusPending {
+		return
+	}
+	if err := refundService.SettleRefund(refund.ID); err != nil {
+		fmt.Printf("Refund %s untuk pesanan %s gagal dikirim ke payment gateway, akan dicoba ulang: %v\n", refund.ID, refund.OrderID, err)
+	}
+}
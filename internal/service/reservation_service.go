@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var ErrNothingToReserve = errors.New("cart has no physical books to reserve")
+
+// InsufficientStockError menandakan stok tersedia tidak cukup untuk direservasi.
+type InsufficientStockError struct {
+	Title     string
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("Insufficient stock for book %s (%d available)", e.Title, e.Available)
+}
+
+// ReservationService menahan stok buku fisik selama pengguna berbelanja atau menyelesaikan pembayaran.
+// Stok baru benar-benar dipotong ketika pembayaran berhasil.
+type ReservationService interface {
+	ReserveCart(userID uuid.UUID) ([]model.StockReservation, error)
+	ReserveForOrder(tx *gorm.DB, userID, orderID, bookID uuid.UUID, quantity int, expiresAt time.Time) error
+	CommitOrder(tx *gorm.DB, orderID uuid.UUID) error
+	ReleaseOrder(tx *gorm.DB, orderID uuid.UUID) error
+	RestockOrder(tx *gorm.DB, orderID uuid.UUID) error
+	RestockItem(tx *gorm.DB, orderID, bookID uuid.UUID, quantity int) error
+	CheckoutExpiry() time.Time
+}
+
+type reservationService struct {
+	db  *gorm.DB
+	cfg config.Config
+}
+
+func NewReservationService(db *gorm.DB, cfg config.Config) ReservationService {
+	return &reservationService{db, cfg}
+}
+
+// CheckoutExpiry mengembalikan batas waktu reservasi untuk pesanan yang baru dibuat.
+func (s *reservationService) CheckoutExpiry() time.Time {
+	return time.Now().Add(time.Duration(s.cfg.CheckoutReservationTTLMinutes) * time.Minute)
+}
+
+// lockAvailable mengunci baris buku lalu menghitung stok yang masih bisa direservasi pengguna.
+func lockAvailable(tx *gorm.DB, userID, bookID uuid.UUID) (model.Book, int, error) {
+	book, err := repository.NewBookRepository(tx).FindByIDForUpdate(bookID)
+	if err != nil {
+		return book, 0, err
+	}
+	reserved, err := repository.NewReservationRepository(tx).ReservedQuantity(bookID, userID)
+	if err != nil {
+		return book, 0, err
+	}
+	return book, book.Stock - reserved, nil
+}
+
+// ReserveCart menahan stok untuk semua buku fisik di keranjang pengguna. Reservasi keranjang
+// sebelumnya dilepas dan diganti dengan yang baru.
+func (s *reservationService) ReserveCart(userID uuid.UUID) ([]model.StockReservation, error) {
+	var reservations []model.StockReservation
+	expiresAt := time.Now().Add(time.Duration(s.cfg.CartReservationTTLMinutes) * time.Minute)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txReservationRepo := repository.NewReservationRepository(tx)
+		if err := txReservationRepo.ReleaseCartReservations(userID, nil); err != nil {
+			return err
+		}
+
+		items, err := repository.NewCartRepository(tx).FindItems(userID, nil)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			book, available, err := lockAvailable(tx, userID, item.BookID)
+			if err != nil {
+				return err
+			}
+			if !book.IsShippable() {
+				continue
+			}
+			if available < item.Quantity {
+				return &InsufficientStockError{Title: book.Title, Available: max(available, 0)}
+			}
+
+			reservation := model.StockReservation{
+				BookID:    book.ID,
+				UserID:    userID,
+				Quantity:  item.Quantity,
+				ExpiresAt: expiresAt,
+			}
+			if err := txReservationRepo.Create(&reservation); err != nil {
+				return err
+			}
+			reservations = append(reservations, reservation)
+		}
+		if len(reservations) == 0 {
+			return ErrNothingToReserve
+		}
+		return nil
+	})
+	return reservations, err
+}
+
+// ReserveForOrder menahan stok sebuah buku untuk pesanan. Reservasi keranjang pengguna untuk
+// buku yang sama ikut dihitung lalu dilepas, sehingga stok yang sudah ditahan berpindah ke pesanan.
+func (s *reservationService) ReserveForOrder(tx *gorm.DB, userID, orderID, bookID uuid.UUID, quantity int, expiresAt time.Time) error {
+	book, available, err := lockAvailable(tx, userID, bookID)
+	if err != nil {
+		return err
+	}
+	if available < quantity {
+		return &InsufficientStockError{Title: book.Title, Available: max(available, 0)}
+	}
+
+	txReservationRepo := repository.NewReservationRepository(tx)
+	if err := txReservationRepo.ReleaseCartReservations(userID, []uuid.UUID{book.ID}); err != nil {
+		return err
+	}
+	return txReservationRepo.Create(&model.StockReservation{
+		BookID:    book.ID,
+		UserID:    userID,
+		OrderID:   &orderID,
+		Quantity:  quantity,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// CommitOrder memotong stok untuk reservasi pesanan yang pembayarannya berhasil.
+// Reservasi yang sempat kedaluwarsa tetap dipotong karena pembeli sudah membayar. Jika stok sudah
+// tidak cukup, hanya sisa stok yang dipotong dan kekurangannya dicatat pada reservasi agar
+// pengembalian stok saat pembatalan tidak melebihi yang benar-benar dipotong.
+func (s *reservationService) CommitOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txReservationRepo := repository.NewReservationRepository(tx)
+	reservations, err := txReservationRepo.FindByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reservations {
+		if r.Status != model.ReservationStatusActive && r.Status != model.ReservationStatusExpired {
+			continue
+		}
+		result := tx.Model(&model.Book{}).Where("id = ? AND stock >= ?", r.BookID, r.Quantity).
+			Update("stock", gorm.Expr("stock - ?", r.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			book, err := repository.NewBookRepository(tx).FindByIDForUpdate(r.BookID)
+			if err != nil {
+				return err
+			}
+			deducted := min(max(book.Stock, 0), r.Quantity)
+			fmt.Printf("Peringatan: stok buku %s kurang %d untuk pesanan %s yang sudah dibayar\n", r.BookID, r.Quantity-deducted, orderID)
+			if err := tx.Model(&model.Book{}).Where("id = ?", r.BookID).Update("stock", gorm.Expr("stock - ?", deducted)).Error; err != nil {
+				return err
+			}
+			if err := txReservationRepo.SetShortfall(r.ID, r.Quantity-deducted); err != nil {
+				return err
+			}
+		}
+	}
+
+	return txReservationRepo.UpdateOrderStatus(orderID,
+		[]string{model.ReservationStatusActive, model.ReservationStatusExpired}, model.ReservationStatusCommitted)
+}
+
+// ReleaseOrder melepas stok yang ditahan pesanan yang batal. Pesanan lama yang dibuat sebelum
+// ada reservasi sudah memotong stok saat checkout, sehingga stoknya dikembalikan.
+func (s *reservationService) ReleaseOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txReservationRepo := repository.NewReservationRepository(tx)
+	reservations, err := txReservationRepo.FindByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if len(reservations) > 0 {
+		return txReservationRepo.UpdateOrderStatus(orderID,
+			[]string{model.ReservationStatusActive, model.ReservationStatusExpired}, model.ReservationStatusReleased)
+	}
+	return restockOrderItems(tx, orderID)
+}
+
+// RestockOrder mengembalikan stok pesanan lunas yang dibatalkan sebelum dikirim. Reservasi yang
+// stoknya sudah dipotong ditandai dilepas, dikurangi kekurangan yang tidak pernah dipotong;
+// pesanan lama tanpa reservasi dikembalikan per item.
+func (s *reservationService) RestockOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txReservationRepo := repository.NewReservationRepository(tx)
+	reservations, err := txReservationRepo.FindByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+	if len(reservations) == 0 {
+		return restockOrderItems(tx, orderID)
+	}
+
+	for _, r := range reservations {
+		if r.Status != model.ReservationStatusCommitted {
+			continue
+		}
+		err := tx.Model(&model.Book{}).Where("id = ?", r.BookID).
+			Update("stock", gorm.Expr("stock + ?", r.Quantity-r.Shortfall)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return txReservationRepo.UpdateOrderStatus(orderID, []string{model.ReservationStatusCommitted}, model.ReservationStatusReleased)
+}
+
+// RestockItem mengembalikan stok sebagian item pesanan lunas yang dibatalkan sebelum dikirim.
+// Reservasi yang sudah dipotong dikurangi kuantitasnya agar pembatalan seluruh pesanan setelahnya
+// tidak mengembalikan stok yang sama dua kali. Kuantitas yang dibatalkan lebih dulu menutup
+// kekurangan reservasi, karena unit tersebut memang tidak pernah dipotong dari stok.
+func (s *reservationService) RestockItem(tx *gorm.DB, orderID, bookID uuid.UUID, quantity int) error {
+	txReservationRepo := repository.NewReservationRepository(tx)
+	reservations, err := txReservationRepo.FindByOrderID(orderID)
+	if err != nil {
+		return err
+	}
+
+	remaining, restock := quantity, quantity
+	for _, r := range reservations {
+		if remaining == 0 {
+			break
+		}
+		if r.BookID != bookID || r.Status != model.ReservationStatusCommitted {
+			continue
+		}
+		reduce := min(remaining, r.Quantity)
+		uncommitted := min(reduce, r.Shortfall)
+		if err := txReservationRepo.UpdateQuantity(r.ID, r.Quantity-reduce, r.Shortfall-uncommitted); err != nil {
+			return err
+		}
+		remaining -= reduce
+		restock -= uncommitted
+	}
+
+	return tx.Model(&model.Book{}).Where("id = ? AND format = ?", bookID, model.BookFormatPhysical).
+		Update("stock", gorm.Expr("stock + ?", restock)).Error
+}
+
+// restockOrderItems mengembalikan stok buku fisik untuk setiap item pesanan yang tidak dibatalkan.
+func restockOrderItems(tx *gorm.DB, orderID uuid.UUID) error {
+	var items []model.OrderItem
+	if err := tx.Where("order_id = ?", orderID).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		// Gunakan gorm.Expr untuk operasi atomik. Ebook dan gift card tidak memakai stok sehingga dilewati.
+		err := tx.Model(&model.Book{}).Where("id = ? AND format = ?", item.BookID, model.BookFormatPhysical).
+			Update("stock", gorm.Expr("stock + ?", item.ActiveQuantity())).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
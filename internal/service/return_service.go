@@ -0,0 +1,358 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"ngabaca/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrderNotReturnable        = errors.New("only completed orders can be returned")
+	ErrReturnWindowClosed        = errors.New("the return period for this order has ended")
+	ErrReturnItemNotReturnable   = errors.New("item is not part of this order or cannot be returned")
+	ErrReturnQuantityExceeded    = errors.New("return quantity exceeds what is left to return for this item")
+	ErrReturnStatusInvalid       = errors.New("this return cannot be processed in its current status")
+	ErrReturnTooManyPhotos       = errors.New("too many photos for one return")
+	ErrReturnPhotoInvalid        = errors.New("photos must be uploaded through the return photo endpoint")
+	ErrReturnDispositionRequired = errors.New("every returned item needs a disposition of restock or write_off")
+	ErrReturnItemNotFound        = errors.New("disposition refers to an item that is not part of this return")
+)
+
+// ReturnItemRequest adalah item pesanan yang ingin diretur pembeli.
+type ReturnItemRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" validate:"required"`
+	Quantity    int       `json:"quantity" validate:"required,gt=0"`
+}
+
+// CreateReturnRequest adalah isi pengajuan retur dari pembeli.
+type CreateReturnRequest struct {
+	Items        []ReturnItemRequest `json:"items" validate:"required,min=1,dive"`
+	Reason       string              `json:"reason" validate:"required,max=255"`
+	Note         string              `json:"note" validate:"max=1000"`
+	PhotoURLs    []string            `json:"photo_urls" validate:"omitempty,dive,url"`
+	RefundMethod string              `json:"refund_method" validate:"omitempty,oneof=original_payment store_credit"` // Default ke metode pembayaran asal
+}
+
+// ReceiveReturnRequest mencatat perlakuan barang retur yang diterima gudang. Disposition berlaku
+// untuk semua item, dan Items bisa menimpanya per item retur.
+type ReceiveReturnRequest struct {
+	Disposition string            `json:"disposition" validate:"omitempty,oneof=restock write_off"`
+	Items       map[string]string `json:"items"` // ID item retur -> restock atau write_off
+	Note        string            `json:"note" validate:"max=1000"`
+}
+
+// ReturnService mengelola pengajuan retur (RMA) dari diajukan, ditinjau, diterima, sampai dananya dikembalikan.
+type ReturnService interface {
+	RequestReturn(userID, orderID uuid.UUID, req *CreateReturnRequest) (*model.ReturnRequest, error)
+	CancelReturn(userID, returnID uuid.UUID) (*model.ReturnRequest, error)
+	GetUserReturns(userID uuid.UUID) ([]model.ReturnRequest, error)
+	GetUserReturn(userID, returnID uuid.UUID) (*model.ReturnRequest, error)
+	GetReturns(status string) ([]model.ReturnRequest, error)
+	GetReturn(returnID uuid.UUID) (*model.ReturnRequest, error)
+	Approve(adminID, returnID uuid.UUID, note string) (*model.ReturnRequest, error)
+	Reject(adminID, returnID uuid.UUID, note string) (*model.ReturnRequest, error)
+	Receive(returnID uuid.UUID, req *ReceiveReturnRequest) (*model.ReturnRequest, error)
+	Refund(returnID uuid.UUID, method string) (*model.ReturnRequest, error)
+}
+
+type returnService struct {
+	db            *gorm.DB
+	refundService RefundService
+	cfg           config.Config
+}
+
+func NewReturnService(db *gorm.DB, refundService RefundService, cfg config.Config) ReturnService {
+	return &returnService{db, refundService, cfg}
+}
+
+// RequestReturn membuat pengajuan retur untuk pesanan selesai yang masih dalam masa retur.
+// Hanya buku fisik yang bisa diretur, dan setiap item tidak bisa diretur melebihi jumlah yang dibeli.
+func (s *returnService) RequestReturn(userID, orderID uuid.UUID, req *CreateReturnRequest) (*model.ReturnRequest, error) {
+	if len(req.PhotoURLs) > s.cfg.ReturnMaxPhotos {
+		return nil, ErrReturnTooManyPhotos
+	}
+	for _, url := range req.PhotoURLs {
+		if s.cfg.ImageKitURLEndpoint == "" || !strings.HasPrefix(url, s.cfg.ImageKitURLEndpoint) {
+			return nil, ErrReturnPhotoInvalid
+		}
+	}
+
+	var ret model.ReturnRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Pesanan dikunci agar dua pengajuan bersamaan tidak meretur item yang sama dua kali
+		order, err := repository.NewOrderRepository(tx).FindByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return gorm.ErrRecordNotFound
+		}
+		if order.Status != "selesai" || order.Payment.Status != "success" {
+			return ErrOrderNotReturnable
+		}
+		completedAt := order.UpdatedAt
+		if order.CompletedAt != nil {
+			completedAt = *order.CompletedAt
+		}
+		if time.Now().After(completedAt.AddDate(0, 0, s.cfg.ReturnWindowDays)) {
+			return ErrReturnWindowClosed
+		}
+
+		txReturnRepo := repository.NewReturnRepository(tx)
+		returned, err := txReturnRepo.ReturnedQuantities(order.ID)
+		if err != nil {
+			return err
+		}
+
+		orderItems := make(map[uuid.UUID]model.OrderItem, len(order.OrderItems))
+		for _, item := range order.OrderItems {
+			orderItems[item.ID] = item
+		}
+
+		var items []model.ReturnItem
+		var total float64
+		for _, line := range req.Items {
+			item, ok := orderItems[line.OrderItemID]
+			if !ok || item.Kind != model.OrderItemKindPurchase || !item.Book.IsShippable() {
+				return ErrReturnItemNotReturnable
+			}
+			returned[item.ID] += line.Quantity
+			if returned[item.ID] > item.ActiveQuantity() {
+				return ErrReturnQuantityExceeded
+			}
+			amount := returnRefundAmount(order, item, line.Quantity)
+			total += amount
+			items = append(items, model.ReturnItem{OrderItemID: item.ID, Quantity: line.Quantity, RefundAmount: amount})
+		}
+
+		count, err := txReturnRepo.CountByOrderID(order.ID)
+		if err != nil {
+			return err
+		}
+		ret = model.ReturnRequest{
+			RMANumber:    model.FormatRMANumber(utils.DefaultString(order.OrderNumber, order.ID.String()), int(count)+1),
+			OrderID:      order.ID,
+			UserID:       userID,
+			Status:       model.ReturnStatusRequested,
+			Reason:       req.Reason,
+			CustomerNote: req.Note,
+			RefundMethod: utils.DefaultString(req.RefundMethod, model.RefundMethodOriginal),
+			RefundAmount: roundRupiah(total),
+			Items:        items,
+		}
+		for _, url := range req.PhotoURLs {
+			ret.Photos = append(ret.Photos, model.ReturnPhoto{URL: url})
+		}
+		return txReturnRepo.Create(&ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetReturn(ret.ID)
+}
+
+// returnRefundAmount menghitung dana yang dikembalikan untuk sebagian kuantitas item: harga setelah
+// potongan, ditambah PPN bagiannya jika pajak ditambahkan di luar harga. Ongkos kirim tidak dikembalikan.
+func returnRefundAmount(order model.Order, item model.OrderItem, quantity int) float64 {
+	share := float64(quantity) / float64(item.Quantity)
+	amount := (item.Price*float64(item.Quantity) - item.Discount) * share
+	if !order.TaxInclusive {
+		amount += item.TaxAmount * share
+	}
+	return roundRupiah(amount)
+}
+
+// CancelReturn membatalkan retur yang belum ditinjau admin.
+func (s *returnService) CancelReturn(userID, returnID uuid.UUID) (*model.ReturnRequest, error) {
+	if _, err := s.GetUserReturn(userID, returnID); err != nil {
+		return nil, err
+	}
+	return s.transition(returnID, func(ret *model.ReturnRequest) error {
+		now := time.Now()
+		ret.Status = model.ReturnStatusCancelled
+		ret.CancelledAt = &now
+		return nil
+	}, model.ReturnStatusCancelled)
+}
+
+func (s *returnService) GetUserReturns(userID uuid.UUID) ([]model.ReturnRequest, error) {
+	return repository.NewReturnRepository(s.db).FindByUserID(userID)
+}
+
+func (s *returnService) GetUserReturn(userID, returnID uuid.UUID) (*model.ReturnRequest, error) {
+	ret, err := s.GetReturn(returnID)
+	if err != nil {
+		return nil, err
+	}
+	if ret.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return ret, nil
+}
+
+func (s *returnService) GetReturns(status string) ([]model.ReturnRequest, error) {
+	return repository.NewReturnRepository(s.db).FindAll(status)
+}
+
+func (s *returnService) GetReturn(returnID uuid.UUID) (*model.ReturnRequest, error) {
+	ret, err := repository.NewReturnRepository(s.db).FindByID(returnID)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Approve menyetujui retur sehingga pembeli bisa mengirim barangnya kembali.
+func (s *returnService) Approve(adminID, returnID uuid.UUID, note string) (*model.ReturnRequest, error) {
+	return s.transition(returnID, func(ret *model.ReturnRequest) error {
+		now := time.Now()
+		ret.Status = model.ReturnStatusApproved
+		ret.ReviewedBy = &adminID
+		ret.ApprovedAt = &now
+		ret.AdminNote = utils.DefaultString(note, ret.AdminNote)
+		return nil
+	}, model.ReturnStatusApproved)
+}
+
+// Reject menolak retur. Item di dalamnya bisa diajukan lagi dalam retur baru selama masa retur belum habis.
+func (s *returnService) Reject(adminID, returnID uuid.UUID, note string) (*model.ReturnRequest, error) {
+	return s.transition(returnID, func(ret *model.ReturnRequest) error {
+		now := time.Now()
+		ret.Status = model.ReturnStatusRejected
+		ret.ReviewedBy = &adminID
+		ret.RejectedAt = &now
+		ret.AdminNote = utils.DefaultString(note, ret.AdminNote)
+		return nil
+	}, model.ReturnStatusRejected)
+}
+
+// Receive mencatat barang retur sudah diterima beserta perlakuannya. Barang yang di-restock
+// menambah stok buku kembali, sedangkan barang write-off hanya dicatat.
+func (s *returnService) Receive(returnID uuid.UUID, req *ReceiveReturnRequest) (*model.ReturnRequest, error) {
+	dispositions := make(map[uuid.UUID]string, len(req.Items))
+	for id, disposition := range req.Items {
+		itemID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, ErrReturnItemNotFound
+		}
+		if disposition != model.ReturnDispositionRestock && disposition != model.ReturnDispositionWriteOff {
+			return nil, ErrReturnDispositionRequired
+		}
+		dispositions[itemID] = disposition
+	}
+
+	return s.transitionTx(returnID, func(tx *gorm.DB, ret *model.ReturnRequest) error {
+		for itemID := range dispositions {
+			if !returnHasItem(*ret, itemID) {
+				return ErrReturnItemNotFound
+			}
+		}
+
+		txReturnRepo := repository.NewReturnRepository(tx)
+		for i, item := range ret.Items {
+			disposition := utils.DefaultString(dispositions[item.ID], req.Disposition)
+			if disposition == "" {
+				return ErrReturnDispositionRequired
+			}
+			if err := txReturnRepo.SetItemDisposition(item.ID, disposition); err != nil {
+				return err
+			}
+			ret.Items[i].Disposition = disposition
+
+			if disposition == model.ReturnDispositionRestock {
+				err := tx.Model(&model.Book{}).Where("id = ?", item.OrderItem.BookID).
+					Update("stock", gorm.Expr("stock + ?", item.Quantity)).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		now := time.Now()
+		ret.Status = model.ReturnStatusReceived
+		ret.ReceivedAt = &now
+		ret.AdminNote = utils.DefaultString(req.Note, ret.AdminNote)
+		return nil
+	}, model.ReturnStatusReceived)
+}
+
+// Refund mengembalikan dana retur yang barangnya sudah diterima. method kosong berarti memakai
+// pilihan pembeli saat mengajukan retur.
+func (s *returnService) Refund(returnID uuid.UUID, method string) (*model.ReturnRequest, error) {
+	var refund *model.Refund
+	ret, err := s.transitionTx(returnID, func(tx *gorm.DB, ret *model.ReturnRequest) error {
+		order, err := repository.NewOrderRepository(tx).FindByIDForUpdate(ret.OrderID)
+		if err != nil {
+			return err
+		}
+
+		ret.RefundMethod = utils.DefaultString(method, ret.RefundMethod)
+		if ret.RefundAmount > 0 {
+			refund, err = s.refundService.RefundOrder(tx, &order, ret.RefundAmount, ret.RefundMethod, fmt.Sprintf("Retur %s", ret.RMANumber))
+			if err != nil {
+				return err
+			}
+			ret.RefundID = &refund.ID
+		}
+
+		now := time.Now()
+		ret.Status = model.ReturnStatusRefunded
+		ret.RefundedAt = &now
+		return nil
+	}, model.ReturnStatusRefunded)
+	if err != nil {
+		return nil, err
+	}
+
+	settleRefund(s.refundService, refund)
+	return ret, nil
+}
+
+// transition memindahkan status retur tanpa akibat lain di luar baris retur itu sendiri.
+func (s *returnService) transition(returnID uuid.UUID, apply func(ret *model.ReturnRequest) error, to string) (*model.ReturnRequest, error) {
+	return s.transitionTx(returnID, func(_ *gorm.DB, ret *model.ReturnRequest) error {
+		return apply(ret)
+	}, to)
+}
+
+// transitionTx mengunci retur, memastikan perpindahan ke status to diizinkan, lalu menjalankan
+// apply dan menyimpan hasilnya dalam satu transaksi.
+func (s *returnService) transitionTx(returnID uuid.UUID, apply func(tx *gorm.DB, ret *model.ReturnRequest) error, to string) (*model.ReturnRequest, error) {
+	var ret model.ReturnRequest
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txReturnRepo := repository.NewReturnRepository(tx)
+		var err error
+		ret, err = txReturnRepo.FindByIDForUpdate(returnID)
+		if err != nil {
+			return err
+		}
+		if !ret.CanTransition(to) {
+			return ErrReturnStatusInvalid
+		}
+		if err := apply(tx, &ret); err != nil {
+			return err
+		}
+		return txReturnRepo.Update(&ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func returnHasItem(ret model.ReturnRequest, itemID uuid.UUID) bool {
+	for _, item := range ret.Items {
+		if item.ID == itemID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrderNotShippable        = errors.New("only paid orders being processed with physical books can be shipped")
+	ErrShipmentCourierRequired  = errors.New("courier and tracking number are required to ship an order")
+	ErrShipmentAlreadyFinal     = errors.New("this shipment has already been delivered or failed")
+	ErrInvalidShipmentStatus    = errors.New("status must be picked_up, in_transit, out_for_delivery, delivered, or failed")
+	ErrShipmentItemInvalid      = errors.New("only physical books from this order can be shipped")
+	ErrShipmentQuantityExceeded = errors.New("shipment quantity exceeds what is left to ship for this item")
+	ErrShipmentNothingToShip    = errors.New("every physical book in this order has already been shipped or cancelled")
+
+	// errShipmentUnchanged menandakan pelacakan kurir tidak membawa event baru
+	errShipmentUnchanged = errors.New("shipment has no new tracking events")
+)
+
+// shipmentStatusOrder adalah urutan status pengiriman. Event yang datang terlambat dengan status
+// lebih awal tetap masuk linimasa tetapi tidak memundurkan status pengiriman.
+var shipmentStatusOrder = map[string]int{
+	model.ShipmentStatusPickedUp:       1,
+	model.ShipmentStatusInTransit:      2,
+	model.ShipmentStatusOutForDelivery: 3,
+	model.ShipmentStatusDelivered:      4,
+	model.ShipmentStatusFailed:         4,
+}
+
+// ShipmentItemRequest adalah kuantitas satu item pesanan yang dimasukkan ke dalam paket.
+type ShipmentItemRequest struct {
+	OrderItemID uuid.UUID `json:"order_item_id" validate:"required"`
+	Quantity    int       `json:"quantity" validate:"required,gt=0"`
+}
+
+// ShipOrderRequest adalah data paket yang diserahkan ke kurir. Courier dan Service default ke
+// layanan yang dipilih pembeli saat checkout. Items kosong berarti semua buku fisik yang belum
+// dikirim dan tidak dibatalkan masuk ke paket ini.
+type ShipOrderRequest struct {
+	Courier        string                `json:"courier" validate:"omitempty,max=32"`
+	Service        string                `json:"service" validate:"omitempty,max=32"`
+	TrackingNumber string                `json:"tracking_number" validate:"omitempty,max=64"`
+	ShippedAt      *time.Time            `json:"shipped_at"`
+	Items          []ShipmentItemRequest `json:"items" validate:"omitempty,dive"`
+}
+
+// ShipmentEventRequest adalah event pelacakan yang dicatat manual oleh admin.
+type ShipmentEventRequest struct {
+	Status      string     `json:"status" validate:"required,oneof=picked_up in_transit out_for_delivery delivered failed"`
+	Description string     `json:"description" validate:"required,max=255"`
+	Location    string     `json:"location" validate:"max=128"`
+	OccurredAt  *time.Time `json:"occurred_at"` // Default waktu sekarang
+}
+
+// ShipmentService mencatat pengiriman pesanan dan linimasa pelacakannya. Satu pesanan bisa dikirim
+// dalam beberapa paket; pesanan selesai secara otomatis setelah semua paketnya diterima.
+type ShipmentService interface {
+	Ship(actorID uuid.UUID, orderID uuid.UUID, req *ShipOrderRequest) (*model.Shipment, error)
+	AddEvent(shipmentID uuid.UUID, req *ShipmentEventRequest) (*model.Shipment, error)
+	GetForOrder(orderID uuid.UUID) ([]model.Shipment, error)
+	PollAll() (int, error)
+}
+
+type shipmentService struct {
+	db            *gorm.DB
+	tracker       ShipmentTracker
+	statusService OrderStatusService
+}
+
+// NewShipmentService membuat ShipmentService. tracker boleh nil jika pelacakan otomatis tidak dipakai.
+func NewShipmentService(db *gorm.DB, tracker ShipmentTracker, statusService OrderStatusService) ShipmentService {
+	return &shipmentService{db, tracker, statusService}
+}
+
+// Ship mencatat paket pesanan yang diserahkan ke kurir oleh admin actorID. Status pesanan menjadi
+// dikirim_sebagian selama masih ada buku fisik yang belum dikirim, atau dikirim jika sudah semua.
+func (s *shipmentService) Ship(actorID uuid.UUID, orderID uuid.UUID, req *ShipOrderRequest) (*model.Shipment, error) {
+	var shipment model.Shipment
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txOrderRepo := repository.NewOrderRepository(tx)
+		order, err := txOrderRepo.FindByIDForUpdate(orderID)
+		if err != nil {
+			return err
+		}
+		if (order.Status != model.OrderStatusProcessing && order.Status != model.OrderStatusPartiallyShipped) || order.Payment.Status != "success" || !order.HasShippableItems() {
+			return ErrOrderNotShippable
+		}
+
+		courier := strings.ToLower(strings.TrimSpace(req.Courier))
+		if courier == "" {
+			courier = order.ShippingCourier
+		}
+		trackingNumber := strings.TrimSpace(req.TrackingNumber)
+		if courier == "" || trackingNumber == "" {
+			return ErrShipmentCourierRequired
+		}
+		courierService := strings.TrimSpace(req.Service)
+		if courierService == "" && courier == order.ShippingCourier {
+			courierService = order.ShippingService
+		}
+		shippedAt := time.Now()
+		if req.ShippedAt != nil {
+			shippedAt = *req.ShippedAt
+		}
+
+		txShipmentRepo := repository.NewShipmentRepository(tx)
+		shipped, err := txShipmentRepo.ShippedQuantities(order.ID)
+		if err != nil {
+			return err
+		}
+		items, err := shipmentItems(order, shipped, req.Items)
+		if err != nil {
+			return err
+		}
+
+		shipment = model.Shipment{
+			OrderID:        order.ID,
+			Courier:        courier,
+			Service:        courierService,
+			TrackingNumber: trackingNumber,
+			Status:         model.ShipmentStatusPickedUp,
+			ShippedAt:      shippedAt,
+			Items:          items,
+			Events: []model.ShipmentEvent{{
+				Status:      model.ShipmentStatusPickedUp,
+				Description: "Paket diserahkan ke kurir",
+				OccurredAt:  shippedAt,
+				Source:      model.ShipmentEventSourceAdmin,
+			}},
+		}
+		if err := txShipmentRepo.Create(&shipment); err != nil {
+			return err
+		}
+		order.Shipments = append(order.Shipments, shipment)
+
+		change := OrderStatusChange{
+			Actor:   model.OrderActorAdmin,
+			ActorID: &actorID,
+			Note:    fmt.Sprintf("Dikirim dengan %s resi %s", strings.ToUpper(courier), trackingNumber),
+		}
+		return syncFulfilmentStatus(tx, s.statusService, &order, change)
+	})
+
+	return &shipment, err
+}
+
+// AddEvent menambah event pelacakan dari admin, misalnya untuk kurir yang tidak punya API pelacakan.
+func (s *shipmentService) AddEvent(shipmentID uuid.UUID, req *ShipmentEventRequest) (*model.Shipment, error) {
+	if _, ok := shipmentStatusOrder[req.Status]; !ok {
+		return nil, ErrInvalidShipmentStatus
+	}
+	occurredAt := time.Now()
+	if req.OccurredAt != nil {
+		occurredAt = *req.OccurredAt
+	}
+	event := TrackingEvent{Status: req.Status, Description: req.Description, Location: req.Location, OccurredAt: occurredAt}
+
+	return s.record(shipmentID, model.ShipmentEventSourceAdmin, []TrackingEvent{event})
+}
+
+func (s *shipmentService) GetForOrder(orderID uuid.UUID) ([]model.Shipment, error) {
+	return repository.NewShipmentRepository(s.db).FindByOrderID(orderID)
+}
+
+// PollAll mengambil status terbaru semua pengiriman yang masih berjalan dari kurir. Hasilnya
+// adalah jumlah pengiriman yang mendapat event baru. Kegagalan satu resi tidak menghentikan yang lain.
+func (s *shipmentService) PollAll() (int, error) {
+	if s.tracker == nil {
+		return 0, nil
+	}
+	shipments, err := repository.NewShipmentRepository(s.db).FindTrackable(time.Now().Add(-15*time.Minute), 200)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, shipment := range shipments {
+		// API kurir dipanggil di luar transaksi agar baris pengiriman tidak terkunci selama menunggu respons
+		events, err := s.tracker.Track(shipment)
+		if err == nil {
+			_, err = s.record(shipment.ID, model.ShipmentEventSourceCourier, events)
+		}
+		if errors.Is(err, errShipmentUnchanged) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Gagal melacak resi %s %s: %v\n", shipment.Courier, shipment.TrackingNumber, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// record mengunci pengiriman, menggabungkan events yang belum tercatat, lalu memperbarui status
+// pengiriman. Event courier untuk pengiriman yang sudah berakhir diabaikan, sedangkan event admin
+// ditolak. Pesanan diselesaikan saat paket terakhirnya tercatat diterima.
+func (s *shipmentService) record(shipmentID uuid.UUID, source string, events []TrackingEvent) (*model.Shipment, error) {
+	var shipment model.Shipment
+	changed := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txShipmentRepo := repository.NewShipmentRepository(tx)
+		var err error
+		shipment, err = txShipmentRepo.FindByIDForUpdate(shipmentID)
+		if err != nil {
+			return err
+		}
+		if shipment.IsFinal() {
+			if source == model.ShipmentEventSourceAdmin {
+				return ErrShipmentAlreadyFinal
+			}
+			events = nil
+		}
+
+		wasDelivered := shipment.Status == model.ShipmentStatusDelivered
+		for _, e := range events {
+			if existing := findShipmentEvent(shipment, e); existing != nil {
+				// Titik manifest yang sama hanya diperbarui jika statusnya maju, misalnya menjadi delivered
+				if shipmentStatusOrder[e.Status] <= shipmentStatusOrder[existing.Status] {
+					continue
+				}
+				existing.Status = e.Status
+				if err := txShipmentRepo.UpdateEvent(existing); err != nil {
+					return err
+				}
+			} else {
+				event := model.ShipmentEvent{
+					ShipmentID:  shipment.ID,
+					Status:      e.Status,
+					Description: e.Description,
+					Location:    e.Location,
+					OccurredAt:  e.OccurredAt,
+					Source:      source,
+				}
+				if err := txShipmentRepo.AddEvent(&event); err != nil {
+					return err
+				}
+				shipment.Events = append(shipment.Events, event)
+			}
+			changed = true
+
+			if shipmentStatusOrder[e.Status] >= shipmentStatusOrder[shipment.Status] && !shipment.IsFinal() {
+				shipment.Status = e.Status
+				if e.Status == model.ShipmentStatusDelivered {
+					deliveredAt := e.OccurredAt
+					shipment.DeliveredAt = &deliveredAt
+				}
+			}
+		}
+
+		if source == model.ShipmentEventSourceCourier {
+			now := time.Now()
+			shipment.LastPolledAt = &now
+		}
+		if err := txShipmentRepo.Update(&shipment); err != nil {
+			return err
+		}
+
+		if wasDelivered || shipment.Status != model.ShipmentStatusDelivered {
+			return nil
+		}
+		order, err := repository.NewOrderRepository(tx).FindByIDForUpdate(shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		return completeIfDelivered(tx, s.statusService, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !changed && source == model.ShipmentEventSourceCourier {
+		return &shipment, errShipmentUnchanged
+	}
+	return &shipment, nil
+}
+
+// findShipmentEvent mencari event yang sudah tercatat untuk titik manifest yang sama, karena API kurir
+// selalu mengembalikan seluruh linimasa setiap kali dilacak. Status tidak ikut dibandingkan karena
+// status diturunkan dari posisi manifest, sehingga baris terakhir bisa berubah menjadi delivered.
+func findShipmentEvent(shipment model.Shipment, e TrackingEvent) *model.ShipmentEvent {
+	for i, existing := range shipment.Events {
+		if existing.Description == e.Description && existing.Location == e.Location && existing.OccurredAt.Equal(e.OccurredAt) {
+			return &shipment.Events[i]
+		}
+	}
+	return nil
+}
+
+// shipmentItems menyusun isi paket dari permintaan admin. Permintaan kosong berarti semua sisa buku
+// fisik yang belum dikirim dan tidak dibatalkan.
+func shipmentItems(order model.Order, shipped map[uuid.UUID]int, lines []ShipmentItemRequest) ([]model.ShipmentItem, error) {
+	if len(lines) == 0 {
+		for _, item := range order.OrderItems {
+			if left := item.ActiveQuantity() - shipped[item.ID]; item.Book.IsShippable() && left > 0 {
+				lines = append(lines, ShipmentItemRequest{OrderItemID: item.ID, Quantity: left})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, ErrShipmentNothingToShip
+		}
+	}
+
+	orderItems := make(map[uuid.UUID]model.OrderItem, len(order.OrderItems))
+	for _, item := range order.OrderItems {
+		orderItems[item.ID] = item
+	}
+	requested := make(map[uuid.UUID]int, len(lines))
+	items := make([]model.ShipmentItem, 0, len(lines))
+	for _, line := range lines {
+		item, ok := orderItems[line.OrderItemID]
+		if !ok || !item.Book.IsShippable() || line.Quantity <= 0 {
+			return nil, ErrShipmentItemInvalid
+		}
+		requested[item.ID] += line.Quantity
+		if shipped[item.ID]+requested[item.ID] > item.ActiveQuantity() {
+			return nil, ErrShipmentQuantityExceeded
+		}
+		items = append(items, model.ShipmentItem{OrderItemID: item.ID, Quantity: line.Quantity})
+	}
+	return items, nil
+}
+
+// fulfilmentStatus menurunkan status pesanan dari kuantitas buku fisik yang sudah dikirim dan yang
+// dibatalkan. Hasil kosong berarti semua buku fisik dibatalkan sebelum satu pun dikirim.
+func fulfilmentStatus(order model.Order, shipped map[uuid.UUID]int) string {
+	shippedUnits, pendingUnits := 0, 0
+	for _, item := range order.OrderItems {
+		if !item.Book.IsShippable() {
+			continue
+		}
+		shippedUnits += shipped[item.ID]
+		pendingUnits += item.ActiveQuantity() - shipped[item.ID]
+	}
+
+	switch {
+	case shippedUnits > 0 && pendingUnits > 0:
+		return model.OrderStatusPartiallyShipped
+	case shippedUnits > 0:
+		return model.OrderStatusShipped
+	case pendingUnits > 0:
+		return model.OrderStatusProcessing
+	}
+	return ""
+}
+
+// syncFulfilmentStatus menyesuaikan status pesanan setelah paket dikirim atau item dibatalkan.
+// Pesanan yang semua itemnya dibatalkan ikut dibatalkan. Pesanan yang semua buku fisiknya dibatalkan
+// sebelum dikirim tetapi masih berisi ebook diselesaikan, dan pesanan yang sisa bukunya dibatalkan
+// setelah semua paketnya diterima langsung diselesaikan.
+func syncFulfilmentStatus(tx *gorm.DB, statusService OrderStatusService, order *model.Order, change OrderStatusChange) error {
+	shipped, err := repository.NewShipmentRepository(tx).ShippedQuantities(order.ID)
+	if err != nil {
+		return err
+	}
+	status := fulfilmentStatus(*order, shipped)
+	if status == "" {
+		status = model.OrderStatusCompleted
+		if allItemsCancelled(*order) {
+			status = model.OrderStatusCancelled
+		}
+	}
+	if status == order.Status {
+		return nil
+	}
+	if err := statusService.Transition(tx, order, status, change); err != nil {
+		return err
+	}
+	return completeIfDelivered(tx, statusService, order)
+}
+
+// completeIfDelivered menyelesaikan pesanan yang semua buku fisiknya sudah dikirim begitu semua
+// paketnya diterima. Pesanan yang dibatalkan atau sudah diselesaikan admin tidak diubah lagi.
+func completeIfDelivered(tx *gorm.DB, statusService OrderStatusService, order *model.Order) error {
+	if order.Status != model.OrderStatusShipped || len(order.Shipments) == 0 {
+		return nil
+	}
+	for _, shipment := range order.Shipments {
+		if shipment.Status != model.ShipmentStatusDelivered {
+			return nil
+		}
+	}
+	change := OrderStatusChange{Actor: model.OrderActorSystem, Note: "Semua paket diterima"}
+	return statusService.Transition(tx, order, model.OrderStatusCompleted, change)
+}
+
+// allItemsCancelled menandakan tidak ada lagi item pesanan yang harus dipenuhi.
+func allItemsCancelled(order model.Order) bool {
+	for _, item := range order.OrderItems {
+		if item.ActiveQuantity() > 0 {
+			return false
+		}
+	}
+	return true
+}
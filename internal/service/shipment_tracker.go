@@ -0,0 +1,137 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"ngabaca/config"
+	"ngabaca/internal/model"
+	"strings"
+	"time"
+)
+
+// TrackingEvent adalah satu status paket yang dilaporkan kurir.
+type TrackingEvent struct {
+	Status      string
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// ShipmentTracker mengambil linimasa pelacakan paket dari kurir. Implementasinya bisa berupa
+// adapter API kurir atau tiruan lokal untuk pengembangan.
+type ShipmentTracker interface {
+	Track(shipment model.Shipment) ([]TrackingEvent, error)
+}
+
+// NewShipmentTracker memilih pelacak dari SHIPMENT_TRACKER: "api" memakai API kurir di
+// SHIPPING_COURIER_API_URL, "fake" memakai pelacak tiruan. Nil berarti status pengiriman hanya
+// diperbarui manual oleh admin.
+func NewShipmentTracker(cfg config.Config) ShipmentTracker {
+	switch strings.ToLower(cfg.ShipmentTracker) {
+	case "fake":
+		return NewFakeShipmentTracker()
+	case "api":
+		if cfg.ShippingCourierAPIURL != "" {
+			return NewCourierAPITracker(cfg.ShippingCourierAPIURL, cfg.ShippingCourierAPIKey)
+		}
+		fmt.Println("Pelacakan pengiriman otomatis nonaktif karena SHIPPING_COURIER_API_URL kosong")
+	}
+	return nil
+}
+
+// courierWaybillResponse mengikuti bentuk respons endpoint cek resi yang umum dipakai kurir
+// dan agregator ongkir di Indonesia: ringkasan status dan manifest perjalanan paket.
+type courierWaybillResponse struct {
+	Status struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"status"`
+	Result struct {
+		Delivered bool `json:"delivered"`
+		Manifest  []struct {
+			Description string `json:"manifest_description"`
+			Date        string `json:"manifest_date"` // YYYY-MM-DD
+			Time        string `json:"manifest_time"` // HH:MM:SS, waktu setempat
+			City        string `json:"city_name"`
+		} `json:"manifest"`
+	} `json:"result"`
+}
+
+// courierAPITracker adalah adapter ke API cek resi kurir.
+type courierAPITracker struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewCourierAPITracker membuat ShipmentTracker lewat API cek resi kurir.
+func NewCourierAPITracker(baseURL, apiKey string) ShipmentTracker {
+	return &courierAPITracker{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *courierAPITracker) Track(shipment model.Shipment) ([]TrackingEvent, error) {
+	form := url.Values{}
+	form.Set("waybill", shipment.TrackingNumber)
+	form.Set("courier", shipment.Courier)
+
+	req, err := http.NewRequest("POST", t.baseURL+"/waybill", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("key", t.apiKey)
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	respBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s waybill request failed: %s", shipment.Courier, string(respBytes))
+	}
+
+	var payload courierWaybillResponse
+	if err := json.Unmarshal(respBytes, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Status.Code != 0 && payload.Status.Code != http.StatusOK {
+		return nil, fmt.Errorf("%s waybill request failed: %s", shipment.Courier, payload.Status.Description)
+	}
+
+	// Kurir tidak memberi kode status per manifest, jadi status diturunkan dari urutannya:
+	// manifest pertama adalah serah terima, yang terakhir menandai paket diterima jika delivered.
+	manifest := payload.Result.Manifest
+	events := make([]TrackingEvent, 0, len(manifest))
+	for i, m := range manifest {
+		occurredAt, err := time.ParseInLocation("2006-01-02 15:04:05", m.Date+" "+m.Time, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%s waybill has invalid manifest time %q %q", shipment.Courier, m.Date, m.Time)
+		}
+		status := model.ShipmentStatusInTransit
+		switch {
+		case i == len(manifest)-1 && payload.Result.Delivered:
+			status = model.ShipmentStatusDelivered
+		case i == 0:
+			status = model.ShipmentStatusPickedUp
+		}
+		events = append(events, TrackingEvent{
+			Status:      status,
+			Description: m.Description,
+			Location:    m.City,
+			OccurredAt:  occurredAt,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"ngabaca/internal/model"
+	"sync"
+	"time"
+)
+
+// fakeTrackingSteps adalah perjalanan paket tiruan, satu langkah per kali dilacak.
+var fakeTrackingSteps = []TrackingEvent{
+	{Status: model.ShipmentStatusPickedUp, Description: "Paket diterima di gerai kurir", Location: "Gudang Ngabaca"},
+	{Status: model.ShipmentStatusInTransit, Description: "Paket diberangkatkan ke kota tujuan", Location: "Hub Transit"},
+	{Status: model.ShipmentStatusOutForDelivery, Description: "Paket dibawa kurir ke alamat tujuan", Location: "Kota Tujuan"},
+	{Status: model.ShipmentStatusDelivered, Description: "Paket diterima oleh penerima", Location: "Kota Tujuan"},
+}
+
+// fakeShipmentTracker adalah pelacak pengiriman tiruan untuk pengembangan lokal. Setiap kali
+// sebuah resi dilacak, paketnya maju satu langkah sampai diterima.
+type fakeShipmentTracker struct {
+	mu     sync.Mutex
+	events map[string][]TrackingEvent
+}
+
+// NewFakeShipmentTracker membuat ShipmentTracker tiruan yang menyimpan perjalanan paket di memori.
+func NewFakeShipmentTracker() ShipmentTracker {
+	return &fakeShipmentTracker{events: map[string][]TrackingEvent{}}
+}
+
+func (t *fakeShipmentTracker) Track(shipment model.Shipment) ([]TrackingEvent, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := shipment.Courier + ":" + shipment.TrackingNumber
+	events := t.events[key]
+	if len(events) < len(fakeTrackingSteps) {
+		step := fakeTrackingSteps[len(events)]
+		step.OccurredAt = time.Now().Truncate(time.Second)
+		events = append(events, step)
+		t.events[key] = events
+	}
+	return append([]TrackingEvent(nil), events...), nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"math"
+)
+
+// ShippingPackage adalah paket yang akan dikirim beserta asal dan tujuannya.
+type ShippingPackage struct {
+	OriginPostalCode      string
+	DestinationPostalCode string
+	WeightGrams           int
+	VolumeCm3             float64 // Total volume kemasan, untuk berat volumetrik
+}
+
+// ChargeableKg menghitung berat tagih dalam kilogram: yang lebih besar antara berat aktual dan
+// berat volumetrik (volume / divisor), dibulatkan ke atas dengan minimal 1 kg.
+func (p ShippingPackage) ChargeableKg(volumetricDivisor float64) int {
+	kg := float64(p.WeightGrams) / 1000
+	if volumetricDivisor > 0 {
+		kg = math.Max(kg, p.VolumeCm3/volumetricDivisor)
+	}
+	return max(int(math.Ceil(kg)), 1)
+}
+
+// ShippingRate adalah satu layanan pengiriman yang tersedia beserta biayanya.
+type ShippingRate struct {
+	Courier     string  `json:"courier"`      // Kode kurir, misal jne
+	CourierName string  `json:"courier_name"` // Nama kurir untuk ditampilkan
+	Service     string  `json:"service"`      // Kode layanan, misal REG
+	Description string  `json:"description"`
+	Cost        float64 `json:"cost"`
+	Etd         string  `json:"etd"` // Perkiraan lama pengiriman dalam hari, misal "2-3"
+}
+
+// ShippingProvider menghitung tarif pengiriman untuk sebuah paket. Implementasinya bisa berupa
+// tabel tarif internal atau adapter API kurir, dan bisa diganti dengan tiruan lokal saat pengujian.
+type ShippingProvider interface {
+	Rates(pkg ShippingPackage) ([]ShippingRate, error)
+}
@@ -0,0 +1,56 @@
+package service
+
+import "sync"
+
+// fakeShippingProvider adalah penyedia tarif tiruan untuk pengembangan lokal dan pengujian. Tarifnya
+// tetap per kilogram tanpa memandang zona, dan bisa dibuat gagal untuk meniru kurir yang sedang gangguan.
+type fakeShippingProvider struct {
+	courier string
+	rates   []tableRate
+	err     error
+
+	mu    sync.Mutex
+	calls int
+}
+
+// NewFakeShippingProvider membuat ShippingProvider tiruan dengan layanan REG dan EXP.
+func NewFakeShippingProvider() ShippingProvider {
+	return &fakeShippingProvider{
+		courier: "fake",
+		rates: []tableRate{
+			{"REG", "Reguler", 10000, "2-3"},
+			{"EXP", "Ekspres", 20000, "1"},
+		},
+	}
+}
+
+func (p *fakeShippingProvider) Rates(pkg ShippingPackage) ([]ShippingRate, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	kg := float64(pkg.ChargeableKg(6000))
+	rates := make([]ShippingRate, 0, len(p.rates))
+	for _, r := range p.rates {
+		rates = append(rates, ShippingRate{
+			Courier:     p.courier,
+			CourierName: "Kurir Tiruan",
+			Service:     r.Service,
+			Description: r.Description,
+			Cost:        roundRupiah(r.PerKg * kg),
+			Etd:         r.Etd,
+		})
+	}
+	return rates, nil
+}
+
+// Calls mengembalikan berapa kali tarif diminta.
+func (p *fakeShippingProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"ngabaca/config"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrShippingDestinationRequired = errors.New("destination postal code is required for physical books")
+	ErrShippingServiceRequired     = errors.New("please choose a shipping service")
+	ErrShippingServiceUnavailable  = errors.New("the chosen shipping service is not available for this destination")
+	ErrNoShippingRates             = errors.New("no shipping service is available for this destination")
+	ErrInvalidPostalCode           = errors.New("postal code must be 5 digits")
+)
+
+// ShippingService mengumpulkan tarif dari semua penyedia pengiriman yang aktif.
+type ShippingService interface {
+	Rates(pkg ShippingPackage) ([]ShippingRate, error)
+	OriginPostalCode() string
+}
+
+type shippingService struct {
+	providers []ShippingProvider
+	origin    string
+}
+
+// NewShippingService merakit penyedia pengiriman dari SHIPPING_PROVIDERS. "table" memakai
+// tabel tarif bawaan, "fake" memakai tarif tiruan untuk pengembangan lokal, dan kode kurir
+// lain (jne, sicepat) memakai adapter API kurir.
+func NewShippingService(cfg config.Config) ShippingService {
+	var providers []ShippingProvider
+	for _, name := range strings.Split(cfg.ShippingProviders, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "":
+			continue
+		case name == "table":
+			providers = append(providers, NewTableShippingProvider())
+		case name == "fake":
+			providers = append(providers, NewFakeShippingProvider())
+		case cfg.ShippingCourierAPIURL != "":
+			providers = append(providers, NewCourierAPIProvider(name, cfg.ShippingCourierAPIURL, cfg.ShippingCourierAPIKey))
+		default:
+			fmt.Printf("Penyedia pengiriman %s dilewati karena SHIPPING_COURIER_API_URL kosong\n", name)
+		}
+	}
+	return NewShippingServiceWithProviders(cfg.ShippingOriginPostalCode, providers...)
+}
+
+// NewShippingServiceWithProviders membuat ShippingService dari penyedia yang sudah jadi,
+// misalnya penyedia tiruan untuk pengujian.
+func NewShippingServiceWithProviders(origin string, providers ...ShippingProvider) ShippingService {
+	return &shippingService{providers: providers, origin: origin}
+}
+
+func (s *shippingService) OriginPostalCode() string {
+	return s.origin
+}
+
+// Rates mengambil tarif dari semua penyedia, urut dari yang termurah. Penyedia yang gagal
+// dilewati agar gangguan satu kurir tidak menghentikan checkout, kecuali semuanya gagal.
+func (s *shippingService) Rates(pkg ShippingPackage) ([]ShippingRate, error) {
+	if pkg.OriginPostalCode == "" {
+		pkg.OriginPostalCode = s.origin
+	}
+	if !isPostalCode(pkg.DestinationPostalCode) {
+		return nil, ErrInvalidPostalCode
+	}
+
+	var rates []ShippingRate
+	var lastErr error
+	for _, provider := range s.providers {
+		providerRates, err := provider.Rates(pkg)
+		if err != nil {
+			fmt.Println("Error saat mengambil tarif pengiriman:", err)
+			lastErr = err
+			continue
+		}
+		rates = append(rates, providerRates...)
+	}
+	if len(rates) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, ErrNoShippingRates
+	}
+
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Cost < rates[j].Cost })
+	return rates, nil
+}
+
+// findShippingRate mencari layanan yang dipilih pembeli di antara tarif yang tersedia.
+func findShippingRate(rates []ShippingRate, courier, service string) (ShippingRate, bool) {
+	for _, rate := range rates {
+		if strings.EqualFold(rate.Courier, courier) && strings.EqualFold(rate.Service, service) {
+			return rate, true
+		}
+	}
+	return ShippingRate{}, false
+}
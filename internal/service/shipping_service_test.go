@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestShippingProvider(courier string, perKg float64) *fakeShippingProvider {
+	return &fakeShippingProvider{
+		courier: courier,
+		rates:   []tableRate{{"REG", "Reguler", perKg, "2-3"}},
+	}
+}
+
+func TestShippingRatesSortedAcrossProviders(t *testing.T) {
+	expensive := newTestShippingProvider("jne", 15000)
+	cheap := newTestShippingProvider("sicepat", 9000)
+	shipping := NewShippingServiceWithProviders("10110", expensive, cheap)
+
+	rates, err := shipping.Rates(ShippingPackage{DestinationPostalCode: "40111", WeightGrams: 1500})
+	if err != nil {
+		t.Fatalf("Rates: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("got %d rates, want 2", len(rates))
+	}
+	if rates[0].Courier != "sicepat" || rates[0].Cost != 18000 {
+		t.Errorf("cheapest rate = %s %v, want sicepat 18000", rates[0].Courier, rates[0].Cost)
+	}
+	if rates[1].Courier != "jne" || rates[1].Cost != 30000 {
+		t.Errorf("second rate = %s %v, want jne 30000", rates[1].Courier, rates[1].Cost)
+	}
+}
+
+func TestShippingRatesSkipFailingProvider(t *testing.T) {
+	down := newTestShippingProvider("jne", 15000)
+	down.err = errors.New("courier timeout")
+	up := newTestShippingProvider("sicepat", 9000)
+	shipping := NewShippingServiceWithProviders("10110", down, up)
+
+	rates, err := shipping.Rates(ShippingPackage{DestinationPostalCode: "40111", WeightGrams: 500})
+	if err != nil {
+		t.Fatalf("Rates: %v", err)
+	}
+	if len(rates) != 1 || rates[0].Courier != "sicepat" {
+		t.Errorf("got %+v, want only the sicepat rate", rates)
+	}
+}
+
+func TestShippingRatesAllProvidersFail(t *testing.T) {
+	errTimeout := errors.New("courier timeout")
+	down := newTestShippingProvider("jne", 15000)
+	down.err = errTimeout
+	shipping := NewShippingServiceWithProviders("10110", down)
+
+	if _, err := shipping.Rates(ShippingPackage{DestinationPostalCode: "40111"}); !errors.Is(err, errTimeout) {
+		t.Errorf("err = %v, want %v", err, errTimeout)
+	}
+}
+
+func TestShippingRatesInvalidPostalCode(t *testing.T) {
+	provider := newTestShippingProvider("jne", 15000)
+	shipping := NewShippingServiceWithProviders("10110", provider)
+
+	if _, err := shipping.Rates(ShippingPackage{DestinationPostalCode: "4011"}); !errors.Is(err, ErrInvalidPostalCode) {
+		t.Errorf("err = %v, want %v", err, ErrInvalidPostalCode)
+	}
+	if provider.Calls() != 0 {
+		t.Errorf("provider called %d times for an invalid postal code", provider.Calls())
+	}
+}
+
+func TestTableProviderRemoteZone(t *testing.T) {
+	rates, err := NewTableShippingProvider().Rates(ShippingPackage{
+		OriginPostalCode:      "10110",
+		DestinationPostalCode: "90111",
+		WeightGrams:           2500,
+	})
+	if err != nil {
+		t.Fatalf("Rates: %v", err)
+	}
+	if len(rates) != 2 || rates[0].Service != "REG" || rates[0].Cost != 135000 {
+		t.Errorf("got %+v, want REG at 135000 for 3 kg to the remote zone", rates)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+// Zona pengiriman tabel tarif internal, ditentukan dari kode pos asal dan tujuan.
+const (
+	shippingZoneLocal    = "local"    // Satu wilayah kode pos (dua digit pertama sama)
+	shippingZoneRegional = "regional" // Satu pulau
+	shippingZoneNational = "national" // Antarpulau
+	shippingZoneRemote   = "remote"   // Antarpulau ke/dari Sulawesi, Maluku, dan Papua
+)
+
+// tableRate adalah tarif per kilogram untuk satu layanan di satu zona.
+type tableRate struct {
+	Service     string
+	Description string
+	PerKg       float64
+	Etd         string
+}
+
+// shippingTable adalah tarif bawaan per zona. Berat dihitung per kilogram yang dibulatkan ke atas.
+var shippingTable = map[string][]tableRate{
+	shippingZoneLocal: {
+		{"REG", "Reguler", 9000, "1-2"},
+		{"EXP", "Ekspres", 18000, "1"},
+	},
+	shippingZoneRegional: {
+		{"REG", "Reguler", 12000, "2-3"},
+		{"EXP", "Ekspres", 24000, "1-2"},
+	},
+	shippingZoneNational: {
+		{"REG", "Reguler", 22000, "3-5"},
+		{"EXP", "Ekspres", 40000, "2-3"},
+	},
+	shippingZoneRemote: {
+		{"REG", "Reguler", 45000, "5-8"},
+		{"EXP", "Ekspres", 80000, "3-5"},
+	},
+}
+
+// postalIsland memetakan digit pertama kode pos ke kelompok pulaunya.
+var postalIsland = map[byte]string{
+	'1': "jawa", '4': "jawa", '5': "jawa", '6': "jawa",
+	'2': "sumatera", '3': "sumatera",
+	'7': "kalimantan",
+	'8': "bali-nusa-tenggara",
+	'9': "timur",
+}
+
+// tableShippingProvider menghitung ongkos kirim dari tabel tarif berat/zona milik toko sendiri.
+type tableShippingProvider struct{}
+
+// NewTableShippingProvider membuat ShippingProvider berbasis tabel tarif bawaan.
+func NewTableShippingProvider() ShippingProvider {
+	return &tableShippingProvider{}
+}
+
+func (p *tableShippingProvider) Rates(pkg ShippingPackage) ([]ShippingRate, error) {
+	zone, err := shippingZone(pkg.OriginPostalCode, pkg.DestinationPostalCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pembagi volumetrik 6000 mengikuti aturan umum kurir darat di Indonesia
+	kg := float64(pkg.ChargeableKg(6000))
+	rates := make([]ShippingRate, 0, len(shippingTable[zone]))
+	for _, r := range shippingTable[zone] {
+		rates = append(rates, ShippingRate{
+			Courier:     "ngabaca",
+			CourierName: "Ngabaca Kirim",
+			Service:     r.Service,
+			Description: r.Description,
+			Cost:        roundRupiah(r.PerKg * kg),
+			Etd:         r.Etd,
+		})
+	}
+	return rates, nil
+}
+
+// shippingZone menentukan zona pengiriman antara dua kode pos.
+func shippingZone(origin, destination string) (string, error) {
+	if !isPostalCode(origin) || !isPostalCode(destination) {
+		return "", ErrInvalidPostalCode
+	}
+	if origin[:2] == destination[:2] {
+		return shippingZoneLocal, nil
+	}
+	from, to := postalIsland[origin[0]], postalIsland[destination[0]]
+	switch {
+	case from == to:
+		return shippingZoneRegional, nil
+	case from == "timur" || to == "timur":
+		return shippingZoneRemote, nil
+	}
+	return shippingZoneNational, nil
+}
+
+func isPostalCode(code string) bool {
+	if len(code) != 5 || code[0] == '0' {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
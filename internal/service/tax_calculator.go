@@ -0,0 +1,53 @@
+package service
+
+import (
+	"ngabaca/config"
+	"ngabaca/internal/model"
+)
+
+// LineTax adalah hasil perhitungan PPN untuk satu baris pesanan.
+type LineTax struct {
+	Class  string
+	Rate   float64
+	Base   float64 // Dasar pengenaan pajak (DPP)
+	Amount float64
+}
+
+// TaxCalculator menghitung PPN per kelas produk, baik untuk harga termasuk maupun belum termasuk pajak.
+type TaxCalculator struct {
+	rates     map[string]float64
+	inclusive bool
+}
+
+// NewTaxCalculator membuat TaxCalculator dari tarif di konfigurasi.
+func NewTaxCalculator(cfg config.Config) TaxCalculator {
+	return TaxCalculator{
+		rates: map[string]float64{
+			model.TaxClassPhysicalBook: cfg.TaxRatePhysicalBook,
+			model.TaxClassEbook:        cfg.TaxRateEbook,
+			model.TaxClassExempt:       cfg.TaxRateExempt,
+			model.TaxClassNone:         0,
+		},
+		inclusive: cfg.TaxPricesInclusive,
+	}
+}
+
+// Inclusive menandakan harga katalog sudah termasuk PPN.
+func (t TaxCalculator) Inclusive() bool {
+	return t.inclusive
+}
+
+// Line menghitung PPN atas nilai baris setelah potongan. Untuk harga termasuk pajak, PPN diambil
+// dari dalam nilai tersebut (DPP = nilai / (1 + tarif)); untuk harga belum termasuk pajak, PPN
+// ditambahkan di atas nilai tersebut.
+func (t TaxCalculator) Line(class string, amount float64) LineTax {
+	rate := t.rates[class]
+	if amount <= 0 || rate <= 0 {
+		return LineTax{Class: class, Rate: rate, Base: max(amount, 0)}
+	}
+	if t.inclusive {
+		base := roundRupiah(amount / (1 + rate/100))
+		return LineTax{Class: class, Rate: rate, Base: base, Amount: amount - base}
+	}
+	return LineTax{Class: class, Rate: rate, Base: amount, Amount: roundRupiah(amount * rate / 100)}
+}
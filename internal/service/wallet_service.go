@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"ngabaca/internal/model"
+	"ngabaca/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInsufficientWalletBalance = errors.New("insufficient wallet balance")
+	ErrInvalidWalletAmount       = errors.New("amount must not be zero")
+)
+
+// WalletEntry adalah keterangan tambahan untuk sebuah mutasi dompet.
+type WalletEntry struct {
+	Source     string
+	OrderID    *uuid.UUID
+	GiftCardID *uuid.UUID
+	Note       string
+}
+
+// WalletSummary adalah saldo dompet beserta mutasi terakhirnya.
+type WalletSummary struct {
+	Balance      float64                   `json:"balance"`
+	Transactions []model.WalletTransaction `json:"transactions"`
+}
+
+// WalletService mengelola saldo dompet. Setiap perubahan saldo selalu disertai baris buku besar.
+type WalletService interface {
+	GetSummary(userID uuid.UUID) (*WalletSummary, error)
+	Credit(tx *gorm.DB, userID uuid.UUID, amount float64, entry WalletEntry) (*model.WalletTransaction, error)
+	Debit(tx *gorm.DB, userID uuid.UUID, amount float64, entry WalletEntry) (*model.WalletTransaction, error)
+	Adjust(userID uuid.UUID, amount float64, note string) (*model.WalletTransaction, error)
+	PayForOrder(tx *gorm.DB, order *model.Order, requested float64) error
+	ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error
+}
+
+type walletService struct {
+	db *gorm.DB
+}
+
+func NewWalletService(db *gorm.DB) WalletService {
+	return &walletService{db}
+}
+
+func (s *walletService) GetSummary(userID uuid.UUID) (*WalletSummary, error) {
+	walletRepo := repository.NewWalletRepository(s.db)
+	wallet, err := walletRepo.FindOrCreate(userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := walletRepo.FindTransactions(userID, 50)
+	if err != nil {
+		return nil, err
+	}
+	return &WalletSummary{Balance: wallet.Balance, Transactions: transactions}, nil
+}
+
+// Credit menambah saldo dompet dan mencatatnya di buku besar.
+func (s *walletService) Credit(tx *gorm.DB, userID uuid.UUID, amount float64, entry WalletEntry) (*model.WalletTransaction, error) {
+	txWalletRepo := repository.NewWalletRepository(tx)
+	wallet, err := txWalletRepo.FindOrCreate(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := txWalletRepo.AddBalance(&wallet, amount); err != nil {
+		return nil, err
+	}
+	return s.record(txWalletRepo, wallet, model.WalletCredit, amount, entry)
+}
+
+// Debit mengurangi saldo dompet dan mencatatnya di buku besar. Saldo tidak akan pernah negatif:
+// pengurangan dilakukan dengan update bersyarat sehingga aman terhadap checkout yang bersamaan.
+func (s *walletService) Debit(tx *gorm.DB, userID uuid.UUID, amount float64, entry WalletEntry) (*model.WalletTransaction, error) {
+	txWalletRepo := repository.NewWalletRepository(tx)
+	wallet, err := txWalletRepo.FindOrCreate(userID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := txWalletRepo.SubtractBalance(&wallet, amount)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInsufficientWalletBalance
+	}
+	return s.record(txWalletRepo, wallet, model.WalletDebit, amount, entry)
+}
+
+func (s *walletService) record(txWalletRepo repository.WalletRepository, wallet model.Wallet, kind string, amount float64, entry WalletEntry) (*model.WalletTransaction, error) {
+	transaction := &model.WalletTransaction{
+		WalletID:     wallet.ID,
+		UserID:       wallet.UserID,
+		Type:         kind,
+		Source:       entry.Source,
+		Amount:       amount,
+		BalanceAfter: wallet.Balance,
+		OrderID:      entry.OrderID,
+		GiftCardID:   entry.GiftCardID,
+		Note:         entry.Note,
+	}
+	if err := txWalletRepo.CreateTransaction(transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// Adjust menambah (nilai positif) atau mengurangi (nilai negatif) saldo dari panel admin.
+func (s *walletService) Adjust(userID uuid.UUID, amount float64, note string) (*model.WalletTransaction, error) {
+	if amount == 0 {
+		return nil, ErrInvalidWalletAmount
+	}
+
+	var transaction *model.WalletTransaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		entry := WalletEntry{Source: model.WalletSourceAdminAdjustment, Note: note}
+		var err error
+		if amount > 0 {
+			transaction, err = s.Credit(tx, userID, amount, entry)
+		} else {
+			transaction, err = s.Debit(tx, userID, -amount, entry)
+		}
+		return err
+	})
+	return transaction, err
+}
+
+// PayForOrder memakai saldo dompet untuk membayar sebagian atau seluruh pesanan.
+// requested = 0 berarti pakai saldo sebanyak mungkin. Nominal yang terpakai disimpan di order.WalletAmount.
+func (s *walletService) PayForOrder(tx *gorm.DB, order *model.Order, requested float64) error {
+	wallet, err := repository.NewWalletRepository(tx).FindOrCreate(order.UserID)
+	if err != nil {
+		return err
+	}
+
+	amount := order.TotalPrice
+	if requested > 0 && requested < amount {
+		amount = requested
+	}
+	if wallet.Balance < amount {
+		if requested > 0 {
+			return ErrInsufficientWalletBalance
+		}
+		amount = wallet.Balance
+	}
+	amount = roundRupiah(amount)
+	if amount <= 0 {
+		return nil
+	}
+
+	if _, err := s.Debit(tx, order.UserID, amount, WalletEntry{Source: model.WalletSourceCheckout, OrderID: &order.ID}); err != nil {
+		return err
+	}
+	order.WalletAmount = amount
+	return nil
+}
+
+// ReleaseForOrder mengembalikan saldo yang dipakai pesanan yang batal. Aman dipanggil berulang
+// karena hanya selisih antara pemakaian dan pengembalian sebelumnya yang dikreditkan.
+func (s *walletService) ReleaseForOrder(tx *gorm.DB, orderID uuid.UUID) error {
+	txWalletRepo := repository.NewWalletRepository(tx)
+	used, err := txWalletRepo.SumOrderTransactions(orderID, model.WalletSourceCheckout)
+	if err != nil {
+		return err
+	}
+	returned, err := txWalletRepo.SumOrderTransactions(orderID, model.WalletSourceCheckoutReversal)
+	if err != nil {
+		return err
+	}
+	if used-returned <= 0 {
+		return nil
+	}
+
+	var order model.Order
+	if err := tx.Select("id", "user_id").First(&order, orderID).Error; err != nil {
+		return err
+	}
+	_, err = s.Credit(tx, order.UserID, used-returned, WalletEntry{Source: model.WalletSourceCheckoutReversal, OrderID: &orderID})
+	return err
+}
@@ -0,0 +1,426 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+)
+
+// InvoiceLine adalah satu baris item pada faktur.
+type InvoiceLine struct {
+	Description string
+	Quantity    int
+	UnitPrice   float64
+	Discount    float64
+	TaxRate     float64 // Persen
+	Amount      float64
+}
+
+// InvoiceTotal adalah satu baris ringkasan di bawah tabel item, misal ongkos kirim atau PPN.
+type InvoiceTotal struct {
+	Label  string
+	Amount float64
+	Bold   bool
+}
+
+// Invoice berisi data yang dicetak ke faktur PDF.
+type Invoice struct {
+	StoreName     string
+	StoreAddress  string
+	StoreNPWP     string
+	Number        string
+	OrderNumber   string
+	IssuedAt      time.Time
+	PaymentMethod string
+	PaidAt        time.Time
+
+	CustomerName    string
+	CustomerEmail   string
+	ShippingAddress string
+	ShippingMethod  string
+
+	Lines  []InvoiceLine
+	Totals []InvoiceTotal
+	Notes  []string
+}
+
+// Ukuran halaman A4 dan tata letak faktur dalam satuan point.
+const (
+	invoicePageWidth    = 595.28
+	invoicePageHeight   = 841.89
+	invoiceMarginLeft   = 40.0
+	invoiceMarginRight  = invoicePageWidth - 40.0
+	invoiceMarginTop    = invoicePageHeight - 40.0
+	invoiceMarginBottom = 60.0
+	invoiceRowHeight    = 16.0
+
+	invoiceFontRegular = "Helvetica"
+	invoiceFontBold    = "Helvetica-Bold"
+)
+
+// Warna merek yang dipakai di faktur.
+var (
+	invoiceBrandColor = [3]float64{0.11, 0.36, 0.55}
+	invoiceMutedColor = [3]float64{0.4, 0.4, 0.4}
+	invoiceShadeColor = [3]float64{0.92, 0.94, 0.96}
+	invoiceTextColor  = [3]float64{0.1, 0.1, 0.1}
+)
+
+// invoiceColumn adalah satu kolom tabel item. Kolom angka rata kanan terhadap Right.
+type invoiceColumn struct {
+	Title string
+	Left  float64
+	Right float64
+	Align string // left atau right
+}
+
+var invoiceColumns = []invoiceColumn{
+	{"No", 44, 62, "left"},
+	{"Item", 66, 262, "left"},
+	{"Qty", 266, 295, "right"},
+	{"Harga", 300, 370, "right"},
+	{"Diskon", 375, 440, "right"},
+	{"PPN", 445, 475, "right"},
+	{"Jumlah", 480, invoiceMarginRight - 4, "right"},
+}
+
+// RenderInvoicePDF membuat faktur PDF bermerek tanpa bantuan layanan eksternal. Faktur memakai
+// font standar PDF (Helvetica) sehingga tidak perlu menyematkan file font.
+func RenderInvoicePDF(inv Invoice) []byte {
+	w := &invoiceWriter{}
+	w.newPage()
+
+	w.renderHeader(inv)
+	w.renderParties(inv)
+	w.renderLines(inv.Lines)
+	w.renderTotals(inv.Totals)
+	w.renderNotes(inv.Notes)
+
+	for i, page := range w.pages {
+		footer := fmt.Sprintf("%s - %s - Halaman %d dari %d", inv.StoreName, inv.Number, i+1, len(w.pages))
+		writeText(page, invoiceMarginLeft, 30, 8, false, invoiceMutedColor, footer)
+	}
+
+	return w.document(inv)
+}
+
+// invoiceWriter menyusun content stream per halaman sambil melacak posisi vertikal.
+type invoiceWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+func (w *invoiceWriter) newPage() {
+	w.page = &bytes.Buffer{}
+	w.pages = append(w.pages, w.page)
+	w.y = invoiceMarginTop
+}
+
+// ensure memindahkan penulisan ke halaman baru jika sisa ruang kurang dari height.
+func (w *invoiceWriter) ensure(height float64) bool {
+	if w.y-height >= invoiceMarginBottom {
+		return false
+	}
+	w.newPage()
+	return true
+}
+
+func (w *invoiceWriter) renderHeader(inv Invoice) {
+	writeText(w.page, invoiceMarginLeft, w.y-18, 20, true, invoiceBrandColor, inv.StoreName)
+	writeTextRight(w.page, invoiceMarginRight, w.y-18, 20, true, invoiceBrandColor, "FAKTUR")
+	w.y -= 28
+
+	for _, line := range wrapText(inv.StoreAddress, invoiceFontRegular, 9, 300) {
+		writeText(w.page, invoiceMarginLeft, w.y-9, 9, false, invoiceMutedColor, line)
+		w.y -= 12
+	}
+	if inv.StoreNPWP != "" {
+		writeText(w.page, invoiceMarginLeft, w.y-9, 9, false, invoiceMutedColor, "NPWP: "+inv.StoreNPWP)
+		w.y -= 12
+	}
+
+	w.y -= 6
+	drawLine(w.page, invoiceMarginLeft, w.y, invoiceMarginRight, w.y, 1.5, invoiceBrandColor)
+	w.y -= 18
+}
+
+func (w *invoiceWriter) renderParties(inv Invoice) {
+	top := w.y
+
+	// Kiri: pembeli dan tujuan pengiriman
+	writeText(w.page, invoiceMarginLeft, w.y-9, 9, true, invoiceBrandColor, "DITAGIHKAN KEPADA")
+	w.y -= 14
+	writeText(w.page, invoiceMarginLeft, w.y-10, 10, true, invoiceTextColor, inv.CustomerName)
+	w.y -= 13
+	writeText(w.page, invoiceMarginLeft, w.y-9, 9, false, invoiceTextColor, inv.CustomerEmail)
+	w.y -= 12
+	if inv.ShippingAddress != "" {
+		w.y -= 6
+		writeText(w.page, invoiceMarginLeft, w.y-9, 9, true, invoiceBrandColor, "DIKIRIM KE")
+		w.y -= 14
+		for _, line := range wrapText(inv.ShippingAddress, invoiceFontRegular, 9, 260) {
+			writeText(w.page, invoiceMarginLeft, w.y-9, 9, false, invoiceTextColor, line)
+			w.y -= 12
+		}
+		if inv.ShippingMethod != "" {
+			writeText(w.page, invoiceMarginLeft, w.y-9, 9, false, invoiceMutedColor, "Kurir: "+inv.ShippingMethod)
+			w.y -= 12
+		}
+	}
+	left := w.y
+
+	// Kanan: identitas faktur dan pembayaran
+	w.y = top
+	meta := [][2]string{
+		{"No. Faktur", inv.Number},
+		{"No. Pesanan", inv.OrderNumber},
+		{"Tanggal Faktur", inv.IssuedAt.Format("02 Jan 2006")},
+		{"Metode Pembayaran", inv.PaymentMethod},
+	}
+	if !inv.PaidAt.IsZero() {
+		meta = append(meta, [2]string{"Dibayar Pada", inv.PaidAt.Format("02 Jan 2006 15:04")})
+	}
+	for _, row := range meta {
+		writeText(w.page, 340, w.y-9, 9, false, invoiceMutedColor, row[0])
+		writeTextRight(w.page, invoiceMarginRight, w.y-9, 9, true, invoiceTextColor, row[1])
+		w.y -= 14
+	}
+
+	w.y = math.Min(left, w.y) - 16
+}
+
+func (w *invoiceWriter) renderTableHeader() {
+	fillRect(w.page, invoiceMarginLeft, w.y-invoiceRowHeight-2, invoiceMarginRight-invoiceMarginLeft, invoiceRowHeight+2, invoiceShadeColor)
+	for _, col := range invoiceColumns {
+		w.writeCell(col, w.y-12, true, col.Title)
+	}
+	w.y -= invoiceRowHeight + 6
+}
+
+func (w *invoiceWriter) renderLines(lines []InvoiceLine) {
+	w.renderTableHeader()
+	for i, line := range lines {
+		if w.ensure(invoiceRowHeight) {
+			w.renderTableHeader()
+		}
+		discount := "-"
+		if line.Discount > 0 {
+			discount = "-" + formatRupiah(line.Discount)
+		}
+		cells := []string{
+			fmt.Sprintf("%d", i+1),
+			line.Description,
+			fmt.Sprintf("%d", line.Quantity),
+			formatRupiah(line.UnitPrice),
+			discount,
+			formatPercent(line.TaxRate),
+			formatRupiah(line.Amount),
+		}
+		for n, col := range invoiceColumns {
+			w.writeCell(col, w.y-11, false, cells[n])
+		}
+		w.y -= invoiceRowHeight
+		drawLine(w.page, invoiceMarginLeft, w.y+2, invoiceMarginRight, w.y+2, 0.3, invoiceShadeColor)
+	}
+	w.y -= 10
+}
+
+func (w *invoiceWriter) renderTotals(totals []InvoiceTotal) {
+	for _, total := range totals {
+		w.ensure(invoiceRowHeight)
+		size := 9.0
+		if total.Bold {
+			size = 11
+			drawLine(w.page, 340, w.y+1, invoiceMarginRight, w.y+1, 0.8, invoiceBrandColor)
+			w.y -= 3
+		}
+		amount := formatRupiah(total.Amount)
+		if total.Amount < 0 {
+			amount = "-" + formatRupiah(-total.Amount)
+		}
+		writeTextRight(w.page, 440, w.y-11, size, total.Bold, invoiceTextColor, total.Label)
+		writeTextRight(w.page, invoiceMarginRight-4, w.y-11, size, total.Bold, invoiceTextColor, amount)
+		w.y -= invoiceRowHeight
+	}
+	w.y -= 12
+}
+
+func (w *invoiceWriter) renderNotes(notes []string) {
+	for _, note := range notes {
+		for _, line := range wrapText(note, invoiceFontRegular, 8, invoiceMarginRight-invoiceMarginLeft) {
+			w.ensure(11)
+			writeText(w.page, invoiceMarginLeft, w.y-8, 8, false, invoiceMutedColor, line)
+			w.y -= 11
+		}
+	}
+}
+
+// writeCell menulis isi satu sel tabel, dipotong agar tidak melewati lebar kolom.
+func (w *invoiceWriter) writeCell(col invoiceColumn, y float64, bold bool, text string) {
+	fontName := invoiceFontRegular
+	if bold {
+		fontName = invoiceFontBold
+	}
+	text = fitText(text, fontName, 9, col.Right-col.Left)
+	if col.Align == "right" {
+		writeTextRight(w.page, col.Right, y, 9, bold, invoiceTextColor, text)
+		return
+	}
+	writeText(w.page, col.Left, y, 9, bold, invoiceTextColor, text)
+}
+
+// document merangkai semua halaman menjadi file PDF lengkap beserta tabel xref-nya.
+func (w *invoiceWriter) document(inv Invoice) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objek 1-5 tetap, lalu pasangan halaman dan content stream mulai dari objek 6
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+i*2)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Author (%s) /Producer (%s) /CreationDate (D:%s) >>",
+		escapePDFText(toWinAnsi("Faktur "+inv.Number)), escapePDFText(toWinAnsi(inv.StoreName)),
+		escapePDFText(toWinAnsi(inv.StoreName)), inv.IssuedAt.UTC().Format("20060102150405")+"Z"))
+	for _, page := range w.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			invoicePageWidth, invoicePageHeight, len(offsets)+2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func writeText(page *bytes.Buffer, x, y, size float64, bold bool, color [3]float64, text string) {
+	if text == "" {
+		return
+	}
+	fontRef := "F1"
+	if bold {
+		fontRef = "F2"
+	}
+	fmt.Fprintf(page, "BT %.3f %.3f %.3f rg /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		color[0], color[1], color[2], fontRef, size, x, y, escapePDFText(toWinAnsi(text)))
+}
+
+func writeTextRight(page *bytes.Buffer, right, y, size float64, bold bool, color [3]float64, text string) {
+	writeText(page, right-textWidth(text, bold, size), y, size, bold, color, text)
+}
+
+func drawLine(page *bytes.Buffer, x1, y1, x2, y2, width float64, color [3]float64) {
+	fmt.Fprintf(page, "%.3f %.3f %.3f RG %.2f w %.2f %.2f m %.2f %.2f l S\n", color[0], color[1], color[2], width, x1, y1, x2, y2)
+}
+
+func fillRect(page *bytes.Buffer, x, y, width, height float64, color [3]float64) {
+	fmt.Fprintf(page, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", color[0], color[1], color[2], x, y, width, height)
+}
+
+func textWidth(text string, bold bool, size float64) float64 {
+	fontName := invoiceFontRegular
+	if bold {
+		fontName = invoiceFontBold
+	}
+	return font.TextWidth(toWinAnsi(text), fontName, 1) * size
+}
+
+// fitText memotong teks dengan elipsis agar muat di lebar yang tersedia.
+func fitText(text, fontName string, size, width float64) string {
+	bold := fontName == invoiceFontBold
+	if textWidth(text, bold, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(string(runes)+"...", bold, size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// wrapText memecah teks menjadi beberapa baris yang muat di lebar yang tersedia.
+// Baris baru pada teks asli tetap dipertahankan.
+func wrapText(text, fontName string, size, width float64) []string {
+	bold := fontName == invoiceFontBold
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := strings.TrimSpace(current + " " + word)
+			if current != "" && textWidth(candidate, bold, size) > width {
+				lines = append(lines, current)
+				candidate = word
+			}
+			current = candidate
+		}
+		if current != "" {
+			lines = append(lines, current)
+		}
+	}
+	return lines
+}
+
+// toWinAnsi mengubah teks ke encoding WinAnsi milik font standar PDF. Karakter di luar
+// Latin-1 (misal emoji atau aksara non-Latin) diganti tanda tanya.
+func toWinAnsi(text string) string {
+	replacer := strings.NewReplacer("–", "-", "—", "-", "‘", "'", "’", "'", "“", "\"", "”", "\"")
+	text = replacer.Replace(text)
+
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r < 0x20:
+			continue
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return string(out)
+}
+
+func escapePDFText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(text)
+}
+
+// formatRupiah menulis nominal dengan pemisah ribuan gaya Indonesia, misal Rp 1.250.000.
+func formatRupiah(amount float64) string {
+	digits := fmt.Sprintf("%.0f", math.Abs(math.Round(amount)))
+	var grouped []string
+	for len(digits) > 3 {
+		grouped = append([]string{digits[len(digits)-3:]}, grouped...)
+		digits = digits[:len(digits)-3]
+	}
+	grouped = append([]string{digits}, grouped...)
+	return "Rp " + strings.Join(grouped, ".")
+}
+
+func formatPercent(rate float64) string {
+	if rate == 0 {
+		return "-"
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%.2f", rate), "0"), ".0") + "%"
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"ngabaca/config"
+	"strings"
+)
+
+// MailAttachment adalah file yang dilampirkan ke email.
+type MailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// SendMail mengirim email HTML menggunakan konfigurasi SMTP aplikasi.
+// MAIL_ENCRYPTION=ssl memakai TLS langsung (biasanya port 465), selain itu memakai STARTTLS jika tersedia.
+func SendMail(cfg config.Config, to, subject, htmlBody string) error {
+	return SendMailWithAttachments(cfg, to, subject, htmlBody)
+}
+
+// SendMailWithAttachments mengirim email HTML beserta lampiran sebagai pesan multipart/mixed.
+func SendMailWithAttachments(cfg config.Config, to, subject, htmlBody string, attachments ...MailAttachment) error {
+	if cfg.MailHost == "" {
+		return fmt.Errorf("mail host is not configured")
+	}
+
+	var msg bytes.Buffer
+	msg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", cfg.MailFromName, cfg.MailFromAddress))
+	msg.WriteString(fmt.Sprintf("To: %s\r\n", to))
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	if len(attachments) == 0 {
+		msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+		msg.WriteString(htmlBody)
+	} else {
+		writeMultipartBody(&msg, htmlBody, attachments)
+	}
+
+	addr := net.JoinHostPort(cfg.MailHost, cfg.MailPort)
+	auth := smtp.PlainAuth("", cfg.MailUsername, cfg.MailPassword, cfg.MailHost)
+
+	if strings.EqualFold(cfg.MailEncryption, "ssl") {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: cfg.MailHost})
+		if err != nil {
+			return err
+		}
+		client, err := smtp.NewClient(conn, cfg.MailHost)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+		if err := client.Mail(cfg.MailFromAddress); err != nil {
+			return err
+		}
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+		w, err := client.Data()
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(msg.Bytes()); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		return client.Quit()
+	}
+
+	return smtp.SendMail(addr, auth, cfg.MailFromAddress, []string{to}, msg.Bytes())
+}
+
+// writeMultipartBody menulis badan email HTML diikuti lampiran yang dikodekan base64.
+func writeMultipartBody(msg *bytes.Buffer, htmlBody string, attachments []MailAttachment) {
+	random := make([]byte, 12)
+	_, _ = rand.Read(random)
+	boundary := "ngabaca-" + hex.EncodeToString(random)
+
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n\r\n", boundary))
+	msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+	msg.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n")
+	msg.WriteString(htmlBody)
+	msg.WriteString("\r\n")
+
+	for _, attachment := range attachments {
+		contentType := DefaultString(attachment.ContentType, "application/octet-stream")
+		msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		msg.WriteString(fmt.Sprintf("Content-Type: %s; name=\"%s\"\r\n", contentType, attachment.Filename))
+		msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+		msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n\r\n", attachment.Filename))
+
+		// Baris base64 dibatasi 76 karakter sesuai RFC 2045
+		encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+		for len(encoded) > 76 {
+			msg.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		msg.WriteString(encoded + "\r\n")
+	}
+	msg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// SignResource membuat tanda tangan HMAC-SHA256 untuk sebuah resource yang berlaku sampai waktu expires (unix).
+func SignResource(secret, resource string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResourceSignature memastikan tanda tangan valid dan belum kedaluwarsa.
+func VerifyResourceSignature(secret, resource string, expires int64, signature string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	expected := SignResource(secret, resource, expires)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func init() {
+	// pdfcpu secara default menulis config.yml ke home directory, tidak diperlukan di server
+	pdfmodel.ConfigPath = "disable"
+}
+
+// BuyerStamp berisi identitas pembeli yang dicap ke file ebook.
+type BuyerStamp struct {
+	Name    string
+	Email   string
+	OrderID string
+}
+
+// Text mengembalikan teks cap yang ditampilkan pada file.
+func (b BuyerStamp) Text() string {
+	return fmt.Sprintf("Dibeli oleh %s (%s) - Pesanan %s", b.Name, b.Email, b.OrderID)
+}
+
+// WatermarkPDF menambahkan footer berisi identitas pembeli di setiap halaman serta metadata dokumen.
+func WatermarkPDF(src, dst string, stamp BuyerStamp) (err error) {
+	// pdfcpu bisa panic ketika membaca PDF yang rusak, ubah menjadi error biasa
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("stamp pdf: malformed file: %v", r)
+		}
+	}()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	wm, err := api.TextWatermark(stamp.Text(), "font:Helvetica, points:8, pos:bc, off:0 12, scale:1 abs, rot:0, op:0.7, fillc:#555555", true, false, types.POINTS)
+	if err != nil {
+		return err
+	}
+
+	var stamped bytes.Buffer
+	if err := api.AddWatermarks(bytes.NewReader(data), &stamped, nil, wm, nil); err != nil {
+		return fmt.Errorf("stamp pdf: %w", err)
+	}
+
+	properties := map[string]string{
+		"BuyerName":  stamp.Name,
+		"BuyerEmail": stamp.Email,
+		"OrderID":    stamp.OrderID,
+	}
+	var out bytes.Buffer
+	if err := api.AddProperties(bytes.NewReader(stamped.Bytes()), &out, properties, nil); err != nil {
+		return fmt.Errorf("set pdf metadata: %w", err)
+	}
+
+	return os.WriteFile(dst, out.Bytes(), 0o640)
+}
+
+var epubRootfileRe = regexp.MustCompile(`full-path="([^"]+)"`)
+
+const epubColophonName = "ngabaca-colophon.xhtml"
+
+// WatermarkEPUB menyisipkan halaman kolofon berisi identitas pembeli di akhir EPUB.
+func WatermarkEPUB(src, dst string, stamp BuyerStamp) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	opfPath, err := findEPUBPackagePath(&reader.Reader)
+	if err != nil {
+		return err
+	}
+	colophonPath := path.Join(path.Dir(opfPath), epubColophonName)
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := zip.NewWriter(out)
+	for _, f := range reader.File {
+		if f.Name == colophonPath {
+			continue
+		}
+
+		if f.Name == opfPath {
+			opf, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			patched, err := injectColophonIntoOPF(opf)
+			if err != nil {
+				return err
+			}
+			w, err := writer.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(patched); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Salin apa adanya (termasuk file mimetype yang tidak dikompresi)
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return err
+		}
+		w, err := writer.CreateRaw(&f.FileHeader)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, raw); err != nil {
+			return err
+		}
+	}
+
+	w, err := writer.CreateHeader(&zip.FileHeader{Name: colophonPath, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildColophonPage(stamp)); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func findEPUBPackagePath(r *zip.Reader) (string, error) {
+	for _, f := range r.File {
+		if f.Name != "META-INF/container.xml" {
+			continue
+		}
+		container, err := readZipFile(f)
+		if err != nil {
+			return "", err
+		}
+		match := epubRootfileRe.FindSubmatch(container)
+		if match == nil {
+			return "", fmt.Errorf("epub container has no rootfile")
+		}
+		return string(match[1]), nil
+	}
+	return "", fmt.Errorf("epub is missing META-INF/container.xml")
+}
+
+func injectColophonIntoOPF(opf []byte) ([]byte, error) {
+	content := string(opf)
+	if !strings.Contains(content, "</manifest>") || !strings.Contains(content, "</spine>") {
+		return nil, fmt.Errorf("epub package document has no manifest or spine")
+	}
+
+	item := `<item id="ngabaca-colophon" href="` + epubColophonName + `" media-type="application/xhtml+xml"/>`
+	content = strings.Replace(content, "</manifest>", item+"\n</manifest>", 1)
+	content = strings.Replace(content, "</spine>", `<itemref idref="ngabaca-colophon" linear="yes"/>`+"\n</spine>", 1)
+	return []byte(content), nil
+}
+
+func buildColophonPage(stamp BuyerStamp) []byte {
+	var buf bytes.Buffer
+	escape := func(s string) string {
+		var b bytes.Buffer
+		_ = xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<!DOCTYPE html>` + "\n")
+	buf.WriteString(`<html xmlns="http://www.w3.org/1999/xhtml"><head><title>Kolofon</title></head><body>` + "\n")
+	buf.WriteString(`<h2>Kolofon</h2>` + "\n")
+	buf.WriteString(`<p>Salinan ebook ini dilisensikan untuk:</p>` + "\n")
+	buf.WriteString(`<p><strong>` + escape(stamp.Name) + `</strong><br/>` + escape(stamp.Email) + `</p>` + "\n")
+	buf.WriteString(`<p>Nomor pesanan: ` + escape(stamp.OrderID) + `</p>` + "\n")
+	buf.WriteString(`<p>Dilarang menyebarluaskan file ini tanpa izin penerbit.</p>` + "\n")
+	buf.WriteString(`</body></html>` + "\n")
+	return buf.Bytes()
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}